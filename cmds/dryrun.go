@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/urfave/cli/v3"
 
@@ -26,7 +25,7 @@ execute, helping you debug your workflows locally.`,
 				Name: "workflow-file",
 			},
 		},
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "event",
 				Aliases: []string{"e"},
@@ -38,41 +37,43 @@ execute, helping you debug your workflows locally.`,
 				Aliases: []string{"r"},
 				Usage:   "Git ref to use (defaults to current branch)",
 			},
-			&cli.StringSliceFlag{
-				Name:    "secret",
-				Aliases: []string{"s"},
-				Usage:   "Secrets in KEY=VALUE format",
-			},
-		},
+		}, inputFlags()...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 			workflowFile := c.StringArg("workflow-file")
 			if workflowFile == "" {
 				return errors.New("missing required argument: <workflow-file>")
 			}
 
-			return runDryrun(workflowFile, c.String("event"), c.String("ref"), c.StringSlice("secret"))
+			return runDryrun(workflowFile, c.String("event"), c.String("ref"), inputConfigFrom(c))
 		},
 	}
 )
 
-func runDryrun(workflowPath, eventName, ref string, secretArgs []string) error {
+func runDryrun(workflowPath, eventName, ref string, inputs inputConfig) error {
 	wf, err := workflow.Parse(workflowPath)
 	if err != nil {
 		return fmt.Errorf("parsing workflow: %w", err)
 	}
 
-	secrets := make(map[string]string)
-	for _, s := range secretArgs {
-		secretParts := strings.SplitN(s, "=", 2)
-		if len(secretParts) == 2 {
-			secrets[secretParts[0]] = secretParts[1]
-		}
+	if err := wf.Validate(); err != nil {
+		return fmt.Errorf("invalid workflow %s: %w", workflowPath, err)
+	}
+
+	secrets, err := inputs.loadSecrets()
+	if err != nil {
+		return err
+	}
+
+	vars, err := inputs.loadVars()
+	if err != nil {
+		return err
 	}
 
 	ctx, err := workflow.NewContext(workflow.Options{
 		EventName: eventName,
 		Ref:       ref,
 		Secrets:   secrets,
+		Vars:      vars,
 	})
 	if err != nil {
 		return fmt.Errorf("building context: %w", err)
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/urfave/cli/v3"
 
@@ -38,7 +37,7 @@ Requirements:
 				Name: "workflow-file",
 			},
 		},
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "event",
 				Aliases: []string{"e"},
@@ -50,10 +49,9 @@ Requirements:
 				Aliases: []string{"r"},
 				Usage:   "Git ref to use (defaults to current branch)",
 			},
-			&cli.StringSliceFlag{
-				Name:    "secret",
-				Aliases: []string{"s"},
-				Usage:   "Secrets in KEY=VALUE format",
+			&cli.BoolFlag{
+				Name:  "no-prompt",
+				Usage: "Do not prompt for secrets the workflow references but were not given",
 			},
 			&cli.StringFlag{
 				Name:  "working-dir",
@@ -67,10 +65,29 @@ Requirements:
 			},
 			&cli.BoolFlag{
 				Name:  "cleanup",
-				Usage: "Clean up containers and volumes after execution",
+				Usage: "Remove job containers when their job ends (set to false to keep them for inspection)",
 				Value: true,
 			},
-		},
+			&cli.StringSliceFlag{
+				Name:    "platform",
+				Aliases: []string{"P"},
+				Usage:   "Image to run jobs with a runs-on label in, as LABEL=IMAGE",
+			},
+			&cli.StringFlag{
+				Name:  "platform-file",
+				Usage: "File of LABEL=IMAGE platform mappings, one per line (defaults to rehearse/platforms in the user config directory, if present)",
+			},
+			&cli.IntFlag{
+				Name:    "jobs",
+				Aliases: []string{"j"},
+				Usage:   "Maximum number of jobs to run concurrently (0 for no limit)",
+				Value:   0,
+			},
+			&cli.StringFlag{
+				Name:  "summary-file",
+				Usage: "Save the markdown jobs write to GITHUB_STEP_SUMMARY to this file",
+			},
+		}, inputFlags()...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 			workflowFile := c.StringArg("workflow-file")
 			if workflowFile == "" {
@@ -81,10 +98,15 @@ Requirements:
 				WorkflowFile: workflowFile,
 				EventName:    c.String("event"),
 				Ref:          c.String("ref"),
-				SecretArgs:   c.StringSlice("secret"),
+				Inputs:       inputConfigFrom(c),
+				Prompt:       !c.Bool("no-prompt"),
 				WorkingDir:   c.String("working-dir"),
 				Pull:         c.Bool("pull"),
 				Cleanup:      c.Bool("cleanup"),
+				MaxJobs:      c.Int("jobs"),
+				PlatformArgs: c.StringSlice("platform"),
+				PlatformFile: c.String("platform-file"),
+				SummaryFile:  c.String("summary-file"),
 			})
 		},
 	}
@@ -95,10 +117,15 @@ type runConfig struct {
 	WorkflowFile string
 	EventName    string
 	Ref          string
-	SecretArgs   []string
+	Inputs       inputConfig
+	Prompt       bool // Ask for referenced secrets that were not given
 	WorkingDir   string
 	Pull         bool
 	Cleanup      bool
+	MaxJobs      int
+	PlatformArgs []string
+	PlatformFile string
+	SummaryFile  string
 }
 
 // runWorkflow executes a workflow with the given configuration.
@@ -119,18 +146,36 @@ func runWorkflow(ctx context.Context, config runConfig) error {
 		return fmt.Errorf("parsing workflow: %w", err)
 	}
 
-	secrets := make(map[string]string)
-	for _, s := range config.SecretArgs {
-		secretParts := strings.SplitN(s, "=", 2)
-		if len(secretParts) == 2 {
-			secrets[secretParts[0]] = secretParts[1]
+	if err := wf.Validate(); err != nil {
+		return fmt.Errorf("invalid workflow %s: %w", config.WorkflowFile, err)
+	}
+
+	platforms, err := loadPlatforms(config.PlatformFile, config.PlatformArgs)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := config.Inputs.loadSecrets()
+	if err != nil {
+		return err
+	}
+
+	if config.Prompt {
+		if err := promptSecrets(wf, secrets); err != nil {
+			return err
 		}
 	}
 
+	vars, err := config.Inputs.loadVars()
+	if err != nil {
+		return err
+	}
+
 	triggerContext, err := workflow.NewContext(workflow.Options{
 		EventName: config.EventName,
 		Ref:       config.Ref,
 		Secrets:   secrets,
+		Vars:      vars,
 	})
 	if err != nil {
 		return fmt.Errorf("building context: %w", err)
@@ -160,11 +205,17 @@ func runWorkflow(ctx context.Context, config runConfig) error {
 
 	executor := workflow.NewExecutor(analyzer, dockerClient, gitClient)
 	executor.SetWorkingDirectory(workingDir)
+	executor.SetMaxParallelJobs(config.MaxJobs)
+	executor.SetCleanup(config.Cleanup)
+	executor.SetPlatforms(platforms)
+	executor.SetSummaryFile(config.SummaryFile)
 
 	renderer.RenderWorkflowStart(wf.Name, workingDir, config.EventName, config.Ref)
 
 	renderer.RenderExecutionStart()
-	if err := executor.Execute(ctx, wf, triggerContext); err != nil {
+	err = executor.Execute(ctx, wf, triggerContext)
+	renderer.RenderAnnotations(executor.Annotations())
+	if err != nil {
 		renderer.RenderWorkflowError(err)
 		return fmt.Errorf("executing workflow: %w", err)
 	}
@@ -173,6 +224,38 @@ func runWorkflow(ctx context.Context, config runConfig) error {
 	return nil
 }
 
+// loadPlatforms builds the platform map from the defaults, then the platform
+// file, then the -P flags, each overriding the mappings before it. Without an
+// explicit file, the one in the user config directory is used if it exists.
+func loadPlatforms(file string, args []string) (workflow.Platforms, error) {
+	platforms := workflow.DefaultPlatforms()
+
+	if file == "" {
+		if configDir, err := os.UserConfigDir(); err == nil {
+			defaultFile := filepath.Join(configDir, "rehearse", "platforms")
+			if _, err := os.Stat(defaultFile); err == nil {
+				file = defaultFile
+			}
+		}
+	}
+
+	if file != "" {
+		if err := platforms.LoadPlatformFile(file); err != nil {
+			return nil, fmt.Errorf("loading platform file: %w", err)
+		}
+	}
+
+	for _, arg := range args {
+		label, image, err := workflow.ParsePlatform(arg)
+		if err != nil {
+			return nil, err
+		}
+		platforms[label] = image
+	}
+
+	return platforms, nil
+}
+
 // validateDockerAvailable checks if Docker is available and running.
 func validateDockerAvailable() error {
 	dockerClient, err := workflow.NewDockerClient()
@@ -0,0 +1,166 @@
+package cmds
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/urfave/cli/v3"
+
+	"github.com/telton/rehearse/workflow"
+)
+
+// inputFlags returns the flags through which run and dryrun take the secrets
+// and variables of a workflow.
+func inputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "secret",
+			Aliases: []string{"s"},
+			Usage:   "Secrets in KEY=VALUE format",
+		},
+		&cli.StringFlag{
+			Name:  "secret-file",
+			Usage: "File of secrets in dotenv format",
+		},
+		&cli.StringSliceFlag{
+			Name:  "secret-from-env",
+			Usage: "Secret to take from the environment variable of the same name",
+		},
+		&cli.StringSliceFlag{
+			Name:  "var",
+			Usage: "Variables for the vars context in KEY=VALUE format",
+		},
+		&cli.StringFlag{
+			Name:  "var-file",
+			Usage: "File of variables for the vars context in dotenv format",
+		},
+	}
+}
+
+// inputConfig holds where the secrets and variables of a workflow come from.
+type inputConfig struct {
+	SecretArgs    []string
+	SecretFile    string
+	SecretFromEnv []string
+	VarArgs       []string
+	VarFile       string
+}
+
+// inputConfigFrom returns the input configuration set by c's flags.
+func inputConfigFrom(c *cli.Command) inputConfig {
+	return inputConfig{
+		SecretArgs:    c.StringSlice("secret"),
+		SecretFile:    c.String("secret-file"),
+		SecretFromEnv: c.StringSlice("secret-from-env"),
+		VarArgs:       c.StringSlice("var"),
+		VarFile:       c.String("var-file"),
+	}
+}
+
+// loadSecrets builds the secrets from the secret file, then the environment
+// variables --secret-from-env names, then the --secret flags, each
+// overriding the secrets before it.
+func (c inputConfig) loadSecrets() (map[string]string, error) {
+	secrets, err := loadVariables("secret", c.SecretFile, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range c.SecretFromEnv {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("secret %s: environment variable %s is not set", name, name)
+		}
+		secrets[name] = value
+	}
+
+	if err := addVariables(secrets, "secret", c.SecretArgs); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// loadVars builds the vars context from the variable file, then the --var
+// flags, which override it.
+func (c inputConfig) loadVars() (map[string]string, error) {
+	return loadVariables("variable", c.VarFile, c.VarArgs)
+}
+
+// loadVariables reads the secrets or variables, as kind says, in the dotenv
+// file, if any, and adds those given as NAME=VALUE in args.
+func loadVariables(kind, file string, args []string) (map[string]string, error) {
+	values := make(map[string]string)
+	if file != "" {
+		loaded, err := workflow.LoadDotenv(file)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s file: %w", kind, err)
+		}
+		values = loaded
+	}
+
+	if err := addVariables(values, kind, args); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// addVariables adds the secrets or variables given as NAME=VALUE in args to
+// values. A malformed one is an error rather than being dropped.
+func addVariables(values map[string]string, kind string, args []string) error {
+	for _, arg := range args {
+		name, value, err := workflow.ParseVariable(kind, arg)
+		if err != nil {
+			return err
+		}
+		values[name] = value
+	}
+	return nil
+}
+
+// promptSecrets asks for each secret the workflow references but secrets
+// lacks, reading the answers without echoing them. It only prompts when
+// stdin is a terminal; an empty answer leaves the secret unset. GITHUB_TOKEN,
+// which GitHub provides rather than users, is not asked for: it is given like
+// other secrets, such as with --secret-from-env GITHUB_TOKEN.
+func promptSecrets(wf *workflow.Workflow, secrets map[string]string) error {
+	fd := os.Stdin.Fd()
+	if !term.IsTerminal(fd) {
+		return nil
+	}
+
+	for _, secret := range workflow.MissingSecrets(wf.SecretRefs(), secrets) {
+		if secret.ProvidedByGitHub {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Secret %s (used by %s): ", secret.Name, secretUsers(secret))
+		value, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("reading secret %s: %w", secret.Name, err)
+		}
+
+		if len(value) > 0 {
+			secrets[secret.Name] = string(value)
+		}
+	}
+
+	return nil
+}
+
+// secretUsers describes the jobs that reference secret, or the workflow env
+// if it does.
+func secretUsers(secret workflow.MissingSecret) string {
+	var users []string
+	for _, ref := range secret.Refs {
+		user := cmp.Or(ref.Job, "workflow env")
+		if !slices.Contains(users, user) {
+			users = append(users, user)
+		}
+	}
+	return strings.Join(users, ", ")
+}
@@ -2,10 +2,13 @@
 package logger
 
 import (
+	"context"
 	"io"
 	"log/slog"
 	"os"
 	"strings"
+
+	"github.com/telton/rehearse/internal/mask"
 )
 
 var globalLogger *slog.Logger
@@ -44,11 +47,14 @@ func Setup(cfg *Config) {
 		Level: level,
 	}
 
+	// Log records can carry secrets, such as in the errors they report.
+	output := mask.Writer(cfg.Output)
+
 	var handler slog.Handler
 	if cfg.Format == "json" {
-		handler = slog.NewJSONHandler(cfg.Output, opts)
+		handler = slog.NewJSONHandler(output, opts)
 	} else {
-		handler = slog.NewTextHandler(cfg.Output, opts)
+		handler = slog.NewTextHandler(output, opts)
 	}
 
 	globalLogger = slog.New(handler)
@@ -95,6 +101,11 @@ func Get() *slog.Logger {
 	return globalLogger
 }
 
+// DebugEnabled reports whether debug messages are logged
+func DebugEnabled() bool {
+	return Get().Enabled(context.Background(), slog.LevelDebug)
+}
+
 // Debug logs at debug level
 func Debug(msg string, args ...any) {
 	Get().Debug(msg, args...)
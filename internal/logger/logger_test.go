@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+
+	"github.com/telton/rehearse/internal/mask"
 )
 
 func TestLoggerSetup(t *testing.T) {
@@ -75,3 +77,19 @@ func TestParseLevelFromString(t *testing.T) {
 		})
 	}
 }
+
+func TestLoggerSetup_MasksSecrets(t *testing.T) {
+	mask.Add("logger-test-secret")
+
+	var buf bytes.Buffer
+	Setup(&Config{Level: LevelInfo, Format: "json", Output: &buf})
+
+	Error("Login failed", "error", `token "logger-test-secret" rejected`)
+
+	if strings.Contains(buf.String(), "logger-test-secret") {
+		t.Errorf("Expected the secret to be masked, got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `token \"***\" rejected`) {
+		t.Errorf("Expected the masked message, got: %s", buf.String())
+	}
+}
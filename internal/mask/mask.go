@@ -0,0 +1,156 @@
+// Package mask redacts secrets from everything rehearse prints, so that
+// recorded terminal sessions are safe to share.
+package mask
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Replacement is what masked values are replaced with, as on GitHub.
+const Replacement = "***"
+
+// minVariantLength is the shortest encoded form or line of a value that is
+// masked. Shorter ones, such as a closing brace, would mask unrelated output.
+const minVariantLength = 4
+
+var defaultMasker = New()
+
+// Masker replaces registered values, and the common encodings of them, with
+// Replacement.
+type Masker struct {
+	mu       sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+// New returns a masker with no values registered.
+func New() *Masker {
+	return &Masker{values: make(map[string]bool)}
+}
+
+// Add registers value to be masked, along with its base64, URL-encoded and
+// JSON-escaped forms. Each line of a multiline value is masked on its own
+// too. Blank values are ignored.
+func (m *Masker) Add(value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[value] = true
+	for _, v := range variants(value) {
+		if len(strings.TrimSpace(v)) >= minVariantLength {
+			m.values[v] = true
+		}
+	}
+
+	// Longer values go first, so that a value is masked whole rather than
+	// around a shorter one it contains.
+	values := slices.SortedFunc(maps.Keys(m.values), func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+	})
+
+	oldnew := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		oldnew = append(oldnew, v, Replacement)
+	}
+	m.replacer = strings.NewReplacer(oldnew...)
+}
+
+// variants returns the lines of value and the forms it takes once encoded.
+func variants(value string) []string {
+	var forms []string
+	if strings.Contains(value, "\n") {
+		for line := range strings.Lines(value) {
+			forms = append(forms, strings.TrimRight(line, "\r\n"))
+		}
+	}
+
+	forms = append(forms,
+		base64Prefix(base64.StdEncoding, value),
+		base64Prefix(base64.URLEncoding, value),
+		url.QueryEscape(value),
+		url.PathEscape(value),
+		jsonEscape(value, true),
+		jsonEscape(value, false),
+	)
+
+	return forms
+}
+
+// base64Prefix returns the characters of the base64 encoding of value that
+// value alone determines, so that it is masked when followed by more data.
+func base64Prefix(enc *base64.Encoding, value string) string {
+	encoded := enc.WithPadding(base64.NoPadding).EncodeToString([]byte(value))
+	return encoded[:len(value)*4/3]
+}
+
+// jsonEscape returns value as it appears inside a JSON string.
+func jsonEscape(value string, escapeHTML bool) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(value); err != nil {
+		return value
+	}
+	quoted := strings.TrimSuffix(buf.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
+
+// String returns s with every registered value replaced.
+func (m *Masker) String(s string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.replacer == nil {
+		return s
+	}
+	return m.replacer.Replace(s)
+}
+
+// Writer returns a writer that masks what is written to it before passing
+// it on to w. Each write is masked on its own, which suits writers that are
+// given whole lines, as loggers and renderers are.
+func (m *Masker) Writer(w io.Writer) io.Writer {
+	return &writer{masker: m, w: w}
+}
+
+type writer struct {
+	masker *Masker
+	w      io.Writer
+}
+
+// Write writes p to the underlying writer, masked. It reports the length of
+// p as written, since masking changes the length of what reaches the writer.
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.masker.String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Add registers value with the masker every output sink goes through.
+func Add(value string) {
+	defaultMasker.Add(value)
+}
+
+// String masks s with the masker every output sink goes through.
+func String(s string) string {
+	return defaultMasker.String(s)
+}
+
+// Writer wraps w with the masker every output sink goes through.
+func Writer(w io.Writer) io.Writer {
+	return defaultMasker.Writer(w)
+}
@@ -0,0 +1,112 @@
+package mask
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestMasker_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		input    string
+		expected string
+	}{
+		{
+			name:     "nothing registered",
+			input:    "token=abc123",
+			expected: "token=abc123",
+		},
+		{
+			name:     "plain",
+			values:   []string{"hunter2"},
+			input:    "password is hunter2, twice: hunter2",
+			expected: "password is ***, twice: ***",
+		},
+		{
+			name:     "base64",
+			values:   []string{"user:p4ssw0rd"},
+			input:    "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("user:p4ssw0rd")),
+			expected: "Authorization: Basic ***A==",
+		},
+		{
+			name:     "base64 followed by more data",
+			values:   []string{"s3cr3t-value"},
+			input:    base64.StdEncoding.EncodeToString([]byte("s3cr3t-value and more")),
+			expected: "***IGFuZCBtb3Jl",
+		},
+		{
+			name:     "url-encoded",
+			values:   []string{"p@ss word/&"},
+			input:    "https://example.com/?key=" + url.QueryEscape("p@ss word/&") + " " + url.PathEscape("p@ss word/&"),
+			expected: "https://example.com/?key=*** ***",
+		},
+		{
+			name:     "json-escaped",
+			values:   []string{`say "hi" <now>` + "\n"},
+			input:    `{"a":"say \"hi\" <now>\n","b":"say \"hi\" <now>\n"}`,
+			expected: `{"a":"***","b":"***"}`,
+		},
+		{
+			name:     "lines of a multiline value",
+			values:   []string{"-----BEGIN KEY-----\nMIIBOgIBAAJBAK\n}\n-----END KEY-----"},
+			input:    "line: MIIBOgIBAAJBAK }",
+			expected: "line: *** }",
+		},
+		{
+			name:     "longest value first",
+			values:   []string{"abcd", "abcdefgh"},
+			input:    "abcdefgh abcd",
+			expected: "*** ***",
+		},
+		{
+			name:     "blank values ignored",
+			values:   []string{"", "   "},
+			input:    "a b",
+			expected: "a b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			for _, v := range tt.values {
+				m.Add(v)
+			}
+
+			if got := m.String(tt.input); got != tt.expected {
+				t.Errorf("String(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMasker_Writer(t *testing.T) {
+	m := New()
+	m.Add("hunter2")
+
+	var buf bytes.Buffer
+	w := m.Writer(&buf)
+
+	n, err := fmt.Fprintln(w, "password=hunter2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != len("password=hunter2\n") {
+		t.Errorf("wrote %d bytes, want %d", n, len("password=hunter2\n"))
+	}
+	if got := buf.String(); got != "password=***\n" {
+		t.Errorf("got %q, want %q", got, "password=***\n")
+	}
+
+	// Values registered later are masked too.
+	m.Add("s3cr3t")
+	buf.Reset()
+	fmt.Fprint(w, "s3cr3t")
+	if got := buf.String(); got != "***" {
+		t.Errorf("got %q, want %q", got, "***")
+	}
+}
@@ -6,13 +6,14 @@ import (
 	"os"
 
 	"github.com/telton/rehearse/cmds"
+	"github.com/telton/rehearse/internal/mask"
 )
 
 func main() {
 	ctx := context.Background()
 
 	if err := cmds.Execute(ctx, os.Args); err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
+		fmt.Fprintln(mask.Writer(os.Stderr), err.Error())
 		os.Exit(1)
 	}
 }
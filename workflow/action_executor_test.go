@@ -1,11 +1,15 @@
 package workflow
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestActionStepExecutor_CanExecute(t *testing.T) {
@@ -79,7 +83,7 @@ func TestActionStepExecutor_Execute_LocalAction(t *testing.T) {
 	step := CreateTestActionStep("local-action", "Local Action", "./my-action", map[string]string{
 		"input1": "value1",
 	})
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	actionMetadata := CreateTestActionMetadata("docker", "my-action:latest", "")
 
@@ -89,6 +93,8 @@ func TestActionStepExecutor_Execute_LocalAction(t *testing.T) {
 		return config.Image == "my-action:latest"
 	})).Return("action-container", nil)
 	mockDocker.On("StartContainer", mock.Anything, "action-container").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "action-container").Return(&ExecResult{}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "action-container").Return(0, nil)
 	mockDocker.On("StopContainer", mock.Anything, "action-container").Return(nil)
 	mockDocker.On("RemoveContainer", mock.Anything, "action-container").Return(nil)
 
@@ -103,6 +109,55 @@ func TestActionStepExecutor_Execute_LocalAction(t *testing.T) {
 	mockGit.AssertExpectations(t)
 }
 
+func TestActionStepExecutor_Execute_DockerActionHooks(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	mockGit := NewMockGitRepo()
+	executor := &ActionStepExecutor{Docker: mockDocker, Git: mockGit}
+
+	step := CreateTestActionStep("local-action", "Local Action", "./my-action", nil)
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+
+	actionMetadata := CreateTestActionMetadata("docker", "my-action:latest", "")
+	actionMetadata.Runs.PreEntrypoint = "/pre.sh"
+	actionMetadata.Runs.PostEntrypoint = "/post.sh"
+	actionMetadata.Runs.PostIf = "failure()"
+
+	mockGit.On("GetActionMetadata", "/tmp/workspace/my-action").Return(actionMetadata, nil)
+	mockDocker.On("PullImage", mock.Anything, "my-action:latest").Return(nil)
+
+	// The pre entrypoint's state reaches the image's own entrypoint.
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return slices.Equal(config.Entrypoint, []string{"/pre.sh"})
+	})).Return("pre-container", nil).Once()
+	mockDocker.On("GetContainerLogs", mock.Anything, "pre-container").
+		Run(func(args mock.Arguments) {
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_STATE"), []byte("token=abc\n"), 0o600))
+		}).
+		Return(&ExecResult{}, nil).Once()
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.Entrypoint == nil && slices.Contains(config.Env, "STATE_token=abc")
+	})).Return("main-container", nil).Once()
+	mockDocker.On("GetContainerLogs", mock.Anything, "main-container").Return(&ExecResult{}, nil).Once()
+
+	for _, id := range []string{"pre-container", "main-container"} {
+		mockDocker.On("StartContainer", mock.Anything, id).Return(nil).Once()
+		mockDocker.On("WaitForContainer", mock.Anything, id).Return(0, nil).Once()
+		mockDocker.On("StopContainer", mock.Anything, id).Return(nil).Once()
+		mockDocker.On("RemoveContainer", mock.Anything, id).Return(nil).Once()
+	}
+
+	result, err := executor.Execute(t.Context(), step, runtime)
+
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	mockDocker.AssertExpectations(t)
+
+	// The post entrypoint is registered with its condition and the state.
+	require.Len(t, runtime.PostSteps, 1)
+	assert.Equal(t, "failure()", runtime.PostSteps[0].If)
+	assert.Equal(t, map[string]string{"token": "abc"}, runtime.PostSteps[0].State)
+}
+
 func TestActionStepExecutor_Execute_DockerAction(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	mockGit := NewMockGitRepo()
@@ -111,16 +166,20 @@ func TestActionStepExecutor_Execute_DockerAction(t *testing.T) {
 	step := CreateTestActionStep("docker-action", "Docker Action", "docker://alpine:latest", map[string]string{
 		"command": "echo hello",
 	})
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	mockDocker.On("PullImage", mock.Anything, "alpine:latest").Return(nil)
 	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
 		return config.Image == "alpine:latest" &&
 			config.WorkingDir == "/github/workspace" &&
-			len(config.Volumes) == 1 &&
-			config.Volumes[0].Source == "/tmp/workspace"
+			len(config.Volumes) == 2 &&
+			config.Volumes[0].Source == "/tmp/workspace" &&
+			config.Volumes[1].Target == "/github/env" &&
+			slices.Contains(config.Env, "GITHUB_PATH=/github/env/GITHUB_PATH")
 	})).Return("docker-container", nil)
 	mockDocker.On("StartContainer", mock.Anything, "docker-container").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "docker-container").Return(&ExecResult{}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "docker-container").Return(0, nil)
 	mockDocker.On("StopContainer", mock.Anything, "docker-container").Return(nil)
 	mockDocker.On("RemoveContainer", mock.Anything, "docker-container").Return(nil)
 
@@ -133,6 +192,31 @@ func TestActionStepExecutor_Execute_DockerAction(t *testing.T) {
 	mockDocker.AssertExpectations(t)
 }
 
+func TestActionStepExecutor_Execute_DockerActionFailure(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := &ActionStepExecutor{Docker: mockDocker}
+
+	step := CreateTestActionStep("lint", "Lint", "docker://hadolint/hadolint:latest", nil)
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+
+	mockDocker.On("PullImage", mock.Anything, "hadolint/hadolint:latest").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.Anything).Return("lint-container", nil)
+	mockDocker.On("StartContainer", mock.Anything, "lint-container").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "lint-container").Return(&ExecResult{Stdout: "Dockerfile:3 DL3008\n", Stderr: "1 issue\n"}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "lint-container").Return(1, nil)
+	mockDocker.On("StopContainer", mock.Anything, "lint-container").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "lint-container").Return(nil)
+
+	result, err := executor.Execute(t.Context(), step, runtime)
+
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Equal(t, "Dockerfile:3 DL3008\n", result.Stdout)
+	assert.Equal(t, "1 issue\n", result.Stderr)
+	mockDocker.AssertExpectations(t)
+}
+
 func TestActionStepExecutor_Execute_RepositoryAction(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	mockGit := NewMockGitRepo()
@@ -142,7 +226,7 @@ func TestActionStepExecutor_Execute_RepositoryAction(t *testing.T) {
 		"token": "github_pat_123",
 		"path":  "src/",
 	})
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	actionMetadata := CreateTestActionMetadata("node20", "", "dist/index.js")
 
@@ -153,9 +237,11 @@ func TestActionStepExecutor_Execute_RepositoryAction(t *testing.T) {
 			len(config.Cmd) == 2 &&
 			config.Cmd[0] == "node" &&
 			config.Cmd[1] == "dist/index.js" &&
-			len(config.Volumes) == 2 // workspace + action
+			len(config.Volumes) == 3 // workspace + action + file commands
 	})).Return("node-action-container", nil)
 	mockDocker.On("StartContainer", mock.Anything, "node-action-container").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "node-action-container").Return(&ExecResult{}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "node-action-container").Return(0, nil)
 	mockDocker.On("StopContainer", mock.Anything, "node-action-container").Return(nil)
 	mockDocker.On("RemoveContainer", mock.Anything, "node-action-container").Return(nil)
 
@@ -175,7 +261,7 @@ func TestActionStepExecutor_Execute_CompositeAction(t *testing.T) {
 	executor := &ActionStepExecutor{Docker: mockDocker, Git: mockGit}
 
 	step := CreateTestActionStep("composite-action", "Composite Action", "my-org/composite-action@v1", nil)
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	actionMetadata := &ActionMetadata{
 		Name:        "Composite Action",
@@ -192,16 +278,11 @@ func TestActionStepExecutor_Execute_CompositeAction(t *testing.T) {
 	mockGit.On("CloneAction", mock.Anything, "https://github.com/my-org/composite-action", "v1", mock.AnythingOfType("string")).Return(nil)
 	mockGit.On("GetActionMetadata", mock.AnythingOfType("string")).Return(actionMetadata, nil)
 
-	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil).Twice() // For both composite steps
-	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("composite-step-1", nil).Once()
-	mockDocker.On("StartContainer", mock.Anything, "composite-step-1").Return(nil).Once()
-	mockDocker.On("StopContainer", mock.Anything, "composite-step-1").Return(nil).Once()
-	mockDocker.On("RemoveContainer", mock.Anything, "composite-step-1").Return(nil).Once()
-
-	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("composite-step-2", nil).Once()
-	mockDocker.On("StartContainer", mock.Anything, "composite-step-2").Return(nil).Once()
-	mockDocker.On("StopContainer", mock.Anything, "composite-step-2").Return(nil).Once()
-	mockDocker.On("RemoveContainer", mock.Anything, "composite-step-2").Return(nil).Once()
+	// Both composite steps run in the job container, started by the first.
+	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil).Once()
+	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("job-container", nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, "job-container").Return(nil).Once()
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.AnythingOfType("*workflow.ExecConfig")).Return(&ExecResult{}, nil).Twice()
 
 	ctx := t.Context()
 	result, err := executor.Execute(ctx, step, runtime)
@@ -213,13 +294,44 @@ func TestActionStepExecutor_Execute_CompositeAction(t *testing.T) {
 	mockGit.AssertExpectations(t)
 }
 
+func TestActionStepExecutor_Execute_CompositeActionStepFailure(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	mockGit := NewMockGitRepo()
+	executor := &ActionStepExecutor{Docker: mockDocker, Git: mockGit}
+
+	step := CreateTestActionStep("composite-action", "Composite Action", "my-org/composite-action@v1", nil)
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Status: "running"}
+
+	actionMetadata := &ActionMetadata{
+		Runs: ActionRuns{
+			Using: "composite",
+			Steps: []Step{
+				{ID: "test", Name: "Test", Run: "exit 3"},
+				{ID: "after", Name: "After", Run: "echo never"},
+			},
+		},
+	}
+
+	mockGit.On("CloneAction", mock.Anything, "https://github.com/my-org/composite-action", "v1", mock.AnythingOfType("string")).Return(nil)
+	mockGit.On("GetActionMetadata", mock.AnythingOfType("string")).Return(actionMetadata, nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.Anything).Return(&ExecResult{ExitCode: 3}, nil).Once()
+
+	result, err := executor.Execute(t.Context(), step, runtime)
+
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, 3, result.ExitCode)
+	mockDocker.AssertExpectations(t)
+}
+
 func TestActionStepExecutor_Execute_UnsupportedActionFormat(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	mockGit := NewMockGitRepo()
 	executor := &ActionStepExecutor{Docker: mockDocker, Git: mockGit}
 
 	step := CreateTestActionStep("invalid-action", "Invalid Action", "invalid-format", nil)
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	ctx := t.Context()
 	result, err := executor.Execute(ctx, step, runtime)
@@ -234,7 +346,7 @@ func TestActionStepExecutor_executeNodeAction_DefaultMain(t *testing.T) {
 	executor := &ActionStepExecutor{Docker: mockDocker}
 
 	step := CreateTestActionStep("node-action", "Node Action", "my-org/node-action@v1", nil)
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	actionMetadata := &ActionMetadata{
 		Runs: ActionRuns{
@@ -250,6 +362,8 @@ func TestActionStepExecutor_executeNodeAction_DefaultMain(t *testing.T) {
 			config.Cmd[1] == "index.js" // Should default to index.js
 	})).Return("node-container", nil)
 	mockDocker.On("StartContainer", mock.Anything, "node-container").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "node-container").Return(&ExecResult{}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "node-container").Return(0, nil)
 	mockDocker.On("StopContainer", mock.Anything, "node-container").Return(nil)
 	mockDocker.On("RemoveContainer", mock.Anything, "node-container").Return(nil)
 
@@ -279,17 +393,22 @@ func TestActionStepExecutor_buildActionEnvironment(t *testing.T) {
 		},
 	}
 
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 	runtime.JobContext.Job.Env = map[string]string{
 		"JOB_VAR": "job_value",
 	}
 
-	env := executor.buildActionEnvironment(step, runtime)
+	runtime.WorkflowEnv = map[string]string{"WORKFLOW_VAR": "workflow_value"}
+	runtime.Context = &Context{GitHub: GitHubContext{Actor: "octocat"}}
 
+	env, err := executor.buildActionEnvironment(step, runtime)
+	require.NoError(t, err)
+
+	AssertEnvironmentContains(t, env, "WORKFLOW_VAR=workflow_value")
 	AssertEnvironmentContains(t, env, "JOB_VAR=job_value")
 	AssertEnvironmentContains(t, env, "STEP_VAR=step_value")
 	AssertEnvironmentContains(t, env, "GITHUB_WORKSPACE=/github/workspace")
-	AssertEnvironmentContains(t, env, "GITHUB_ACTOR=rehearse")
+	AssertEnvironmentContains(t, env, "GITHUB_ACTOR=octocat")
 	AssertEnvironmentContains(t, env, "RUNNER_OS=Linux")
 
 	// Note: Action inputs (INPUT_*) are added separately in the specific action execution methods
@@ -302,7 +421,7 @@ func TestActionStepExecutor_Execute_GitCloneFailure(t *testing.T) {
 	executor := &ActionStepExecutor{Docker: mockDocker, Git: mockGit}
 
 	step := CreateTestActionStep("failing-clone", "Failing Clone", "nonexistent/action@v1", nil)
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	mockGit.On("CloneAction", mock.Anything, "https://github.com/nonexistent/action", "v1", mock.AnythingOfType("string")).Return(assert.AnError)
 
@@ -322,7 +441,7 @@ func TestActionStepExecutor_Execute_ActionMetadataFailure(t *testing.T) {
 	executor := &ActionStepExecutor{Docker: mockDocker, Git: mockGit}
 
 	step := CreateTestActionStep("metadata-fail", "Metadata Fail", "valid/action@v1", nil)
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	mockGit.On("CloneAction", mock.Anything, "https://github.com/valid/action", "v1", mock.AnythingOfType("string")).Return(nil)
 	mockGit.On("GetActionMetadata", mock.AnythingOfType("string")).Return(nil, assert.AnError)
@@ -343,7 +462,7 @@ func TestActionStepExecutor_Execute_UnsupportedActionType(t *testing.T) {
 	executor := &ActionStepExecutor{Docker: mockDocker, Git: mockGit}
 
 	step := CreateTestActionStep("unsupported-type", "Unsupported Type", "./local-action", nil)
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	actionMetadata := &ActionMetadata{
 		Runs: ActionRuns{
@@ -369,7 +488,7 @@ func TestActionStepExecutor_Execute_DockerImagePullFailure(t *testing.T) {
 	executor := &ActionStepExecutor{Docker: mockDocker, Git: mockGit}
 
 	step := CreateTestActionStep("pull-fail", "Pull Fail", "docker://nonexistent:latest", nil)
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	mockDocker.On("PullImage", mock.Anything, "nonexistent:latest").Return(assert.AnError)
 
@@ -428,3 +547,27 @@ func TestActionStepExecutor_parseRepositoryReference(t *testing.T) {
 		})
 	}
 }
+
+func TestInputEnv(t *testing.T) {
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.Context = &Context{GitHub: GitHubContext{Ref: "refs/heads/main"}}
+
+	step := CreateTestActionStep("deploy", "Deploy", "./deploy", map[string]string{
+		"environment": "${{ env.TARGET }}",
+		"ref":         "${{ github.ref }}",
+		"dry-run":     "${{ github.ref != 'refs/heads/main' }}",
+	})
+	step.Env = map[string]string{"TARGET": "staging"}
+
+	env, err := inputEnv(step, runtime)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"INPUT_DRY_RUN=false",
+		"INPUT_ENVIRONMENT=staging",
+		"INPUT_REF=refs/heads/main",
+	}, env)
+
+	step.With["broken"] = "${{ github.ref"
+	_, err = inputEnv(step, runtime)
+	assert.ErrorContains(t, err, "evaluating with.broken")
+}
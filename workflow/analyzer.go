@@ -1,22 +1,34 @@
 package workflow
 
+import "github.com/telton/rehearse/internal/mask"
+
 // AnalysisResult holds the complete run analysis.
 type AnalysisResult struct {
 	WorkflowName string
 	Trigger      string
 	Context      *Context
 	Jobs         []JobResult
+
+	// MissingSecrets are the secrets the workflow references that the
+	// context lacks, which evaluate to null.
+	MissingSecrets []MissingSecret
 }
 
 // JobResult holds analysis for a single job.
 type JobResult struct {
+	ID         string // Key of the job in the workflow
 	Name       string
+	Matrix     map[string]any
 	RunsOn     string
 	Needs      []string
 	Condition  *ConditionResult
 	WouldRun   bool
 	SkipReason string
 	Steps      []StepResult
+
+	// PendingMatrix is set when the matrix could not be evaluated ahead of
+	// the run, as when it comes from the outputs of the jobs the job needs.
+	PendingMatrix bool
 }
 
 // StepResult holds analysis for a single step.
@@ -59,30 +71,86 @@ func (a *Analyzer) Analyze() *AnalysisResult {
 		Context:      a.ctx,
 	}
 
+	result.MissingSecrets = MissingSecrets(a.workflow.SecretRefs(), a.ctx.Secrets)
+
 	order := a.topologicalSort()
 
 	for _, jobName := range order {
 		job := a.workflow.Jobs[jobName]
-		jobResult := a.analyzeJob(jobName, job)
-		result.Jobs = append(result.Jobs, jobResult)
+		a.ctx.Needs = needsContext(job, a.ctx.Jobs)
 
 		status := "success"
-		if !jobResult.WouldRun {
-			status = "skipped"
+		for _, jobResult := range a.analyzeJobInstances(jobName, job) {
+			result.Jobs = append(result.Jobs, jobResult)
+			if !jobResult.WouldRun {
+				status = "skipped"
+			}
 		}
 		a.ctx.Jobs[jobName] = JobContext{Status: status}
 	}
+	a.ctx.Needs = make(map[string]JobContext)
 
 	return result
 }
 
+// analyzeJobInstances analyzes every instance of a job, one per matrix combination.
+func (a *Analyzer) analyzeJobInstances(name string, job Job) []JobResult {
+	if job.Strategy == nil || job.Strategy.Matrix == nil {
+		return []JobResult{a.analyzeJob(name, job)}
+	}
+
+	matrix := job.Strategy.Matrix
+	if matrix.Dynamic() {
+		evaluated, err := matrix.Evaluate(a.eval)
+		if err != nil {
+			result := a.analyzeJob(name, job)
+			result.PendingMatrix = true
+			return []JobResult{result}
+		}
+		matrix = evaluated
+	}
+
+	combos, err := matrix.Expand()
+	if err != nil {
+		return []JobResult{{
+			ID:         name,
+			Name:       name,
+			RunsOn:     job.RunsOn.String(),
+			Needs:      job.Needs.Jobs,
+			SkipReason: "invalid matrix: " + err.Error(),
+		}}
+	}
+
+	defer func() {
+		a.ctx.Matrix = make(map[string]any)
+	}()
+
+	results := make([]JobResult, 0, len(combos))
+	for _, combo := range combos {
+		a.ctx.Matrix = combo.Values
+
+		jobResult := a.analyzeJob(name, job)
+		jobResult.Name = combo.Name(name)
+		jobResult.Matrix = combo.Values
+		results = append(results, jobResult)
+	}
+
+	return results
+}
+
 func (a *Analyzer) analyzeJob(name string, job Job) JobResult {
 	result := JobResult{
+		ID:     name,
 		Name:   name,
 		RunsOn: job.RunsOn.String(),
 		Needs:  job.Needs.Jobs,
 	}
 
+	// Show the labels a matrix instance actually runs on.
+	if runsOn, err := job.RunsOn.evaluate(a.eval); err == nil {
+		result.RunsOn = runsOn.String()
+	}
+
 	needsSatisfied := true
 	for _, dep := range job.Needs.Jobs {
 		if jobCtx, ok := a.ctx.Jobs[dep]; ok {
@@ -100,14 +168,16 @@ func (a *Analyzer) analyzeJob(name string, job Job) JobResult {
 		if !condResult.Value {
 			result.WouldRun = false
 			result.SkipReason = "condition evaluated to false"
-		} else if needsSatisfied {
+		} else if needsSatisfied || usesStatusCheck(job.If) {
+			// Status check functions such as always() run regardless of needs.
 			result.WouldRun = true
+			result.SkipReason = ""
 		}
 	} else {
 		result.WouldRun = needsSatisfied
 	}
 
-	if !needsSatisfied && result.SkipReason == "" {
+	if !result.WouldRun && !needsSatisfied && result.SkipReason == "" {
 		result.SkipReason = "dependency not satisfied"
 	}
 
@@ -121,19 +191,11 @@ func (a *Analyzer) analyzeJob(name string, job Job) JobResult {
 
 func (a *Analyzer) analyzeStep(step Step) StepResult {
 	result := StepResult{
-		Name:    step.Name,
+		Name:    stepDisplayName(step),
 		Command: step.Run,
 		Action:  step.Uses,
 	}
 
-	if result.Name == "" {
-		if step.Run != "" {
-			result.Name = truncate(step.Run, 40)
-		} else if step.Uses != "" {
-			result.Name = step.Uses
-		}
-	}
-
 	if step.Uses != "" {
 		result.Type = "action"
 	} else {
@@ -151,29 +213,40 @@ func (a *Analyzer) analyzeStep(step Step) StepResult {
 	return result
 }
 
+// stepDisplayName returns the name of step, or for an unnamed step the first
+// line of its command or the action it uses.
+func stepDisplayName(step Step) string {
+	switch {
+	case step.Name != "":
+		return step.Name
+	case step.Run != "":
+		return truncate(step.Run, 40)
+	}
+	return step.Uses
+}
+
+// evaluateCondition evaluates expr, with the values in its trace, such as
+// secrets.TOKEN -> 'value', masked.
 func (a *Analyzer) evaluateCondition(expr string) *ConditionResult {
-	result, err := a.eval.Evaluate(expr)
+	result, err := a.eval.EvaluateCondition(expr)
 	if err != nil {
 		return &ConditionResult{
 			Expression: expr,
 			Value:      false,
-			Trace:      "error: " + err.Error(),
+			Trace:      mask.String("error: " + err.Error()),
 		}
 	}
 
-	boolVal := false
-	if b, ok := result.Value.(bool); ok {
-		boolVal = b
-	}
-
 	return &ConditionResult{
 		Expression: expr,
-		Value:      boolVal,
-		Trace:      result.Trace,
+		Value:      result.Value.(bool),
+		Trace:      mask.String(result.Trace),
 	}
 }
 
-// topologicalSort returns jobs in dependency order.
+// topologicalSort returns jobs in dependency order, keeping declaration order
+// among jobs that do not depend on each other. Cycles are broken arbitrarily;
+// Workflow.Validate reports them.
 func (a *Analyzer) topologicalSort() []string {
 	visited := make(map[string]bool)
 	order := []string{}
@@ -187,12 +260,15 @@ func (a *Analyzer) topologicalSort() []string {
 
 		job := a.workflow.Jobs[name]
 		for _, dep := range job.Needs.Jobs {
-			visit(dep)
+			// Unknown jobs are reported by Workflow.Validate.
+			if _, exists := a.workflow.Jobs[dep]; exists {
+				visit(dep)
+			}
 		}
 		order = append(order, name)
 	}
 
-	for name := range a.workflow.Jobs {
+	for _, name := range a.workflow.JobIDs() {
 		visit(name)
 	}
 
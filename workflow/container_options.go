@@ -0,0 +1,388 @@
+package workflow
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+
+	"github.com/telton/rehearse/internal/logger"
+)
+
+// Healthcheck describes how Docker checks that a container is healthy.
+type Healthcheck struct {
+	Test        []string // As in the Docker API: {"CMD-SHELL", command} or {"NONE"}
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+// ContainerOptions holds the settings given through a container's options:
+// field, which takes docker create flags.
+type ContainerOptions struct {
+	Env            []string
+	Volumes        []string
+	Mounts         []string // As given to --mount
+	Tmpfs          map[string]string
+	Ports          []string
+	NetworkAliases []string
+	User           string
+	WorkingDir     string
+	Entrypoint     string
+	Hostname       string
+	Privileged     bool
+	Init           bool
+	ExtraHosts     []string
+	CapAdd         []string
+	ShmSize        int64 // Bytes
+	Memory         int64 // Bytes
+	NanoCPUs       int64
+	Healthcheck    *Healthcheck
+}
+
+// valueOptions are the docker create flags taking a value that
+// parseContainerOptions supports.
+var valueOptions = []string{
+	"-e", "--env", "-v", "--volume", "--mount", "--tmpfs", "-p", "--publish", "--network-alias",
+	"-u", "--user", "-w", "--workdir", "--entrypoint", "-h", "--hostname", "--add-host", "--cap-add",
+	"--shm-size", "-m", "--memory", "--cpus",
+	"--health-cmd", "--health-interval", "--health-timeout", "--health-start-period", "--health-retries",
+}
+
+// parseContainerOptions parses the docker create flags of an options: field.
+// Only flags that make sense for a job or service container are supported.
+// Others, such as --rm or --network, are ignored with a warning, so that
+// workflows using them still run.
+func parseContainerOptions(options string) (*ContainerOptions, error) {
+	args, err := splitShellWords(options)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &ContainerOptions{}
+	for i := 0; i < len(args); i++ {
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		if !strings.HasPrefix(flag, "-") {
+			return nil, fmt.Errorf("unexpected argument %q", args[i])
+		}
+
+		switch {
+		case flag == "--privileged" || flag == "--init":
+			enabled := true
+			if hasValue {
+				if enabled, err = strconv.ParseBool(value); err != nil {
+					return nil, fmt.Errorf("%s: %w", flag, err)
+				}
+			}
+			if flag == "--init" {
+				opts.Init = enabled
+			} else {
+				opts.Privileged = enabled
+			}
+			continue
+		case flag == "--no-healthcheck":
+			opts.healthcheck().Test = []string{"NONE"}
+			continue
+		case !slices.Contains(valueOptions, flag):
+			// The value of a flag that is not known, if it has one, is
+			// the next argument, unless that is a flag itself.
+			if !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+			}
+			logger.Warn("Ignoring unsupported container option", "option", flag)
+			continue
+		}
+
+		if !hasValue {
+			i++
+			if i == len(args) {
+				return nil, fmt.Errorf("%s requires a value", flag)
+			}
+			value = args[i]
+		}
+
+		switch flag {
+		case "-e", "--env":
+			opts.Env = append(opts.Env, value)
+		case "-v", "--volume":
+			opts.Volumes = append(opts.Volumes, value)
+		case "--mount":
+			opts.Mounts = append(opts.Mounts, value)
+		case "--tmpfs":
+			if opts.Tmpfs == nil {
+				opts.Tmpfs = make(map[string]string)
+			}
+			path, mountOptions, _ := strings.Cut(value, ":")
+			opts.Tmpfs[path] = mountOptions
+		case "-p", "--publish":
+			opts.Ports = append(opts.Ports, value)
+		case "--network-alias":
+			opts.NetworkAliases = append(opts.NetworkAliases, value)
+		case "-u", "--user":
+			opts.User = value
+		case "-w", "--workdir":
+			opts.WorkingDir = value
+		case "--entrypoint":
+			opts.Entrypoint = value
+		case "-h", "--hostname":
+			opts.Hostname = value
+		case "--add-host":
+			opts.ExtraHosts = append(opts.ExtraHosts, value)
+		case "--cap-add":
+			opts.CapAdd = append(opts.CapAdd, value)
+		case "--shm-size":
+			err = parseBytesOption(flag, value, &opts.ShmSize)
+		case "-m", "--memory":
+			err = parseBytesOption(flag, value, &opts.Memory)
+		case "--cpus":
+			var cpus float64
+			if cpus, err = strconv.ParseFloat(value, 64); err != nil {
+				err = fmt.Errorf("%s: %w", flag, err)
+			}
+			opts.NanoCPUs = int64(cpus * 1e9)
+		case "--health-cmd":
+			opts.healthcheck().Test = []string{"CMD-SHELL", value}
+		case "--health-interval":
+			err = parseDurationOption(flag, value, &opts.healthcheck().Interval)
+		case "--health-timeout":
+			err = parseDurationOption(flag, value, &opts.healthcheck().Timeout)
+		case "--health-start-period":
+			err = parseDurationOption(flag, value, &opts.healthcheck().StartPeriod)
+		case "--health-retries":
+			opts.healthcheck().Retries, err = strconv.Atoi(value)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", flag, err)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return opts, nil
+}
+
+// healthcheck returns the options' healthcheck, creating it if needed.
+func (o *ContainerOptions) healthcheck() *Healthcheck {
+	if o.Healthcheck == nil {
+		o.Healthcheck = &Healthcheck{}
+	}
+	return o.Healthcheck
+}
+
+// parseBytesOption parses a size such as 512m or 2g, as docker takes them.
+func parseBytesOption(flag, value string, n *int64) error {
+	parsed, err := units.RAMInBytes(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", flag, err)
+	}
+	*n = parsed
+	return nil
+}
+
+func parseDurationOption(flag, value string, d *time.Duration) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", flag, err)
+	}
+	*d = parsed
+	return nil
+}
+
+// splitShellWords splits s into words as a POSIX shell would, honoring single
+// quotes, double quotes and backslash escapes.
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+
+	return words, nil
+}
+
+// containerConfigFor translates a container: or services: entry into the
+// configuration of a container created from it. Relative bind mount sources
+// are resolved against workingDir.
+func containerConfigFor(c *Container, workingDir string) (*ContainerConfig, error) {
+	config := &ContainerConfig{
+		Image: c.Image,
+		Ports: slices.Clone(c.Ports),
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(c.Env)) {
+		config.Env = append(config.Env, fmt.Sprintf("%s=%s", k, c.Env[k]))
+	}
+
+	opts, err := parseContainerOptions(c.Options)
+	if err != nil {
+		return nil, fmt.Errorf("parsing options: %w", err)
+	}
+
+	config.Env = append(config.Env, opts.Env...)
+	config.Ports = append(config.Ports, opts.Ports...)
+	config.NetworkAliases = opts.NetworkAliases
+	config.User = opts.User
+	config.WorkingDir = opts.WorkingDir
+	config.Hostname = opts.Hostname
+	config.Privileged = opts.Privileged
+	config.Init = opts.Init
+	config.ExtraHosts = opts.ExtraHosts
+	config.CapAdd = opts.CapAdd
+	config.Tmpfs = opts.Tmpfs
+	config.ShmSize = opts.ShmSize
+	config.Memory = opts.Memory
+	config.NanoCPUs = opts.NanoCPUs
+	config.Healthcheck = opts.Healthcheck
+	if opts.Entrypoint != "" {
+		config.Entrypoint = []string{opts.Entrypoint}
+	}
+
+	for _, spec := range slices.Concat(c.Volumes, opts.Volumes) {
+		volume, err := parseVolumeSpec(spec, workingDir)
+		if err != nil {
+			return nil, err
+		}
+		config.Volumes = append(config.Volumes, volume)
+	}
+
+	for _, spec := range opts.Mounts {
+		volume, err := parseMountSpec(spec, workingDir)
+		if err != nil {
+			return nil, err
+		}
+		config.Volumes = append(config.Volumes, volume)
+	}
+
+	return config, nil
+}
+
+// parseVolumeSpec parses a volume as given to docker run --volume: a target
+// alone for an anonymous volume, or source:target with an optional :ro or
+// :rw suffix. Sources that are paths are bind mounts, others named volumes.
+func parseVolumeSpec(spec, workingDir string) (VolumeMount, error) {
+	parts := strings.Split(spec, ":")
+
+	var volume VolumeMount
+	switch len(parts) {
+	case 1:
+		volume = VolumeMount{Target: parts[0], Type: "volume"}
+	case 2, 3:
+		volume = VolumeMount{Source: parts[0], Target: parts[1], Type: "volume"}
+		if len(parts) == 3 {
+			switch parts[2] {
+			case "ro":
+				volume.ReadOnly = true
+			case "rw":
+			default:
+				return VolumeMount{}, fmt.Errorf("invalid volume %q: unknown mode %q", spec, parts[2])
+			}
+		}
+	default:
+		return VolumeMount{}, fmt.Errorf("invalid volume %q", spec)
+	}
+
+	if volume.Source == "" && len(parts) > 1 || volume.Target == "" {
+		return VolumeMount{}, fmt.Errorf("invalid volume %q", spec)
+	}
+
+	if filepath.IsAbs(volume.Source) || strings.HasPrefix(volume.Source, ".") {
+		volume.Type = "bind"
+		if !filepath.IsAbs(volume.Source) {
+			volume.Source = filepath.Join(workingDir, volume.Source)
+		}
+	}
+
+	return volume, nil
+}
+
+// parseMountSpec parses a mount as given to docker run --mount: comma-separated
+// fields such as type=bind,source=./data,target=/data,readonly. Relative bind
+// mount sources are resolved against workingDir, as with volumes.
+func parseMountSpec(spec, workingDir string) (VolumeMount, error) {
+	volume := VolumeMount{Type: "volume"}
+	for _, field := range strings.Split(spec, ",") {
+		key, value, hasValue := strings.Cut(field, "=")
+		switch key {
+		case "type":
+			volume.Type = value
+		case "source", "src":
+			volume.Source = value
+		case "target", "destination", "dst":
+			volume.Target = value
+		case "readonly", "ro":
+			volume.ReadOnly = true
+			if hasValue {
+				var err error
+				if volume.ReadOnly, err = strconv.ParseBool(value); err != nil {
+					return VolumeMount{}, fmt.Errorf("invalid mount %q: %w", spec, err)
+				}
+			}
+		default:
+			return VolumeMount{}, fmt.Errorf("invalid mount %q: unsupported field %q", spec, key)
+		}
+	}
+
+	switch {
+	case volume.Target == "":
+		return VolumeMount{}, fmt.Errorf("invalid mount %q: no target", spec)
+	case volume.Type != "bind" && volume.Type != "volume" && volume.Type != "tmpfs":
+		return VolumeMount{}, fmt.Errorf("invalid mount %q: unsupported type %q", spec, volume.Type)
+	case volume.Type == "bind" && volume.Source == "":
+		return VolumeMount{}, fmt.Errorf("invalid mount %q: no source", spec)
+	}
+
+	if volume.Type == "bind" && !filepath.IsAbs(volume.Source) {
+		volume.Source = filepath.Join(workingDir, volume.Source)
+	}
+
+	return volume, nil
+}
@@ -0,0 +1,223 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+		wantErr  string
+	}{
+		{name: "empty", input: "", expected: nil},
+		{name: "plain words", input: "--cpus 2  --privileged", expected: []string{"--cpus", "2", "--privileged"}},
+		{name: "double quotes", input: `--health-cmd "pg_isready -U postgres"`, expected: []string{"--health-cmd", "pg_isready -U postgres"}},
+		{name: "single quotes", input: `--health-cmd='redis-cli ping'`, expected: []string{"--health-cmd=redis-cli ping"}},
+		{name: "escaped space", input: `-e GREETING=hello\ world`, expected: []string{"-e", "GREETING=hello world"}},
+		{name: "multiline", input: "--health-interval 10s\n--health-retries 5", expected: []string{"--health-interval", "10s", "--health-retries", "5"}},
+		{name: "unterminated quote", input: `--health-cmd "pg_isready`, wantErr: "unterminated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, err := splitShellWords(tt.input)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, words)
+		})
+	}
+}
+
+func TestParseContainerOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  string
+		expected *ContainerOptions
+		wantErr  string
+	}{
+		{
+			name:     "empty",
+			options:  "",
+			expected: &ContainerOptions{},
+		},
+		{
+			name:    "postgres healthcheck",
+			options: `--health-cmd pg_isready --health-interval 10s --health-timeout 5s --health-retries 5 --health-start-period=30s`,
+			expected: &ContainerOptions{Healthcheck: &Healthcheck{
+				Test:        []string{"CMD-SHELL", "pg_isready"},
+				Interval:    10 * time.Second,
+				Timeout:     5 * time.Second,
+				StartPeriod: 30 * time.Second,
+				Retries:     5,
+			}},
+		},
+		{
+			name:    "general options",
+			options: `--user 1001 -e DEBUG=1 --env=MODE=test -v cache:/cache -p 8080:80 --entrypoint /bin/sh --hostname db --privileged --add-host host.docker.internal:host-gateway --cap-add NET_ADMIN`,
+			expected: &ContainerOptions{
+				Env:        []string{"DEBUG=1", "MODE=test"},
+				Volumes:    []string{"cache:/cache"},
+				Ports:      []string{"8080:80"},
+				User:       "1001",
+				Entrypoint: "/bin/sh",
+				Hostname:   "db",
+				Privileged: true,
+				ExtraHosts: []string{"host.docker.internal:host-gateway"},
+				CapAdd:     []string{"NET_ADMIN"},
+			},
+		},
+		{
+			name:     "no healthcheck",
+			options:  "--no-healthcheck",
+			expected: &ContainerOptions{Healthcheck: &Healthcheck{Test: []string{"NONE"}}},
+		},
+		{
+			name:    "resources and mounts",
+			options: `--shm-size 2g --cpus=1.5 -m 512m --tmpfs /run:rw,size=64m --tmpfs /tmp --mount type=bind,src=./data,dst=/data,readonly --network-alias db -w /srv --init`,
+			expected: &ContainerOptions{
+				Mounts:         []string{"type=bind,src=./data,dst=/data,readonly"},
+				Tmpfs:          map[string]string{"/run": "rw,size=64m", "/tmp": ""},
+				NetworkAliases: []string{"db"},
+				WorkingDir:     "/srv",
+				Init:           true,
+				ShmSize:        2 << 30,
+				Memory:         512 << 20,
+				NanoCPUs:       1_500_000_000,
+			},
+		},
+		{
+			name:     "unsupported flags are ignored",
+			options:  "--rm --network host --health-cmd true --restart=always --label a=b --tty",
+			expected: &ContainerOptions{Healthcheck: &Healthcheck{Test: []string{"CMD-SHELL", "true"}}},
+		},
+		{name: "bad size", options: "--shm-size huge", wantErr: "--shm-size"},
+		{name: "bad cpus", options: "--cpus all", wantErr: "--cpus"},
+		{name: "missing value", options: "--health-cmd", wantErr: "--health-cmd requires a value"},
+		{name: "bad duration", options: "--health-interval soon", wantErr: "--health-interval"},
+		{name: "bad retries", options: "--health-retries many", wantErr: "--health-retries"},
+		{name: "stray argument", options: "postgres", wantErr: `unexpected argument "postgres"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseContainerOptions(tt.options)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, opts)
+		})
+	}
+}
+
+func TestParseVolumeSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected VolumeMount
+		wantErr  bool
+	}{
+		{spec: "/data", expected: VolumeMount{Target: "/data", Type: "volume"}},
+		{spec: "pgdata:/var/lib/postgresql/data", expected: VolumeMount{Source: "pgdata", Target: "/var/lib/postgresql/data", Type: "volume"}},
+		{spec: "/etc/certs:/certs:ro", expected: VolumeMount{Source: "/etc/certs", Target: "/certs", Type: "bind", ReadOnly: true}},
+		{spec: "./fixtures:/fixtures", expected: VolumeMount{Source: "/repo/fixtures", Target: "/fixtures", Type: "bind"}},
+		{spec: "a:/b:rx", wantErr: true},
+		{spec: ":/b", wantErr: true},
+		{spec: "a:b:c:d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			volume, err := parseVolumeSpec(tt.spec, "/repo")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, volume)
+		})
+	}
+}
+
+func TestParseMountSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected VolumeMount
+		wantErr  string
+	}{
+		{spec: "source=pgdata,target=/var/lib/postgresql/data", expected: VolumeMount{Source: "pgdata", Target: "/var/lib/postgresql/data", Type: "volume"}},
+		{spec: "type=bind,src=/etc/certs,dst=/certs,ro=true", expected: VolumeMount{Source: "/etc/certs", Target: "/certs", Type: "bind", ReadOnly: true}},
+		{spec: "type=bind,source=fixtures,destination=/fixtures", expected: VolumeMount{Source: "/repo/fixtures", Target: "/fixtures", Type: "bind"}},
+		{spec: "type=tmpfs,target=/cache", expected: VolumeMount{Target: "/cache", Type: "tmpfs"}},
+		{spec: "type=bind,target=/data", wantErr: "no source"},
+		{spec: "source=pgdata", wantErr: "no target"},
+		{spec: "type=npipe,target=/pipe", wantErr: `unsupported type "npipe"`},
+		{spec: "target=/data,volume-opt=size=1g", wantErr: `unsupported field "volume-opt"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			volume, err := parseMountSpec(tt.spec, "/repo")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, volume)
+		})
+	}
+}
+
+func TestContainerConfigFor(t *testing.T) {
+	var job Job
+	require.NoError(t, yaml.Unmarshal([]byte(`
+services:
+  postgres:
+    image: postgres:16
+    env:
+      POSTGRES_USER: rehearse
+      POSTGRES_PASSWORD: secret
+    ports:
+      - 5432:5432
+      - 9187
+    volumes:
+      - pgdata:/var/lib/postgresql/data
+    options: >-
+      --health-cmd "pg_isready -U rehearse"
+      --health-interval 1s
+      -e PGDATA=/var/lib/postgresql/data/pgdata
+    credentials:
+      username: bot
+      password: hunter2
+`), &job))
+
+	service := job.Services["postgres"]
+	require.NotNil(t, service)
+	assert.Equal(t, &Credentials{Username: "bot", Password: "hunter2"}, service.Credentials)
+
+	config, err := containerConfigFor(service, "/repo")
+	require.NoError(t, err)
+
+	assert.Equal(t, "postgres:16", config.Image)
+	assert.Equal(t, []string{
+		"POSTGRES_PASSWORD=secret",
+		"POSTGRES_USER=rehearse",
+		"PGDATA=/var/lib/postgresql/data/pgdata",
+	}, config.Env)
+	assert.Equal(t, []string{"5432:5432", "9187"}, config.Ports)
+	assert.Equal(t, []VolumeMount{{Source: "pgdata", Target: "/var/lib/postgresql/data", Type: "volume"}}, config.Volumes)
+	assert.Equal(t, &Healthcheck{Test: []string{"CMD-SHELL", "pg_isready -U rehearse"}, Interval: time.Second}, config.Healthcheck)
+
+	_, err = containerConfigFor(&Container{Image: "redis", Options: "--memory lots"}, "/repo")
+	assert.ErrorContains(t, err, "parsing options")
+}
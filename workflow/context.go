@@ -2,7 +2,12 @@ package workflow
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"runtime"
+	"slices"
+
+	"github.com/telton/rehearse/internal/mask"
 )
 
 // Context holds all of the context available during a workflow's execution.
@@ -10,9 +15,12 @@ type Context struct {
 	GitHub  GitHubContext
 	Env     map[string]string
 	Secrets map[string]string
+	Vars    map[string]string // Configuration variables, as set on a repository
 	Jobs    map[string]JobContext
+	Needs   map[string]JobContext // The jobs the current job needs
 	Steps   map[string]StepContext
 	Matrix  map[string]any
+	Job     JobContext // The job currently running
 }
 
 // GitHubContext mirrors the github.* context in Actions.
@@ -28,14 +36,15 @@ type GitHubContext struct {
 
 // JobContext holds info about completed jobs.
 type JobContext struct {
-	Status  string
+	Status  string // Result of a completed job: success, failure, cancelled or skipped
 	Outputs map[string]string
 }
 
 // StepContext holds info about completed steps.
 type StepContext struct {
-	Outcome string
-	Outputs map[string]string
+	Outcome    string // Result before continue-on-error is applied
+	Conclusion string // Result after continue-on-error is applied
+	Outputs    map[string]string
 }
 
 // Options for building a context.
@@ -44,6 +53,7 @@ type Options struct {
 	Ref          string
 	EventPayload map[string]any
 	Secrets      map[string]string
+	Vars         map[string]string
 }
 
 // NewContext creates a new Context from git info and options.
@@ -65,11 +75,23 @@ func NewContext(opts Options) (*Context, error) {
 		},
 		Env:     make(map[string]string),
 		Secrets: opts.Secrets,
+		Vars:    opts.Vars,
 		Jobs:    make(map[string]JobContext),
+		Needs:   make(map[string]JobContext),
 		Steps:   make(map[string]StepContext),
 		Matrix:  make(map[string]any),
 	}
 
+	// Secrets are masked wherever they would be printed, however they got
+	// into the output.
+	for _, secret := range opts.Secrets {
+		mask.Add(secret)
+	}
+
+	if ctx.Vars == nil {
+		ctx.Vars = make(map[string]string)
+	}
+
 	if ctx.GitHub.Ref == "" {
 		ctx.GitHub.Ref = gitInfo.Ref
 	}
@@ -90,6 +112,57 @@ func NewContext(opts Options) (*Context, error) {
 	return ctx, nil
 }
 
+// withJobStatus returns a shallow copy of the context for a job running with
+// the given status.
+func (c *Context) withJobStatus(status string) *Context {
+	clone := *c
+	clone.Job = JobContext{Status: status}
+	return &clone
+}
+
+// withMatrix returns a shallow copy of the context with the given matrix values.
+func (c *Context) withMatrix(matrix map[string]any) *Context {
+	clone := *c
+	clone.Matrix = matrix
+	if clone.Matrix == nil {
+		clone.Matrix = make(map[string]any)
+	}
+	return &clone
+}
+
+// withJobs returns a shallow copy of the context with the given jobs context.
+func (c *Context) withJobs(jobs map[string]JobContext) *Context {
+	clone := *c
+	clone.Jobs = jobs
+	return &clone
+}
+
+// withNeeds returns a shallow copy of the context with the given needs context.
+func (c *Context) withNeeds(needs map[string]JobContext) *Context {
+	clone := *c
+	clone.Needs = needs
+	return &clone
+}
+
+// needsContext returns the needs context of job: the result and outputs of
+// each job in jobs that it needs.
+func needsContext(job Job, jobs map[string]JobContext) map[string]JobContext {
+	needs := make(map[string]JobContext, len(job.Needs.Jobs))
+	for _, dep := range job.Needs.Jobs {
+		if needed, ok := jobs[dep]; ok {
+			needs[dep] = needed
+		}
+	}
+	return needs
+}
+
+// withEnv returns a shallow copy of the context with the given env context.
+func (c *Context) withEnv(env map[string]string) *Context {
+	clone := *c
+	clone.Env = env
+	return &clone
+}
+
 func defaultEventPayload(event string) map[string]any {
 	switch event {
 	case "push":
@@ -125,24 +198,43 @@ func (c *Context) Lookup(path string) (any, bool) {
 	case "github":
 		return c.lookupGitHub(parts[1:])
 	case "env":
+		if len(parts) == 1 {
+			return c.Env, true
+		}
 		if len(parts) == 2 {
 			v, ok := c.Env[parts[1]]
 			return v, ok
 		}
 	case "secrets":
+		if len(parts) == 1 {
+			return c.Secrets, true
+		}
 		if len(parts) == 2 {
 			v, ok := c.Secrets[parts[1]]
 			return v, ok
 		}
+	case "vars":
+		if len(parts) == 1 {
+			return c.Vars, true
+		}
+		if len(parts) == 2 {
+			v, ok := c.Vars[parts[1]]
+			return v, ok
+		}
+	case "job":
+		if len(parts) == 2 && parts[1] == "status" {
+			return c.Job.Status, true
+		}
 	case "jobs":
-		return c.lookupJobs(parts[1:])
+		return lookupJob(c.Jobs, parts[1:])
+	case "needs":
+		return lookupJob(c.Needs, parts[1:])
 	case "steps":
 		return c.lookupSteps(parts[1:])
 	case "matrix":
-		if len(parts) == 2 {
-			v, ok := c.Matrix[parts[1]]
-			return v, ok
-		}
+		return lookupMap(c.Matrix, parts[1:])
+	case "runner":
+		return lookupRunner(parts[1:])
 	}
 
 	return nil, false
@@ -150,7 +242,15 @@ func (c *Context) Lookup(path string) (any, bool) {
 
 func (c *Context) lookupGitHub(parts []string) (any, bool) {
 	if len(parts) == 0 {
-		return nil, false
+		return map[string]any{
+			"event_name": c.GitHub.EventName,
+			"ref":        c.GitHub.Ref,
+			"sha":        c.GitHub.SHA,
+			"actor":      c.GitHub.Actor,
+			"repository": c.GitHub.Repository,
+			"workspace":  c.GitHub.Workspace,
+			"event":      c.GitHub.Event,
+		}, true
 	}
 
 	switch parts[0] {
@@ -176,18 +276,46 @@ func (c *Context) lookupGitHub(parts []string) (any, bool) {
 	return nil, false
 }
 
-func (c *Context) lookupJobs(parts []string) (any, bool) {
-	if len(parts) > 2 {
+// lookupRunner retrieves a property of the runner. Jobs run in Linux
+// containers on the architecture of the Docker host.
+func lookupRunner(parts []string) (any, bool) {
+	if len(parts) != 1 {
+		return nil, false
+	}
+
+	switch parts[0] {
+	case "os":
+		return "Linux", true
+	case "arch":
+		switch runtime.GOARCH {
+		case "amd64":
+			return "X64", true
+		case "386":
+			return "X86", true
+		case "arm64":
+			return "ARM64", true
+		case "arm":
+			return "ARM", true
+		}
+	}
+
+	return nil, false
+}
+
+// lookupJob retrieves the result or an output of a job in jobs, as in
+// needs.build.result or needs.build.outputs.tag.
+func lookupJob(jobs map[string]JobContext, parts []string) (any, bool) {
+	if len(parts) < 2 {
 		return nil, false
 	}
 
-	job, ok := c.Jobs[parts[0]]
+	job, ok := jobs[parts[0]]
 	if !ok {
 		return nil, false
 	}
 
 	switch parts[1] {
-	case "status":
+	case "status", "result":
 		return job.Status, true
 	case "outputs":
 		if len(parts) == 3 {
@@ -212,6 +340,8 @@ func (c *Context) lookupSteps(parts []string) (any, bool) {
 	switch parts[1] {
 	case "outcome":
 		return step.Outcome, true
+	case "conclusion":
+		return step.Conclusion, true
 	case "outputs":
 		if len(parts) == 3 {
 			v, ok := step.Outputs[parts[2]]
@@ -223,21 +353,54 @@ func (c *Context) lookupSteps(parts []string) (any, bool) {
 }
 
 func lookupMap(m map[string]any, parts []string) (any, bool) {
+	return lookupValue(m, parts)
+}
+
+// lookupValue retrieves the value at parts within v. A * part selects every
+// element of an array or object, collecting what the remaining parts select
+// from each, as in github.event.pull_request.labels.*.name.
+func lookupValue(v any, parts []string) (any, bool) {
 	if len(parts) == 0 {
-		return m, true
+		return v, true
 	}
 
-	v, ok := m[parts[0]]
-	if !ok {
-		return nil, false
-	}
+	if parts[0] == "*" {
+		var elements []any
+		switch val := v.(type) {
+		case []any:
+			elements = val
+		case map[string]any:
+			for _, key := range slices.Sorted(maps.Keys(val)) {
+				elements = append(elements, val[key])
+			}
+		}
 
-	if len(parts) == 1 {
-		return v, true
+		// Nested filters flatten into one array, as on GitHub.
+		flatten := slices.Contains(parts[1:], "*")
+		filtered := []any{}
+		for _, element := range elements {
+			found, ok := lookupValue(element, parts[1:])
+			if !ok {
+				continue
+			}
+			if nested, isArray := found.([]any); isArray && flatten {
+				filtered = append(filtered, nested...)
+			} else {
+				filtered = append(filtered, found)
+			}
+		}
+		return filtered, true
 	}
 
-	if nested, ok := v.(map[string]any); ok {
-		return lookupMap(nested, parts[1:])
+	switch nested := v.(type) {
+	case map[string]any:
+		if val, ok := nested[parts[0]]; ok {
+			return lookupValue(val, parts[1:])
+		}
+	case map[string]string:
+		if val, ok := nested[parts[0]]; ok {
+			return lookupValue(val, parts[1:])
+		}
 	}
 
 	return nil, false
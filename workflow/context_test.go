@@ -103,6 +103,9 @@ func TestShellStepExecutor_buildEnvironment_Precedence(t *testing.T) {
 				JobContext: &ExecutionJobContext{
 					Job: &Job{Env: tt.jobEnv},
 				},
+				Context: &Context{
+					GitHub: GitHubContext{Actor: "octocat", Repository: "octo-org/hello-world"},
+				},
 			}
 
 			step := &Step{
@@ -111,7 +114,8 @@ func TestShellStepExecutor_buildEnvironment_Precedence(t *testing.T) {
 				Env:  tt.stepEnv,
 			}
 
-			env := executor.buildEnvironment(step, runtime)
+			env, err := executor.buildEnvironment(step, runtime)
+			require.NoError(t, err)
 
 			envMap := make(map[string]string)
 			for _, envVar := range env {
@@ -131,8 +135,8 @@ func TestShellStepExecutor_buildEnvironment_Precedence(t *testing.T) {
 
 			expectedDefaults := map[string]string{
 				"GITHUB_WORKSPACE":  "/github/workspace",
-				"GITHUB_ACTOR":      "rehearse",
-				"GITHUB_REPOSITORY": "local/repo",
+				"GITHUB_ACTOR":      "octocat",
+				"GITHUB_REPOSITORY": "octo-org/hello-world",
 				"RUNNER_OS":         "Linux",
 				"RUNNER_ARCH":       "X64",
 			}
@@ -343,3 +347,60 @@ func splitEnvVar(envVar string) []string {
 	}
 	return []string{envVar, ""}
 }
+
+func TestContext_Lookup_Jobs(t *testing.T) {
+	build := JobContext{Status: "success", Outputs: map[string]string{"tag": "v1.2.3"}}
+	ctx := &Context{
+		Jobs:  map[string]JobContext{"build": build, "lint": {Status: "failure"}},
+		Needs: map[string]JobContext{"build": build},
+	}
+
+	tests := []struct {
+		path     string
+		expected any
+		found    bool
+	}{
+		{path: "needs.build.result", expected: "success", found: true},
+		{path: "needs.build.outputs.tag", expected: "v1.2.3", found: true},
+		{path: "needs.build.outputs.missing", expected: "", found: false},
+		{path: "needs.lint.result", found: false}, // Not a dependency
+		{path: "needs.build", found: false},
+		{path: "jobs.lint.status", expected: "failure", found: true},
+		{path: "jobs.build.result", expected: "success", found: true},
+		{path: "jobs.build.outputs.tag", expected: "v1.2.3", found: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, found := ctx.Lookup(tt.path)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestNeedsContext(t *testing.T) {
+	jobs := map[string]JobContext{
+		"build": {Status: "success", Outputs: map[string]string{"tag": "v1"}},
+		"lint":  {Status: "failure"},
+	}
+
+	needs := needsContext(Job{Needs: Needs{Jobs: []string{"build", "test"}}}, jobs)
+
+	assert.Equal(t, map[string]JobContext{"build": jobs["build"]}, needs)
+}
+
+func TestContext_Lookup_Vars(t *testing.T) {
+	ctx := &Context{Vars: map[string]string{"REGION": "eu-west-1"}}
+
+	value, found := ctx.Lookup("vars.REGION")
+	assert.True(t, found)
+	assert.Equal(t, "eu-west-1", value)
+
+	_, found = ctx.Lookup("vars.MISSING")
+	assert.False(t, found)
+
+	result, err := NewEvaluator(ctx).Interpolate("deploy to ${{ vars.REGION }}")
+	require.NoError(t, err)
+	assert.Equal(t, "deploy to eu-west-1", result)
+}
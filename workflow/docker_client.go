@@ -1,19 +1,36 @@
 package workflow
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/docker/go-connections/nat"
+	"github.com/moby/moby/api/pkg/stdcopy"
 	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/api/types/mount"
 	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/api/types/registry"
 	"github.com/moby/moby/client"
+
+	"github.com/telton/rehearse/internal/logger"
 )
 
 // RealDockerClient implements DockerClient using the actual Docker SDK.
 type RealDockerClient struct {
 	client *client.Client
+
+	mu    sync.Mutex
+	auths map[string]registry.AuthConfig // Registry host -> credentials
 }
 
 // NewDockerClient creates a new Docker client.
@@ -28,28 +45,69 @@ func NewDockerClient() (DockerClient, error) {
 
 // CreateContainer creates a new Docker container.
 func (d *RealDockerClient) CreateContainer(ctx context.Context, config *ContainerConfig) (string, error) {
+	exposedPorts, portBindings, err := portBindings(config.Ports)
+	if err != nil {
+		return "", err
+	}
+
 	containerConfig := &container.Config{
-		Image:      config.Image,
-		Cmd:        config.Cmd,
-		Env:        config.Env,
-		WorkingDir: config.WorkingDir,
+		Image:        config.Image,
+		Cmd:          config.Cmd,
+		Env:          config.Env,
+		WorkingDir:   config.WorkingDir,
+		User:         config.User,
+		Entrypoint:   config.Entrypoint,
+		Hostname:     config.Hostname,
+		ExposedPorts: exposedPorts,
+	}
+
+	if hc := config.Healthcheck; hc != nil {
+		containerConfig.Healthcheck = &container.HealthConfig{
+			Test:        hc.Test,
+			Interval:    hc.Interval,
+			Timeout:     hc.Timeout,
+			StartPeriod: hc.StartPeriod,
+			Retries:     hc.Retries,
+		}
 	}
 
 	var mounts []mount.Mount
 	for _, vol := range config.Volumes {
 		mounts = append(mounts, mount.Mount{
-			Type:   mount.Type(vol.Type),
-			Source: vol.Source,
-			Target: vol.Target,
+			Type:     mount.Type(vol.Type),
+			Source:   vol.Source,
+			Target:   vol.Target,
+			ReadOnly: vol.ReadOnly,
 		})
 	}
 
+	// Containers are removed explicitly, so that a kept job container
+	// survives being stopped.
 	hostConfig := &container.HostConfig{
-		Mounts:     mounts,
-		AutoRemove: true,
+		Mounts:       mounts,
+		PortBindings: portBindings,
+		Privileged:   config.Privileged,
+		ExtraHosts:   config.ExtraHosts,
+		CapAdd:       config.CapAdd,
+		NetworkMode:  container.NetworkMode(config.NetworkMode),
+		Tmpfs:        config.Tmpfs,
+		ShmSize:      config.ShmSize,
+		Resources: container.Resources{
+			Memory:   config.Memory,
+			NanoCPUs: config.NanoCPUs,
+		},
+	}
+	if config.Init {
+		hostConfig.Init = &config.Init
 	}
 
 	networkConfig := &network.NetworkingConfig{}
+	if len(config.Networks) > 0 {
+		networkConfig.EndpointsConfig = make(map[string]*network.EndpointSettings)
+		for _, name := range config.Networks {
+			networkConfig.EndpointsConfig[name] = &network.EndpointSettings{Aliases: config.NetworkAliases}
+		}
+	}
 
 	createOptions := client.ContainerCreateOptions{
 		Config:           containerConfig,
@@ -66,6 +124,44 @@ func (d *RealDockerClient) CreateContainer(ctx context.Context, config *Containe
 	return resp.ID, nil
 }
 
+// portBindings translates ports given as to docker run --publish, in the form
+// [[hostIP:]hostPort:]containerPort[/protocol], into the ports a container
+// exposes and their bindings on the host. Ports without a host port are
+// bound to a random one.
+func portBindings(ports []string) (network.PortSet, network.PortMap, error) {
+	if len(ports) == 0 {
+		return nil, nil, nil
+	}
+
+	exposed := make(network.PortSet)
+	bindings := make(network.PortMap)
+	for _, spec := range ports {
+		mappings, err := nat.ParsePortSpec(spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid port %q: %w", spec, err)
+		}
+
+		for _, mapping := range mappings {
+			port, err := network.ParsePort(string(mapping.Port))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid port %q: %w", spec, err)
+			}
+
+			binding := network.PortBinding{HostPort: mapping.Binding.HostPort}
+			if mapping.Binding.HostIP != "" {
+				if binding.HostIP, err = netip.ParseAddr(mapping.Binding.HostIP); err != nil {
+					return nil, nil, fmt.Errorf("invalid port %q: %w", spec, err)
+				}
+			}
+
+			exposed[port] = struct{}{}
+			bindings[port] = append(bindings[port], binding)
+		}
+	}
+
+	return exposed, bindings, nil
+}
+
 // StartContainer starts a Docker container.
 func (d *RealDockerClient) StartContainer(ctx context.Context, containerID string) error {
 	startOptions := client.ContainerStartOptions{}
@@ -73,9 +169,105 @@ func (d *RealDockerClient) StartContainer(ctx context.Context, containerID strin
 	return err
 }
 
-// ExecInContainer executes a command inside a container.
-func (d *RealDockerClient) ExecInContainer(ctx context.Context, containerID string, cmd []string) (*ExecResult, error) {
-	return nil, ErrNotImplemented
+// execMarkerEnv is set, to a value unique to each exec, in the environment of
+// the commands ExecInContainer runs. The processes they start inherit it, which
+// is how they are found when the exec has to be killed.
+const execMarkerEnv = "REHEARSE_EXEC"
+
+// killExecScript kills the processes of a container whose environment holds
+// the exec marker given as $1.
+const killExecScript = `for environ in /proc/[0-9]*/environ; do
+	if grep -qF "$1" "$environ" 2>/dev/null; then
+		pid=${environ#/proc/}
+		kill -KILL "${pid%/environ}" 2>/dev/null
+	fi
+done
+exit 0`
+
+// killExecTimeout bounds how long killing a cancelled exec may take.
+const killExecTimeout = 10 * time.Second
+
+// ExecInContainer executes a command inside a running container, waits for it
+// to exit and returns its exit code and output. If ctx is cancelled first, the
+// command and the processes it started are killed.
+func (d *RealDockerClient) ExecInContainer(ctx context.Context, containerID string, config *ExecConfig) (*ExecResult, error) {
+	marker := execMarkerEnv + "=" + rand.Text()
+	created, err := d.client.ExecCreate(ctx, containerID, client.ExecCreateOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          config.Cmd,
+		Env:          append(slices.Clip(config.Env), marker),
+		WorkingDir:   config.WorkingDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating exec: %w", err)
+	}
+
+	attached, err := d.client.ExecAttach(ctx, created.ID, client.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("attaching to exec: %w", err)
+	}
+	defer attached.Close()
+
+	// Output is collected for the result and, when asked for, streamed as
+	// it arrives.
+	var stdout, stderr bytes.Buffer
+	stdoutWriter, stderrWriter := io.Writer(&stdout), io.Writer(&stderr)
+	if config.Stdout != nil {
+		stdoutWriter = io.MultiWriter(&stdout, config.Stdout)
+	}
+	if config.Stderr != nil {
+		stderrWriter = io.MultiWriter(&stderr, config.Stderr)
+	}
+
+	copied := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdoutWriter, stderrWriter, attached.Reader)
+		copied <- err
+	}()
+
+	select {
+	case err := <-copied:
+		if err != nil {
+			return nil, fmt.Errorf("reading exec output: %w", err)
+		}
+	case <-ctx.Done():
+		// Closing the connection unblocks the copy, but the daemon leaves
+		// the process running, so it is killed from inside the container.
+		attached.Close()
+		<-copied
+		d.killExec(context.WithoutCancel(ctx), containerID, marker)
+		return nil, ctx.Err()
+	}
+
+	inspect, err := d.client.ExecInspect(ctx, created.ID, client.ExecInspectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("inspecting exec: %w", err)
+	}
+
+	return &ExecResult{
+		ExitCode: inspect.ExitCode,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	}, nil
+}
+
+// killExec kills the processes of the exec whose environment holds marker.
+// Failing to is only worth a warning: they are killed at the latest when the
+// container is removed.
+func (d *RealDockerClient) killExec(ctx context.Context, containerID, marker string) {
+	ctx, cancel := context.WithTimeout(ctx, killExecTimeout)
+	defer cancel()
+
+	result, err := d.ExecInContainer(ctx, containerID, &ExecConfig{
+		Cmd: []string{"sh", "-c", killExecScript, "sh", marker},
+	})
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	if err != nil {
+		logger.Warn("Failed to kill cancelled command", "container_id", containerID, "error", err)
+	}
 }
 
 // StopContainer stops a Docker container.
@@ -97,9 +289,117 @@ func (d *RealDockerClient) RemoveContainer(ctx context.Context, containerID stri
 	return err
 }
 
+// CreateNetwork creates a user-defined bridge network, on which containers
+// can reach each other by name.
+func (d *RealDockerClient) CreateNetwork(ctx context.Context, name string) (string, error) {
+	resp, err := d.client.NetworkCreate(ctx, name, client.NetworkCreateOptions{Driver: "bridge"})
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// RemoveNetwork removes a Docker network.
+func (d *RealDockerClient) RemoveNetwork(ctx context.Context, networkID string) error {
+	_, err := d.client.NetworkRemove(ctx, networkID, client.NetworkRemoveOptions{})
+	return err
+}
+
+// ContainerHealth returns the health status of a container: starting, healthy
+// or unhealthy, or none if it has no healthcheck.
+func (d *RealDockerClient) ContainerHealth(ctx context.Context, containerID string) (string, error) {
+	resp, err := d.client.ContainerInspect(ctx, containerID, client.ContainerInspectOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	state := resp.Container.State
+	if state != nil && !state.Running {
+		return "", fmt.Errorf("container exited with code %d", state.ExitCode)
+	}
+	if state == nil || state.Health == nil {
+		return string(container.NoHealthcheck), nil
+	}
+
+	return string(state.Health.Status), nil
+}
+
+// RegistryLogin logs in to the registry that image is pulled from. Later
+// pulls of images from that registry use the credentials.
+func (d *RealDockerClient) RegistryLogin(ctx context.Context, image string, credentials *Credentials) error {
+	host := registryHost(image)
+	auth := registry.AuthConfig{
+		Username:      credentials.Username,
+		Password:      credentials.Password,
+		ServerAddress: host,
+	}
+	if host == defaultRegistry {
+		auth.ServerAddress = "https://index.docker.io/v1/"
+	}
+
+	result, err := d.client.RegistryLogin(ctx, client.RegistryLoginOptions{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		ServerAddress: auth.ServerAddress,
+	})
+	if err != nil {
+		return fmt.Errorf("logging in to %s: %w", host, err)
+	}
+	if result.Auth.IdentityToken != "" {
+		auth.Password = ""
+		auth.IdentityToken = result.Auth.IdentityToken
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.auths == nil {
+		d.auths = make(map[string]registry.AuthConfig)
+	}
+	d.auths[host] = auth
+
+	return nil
+}
+
+// defaultRegistry is the registry of images whose name has no registry host.
+const defaultRegistry = "docker.io"
+
+// registryHost returns the host of the registry an image is pulled from. As
+// in Docker, the first component of the name is a host only if it looks like
+// one; "postgres" and "bitnami/redis" come from Docker Hub.
+func registryHost(image string) string {
+	first, _, ok := strings.Cut(image, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return defaultRegistry
+}
+
+// registryAuth returns the encoded credentials to pull image with, or an
+// empty string if no login was made to its registry.
+func (d *RealDockerClient) registryAuth(image string) (string, error) {
+	d.mu.Lock()
+	auth, ok := d.auths[registryHost(image)]
+	d.mu.Unlock()
+	if !ok {
+		return "", nil
+	}
+
+	data, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
 // PullImage pulls a Docker image.
 func (d *RealDockerClient) PullImage(ctx context.Context, imageName string) error {
-	pullOptions := client.ImagePullOptions{}
+	auth, err := d.registryAuth(imageName)
+	if err != nil {
+		return fmt.Errorf("encoding registry credentials: %w", err)
+	}
+
+	pullOptions := client.ImagePullOptions{RegistryAuth: auth}
 	reader, err := d.client.ImagePull(ctx, imageName, pullOptions)
 	if err != nil {
 		return err
@@ -113,30 +413,56 @@ func (d *RealDockerClient) PullImage(ctx context.Context, imageName string) erro
 
 // WaitForContainer waits for a container to finish and returns its exit code.
 func (d *RealDockerClient) WaitForContainer(ctx context.Context, containerID string) (int, error) {
-	return -1, ErrNotImplemented
+	wait := d.client.ContainerWait(ctx, containerID, client.ContainerWaitOptions{
+		Condition: container.WaitConditionNotRunning,
+	})
+
+	select {
+	case result := <-wait.Result:
+		if result.Error != nil && result.Error.Message != "" {
+			return int(result.StatusCode), fmt.Errorf("waiting for container: %s", result.Error.Message)
+		}
+		return int(result.StatusCode), nil
+	case err := <-wait.Error:
+		return -1, fmt.Errorf("waiting for container: %w", err)
+	}
 }
 
-// GetContainerLogs retrieves logs from a container.
-func (d *RealDockerClient) GetContainerLogs(ctx context.Context, containerID string) (string, error) {
+// GetContainerLogs follows the logs of a container until it exits, passing
+// them to stdout and stderr as they arrive when those are given, and returns
+// them.
+func (d *RealDockerClient) GetContainerLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) (*ExecResult, error) {
 	options := client.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
-		Follow:     false,
+		Follow:     true,
 		Timestamps: false,
 	}
 
 	reader, err := d.client.ContainerLogs(ctx, containerID, options)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer reader.Close()
 
-	logs, err := io.ReadAll(reader)
-	if err != nil {
-		return "", err
+	var stdoutBuf, stderrBuf bytes.Buffer
+	stdoutWriter, stderrWriter := io.Writer(&stdoutBuf), io.Writer(&stderrBuf)
+	if stdout != nil {
+		stdoutWriter = io.MultiWriter(&stdoutBuf, stdout)
+	}
+	if stderr != nil {
+		stderrWriter = io.MultiWriter(&stderrBuf, stderr)
+	}
+
+	// Containers run without a TTY, so both streams are multiplexed.
+	if _, err := stdcopy.StdCopy(stdoutWriter, stderrWriter, reader); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
 	}
 
-	return string(logs), nil
+	return &ExecResult{Stdout: stdoutBuf.String(), Stderr: stderrBuf.String()}, nil
 }
 
 // Close closes the Docker client.
@@ -153,6 +479,3 @@ func (d *RealDockerClient) Ping(ctx context.Context) (string, error) {
 	}
 	return ping.APIVersion, nil
 }
-
-// ErrNotImplemented is returned for methods not yet implemented in the Moby client
-var ErrNotImplemented = fmt.Errorf("method not implemented in current Moby client version")
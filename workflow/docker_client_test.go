@@ -0,0 +1,321 @@
+package workflow
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/registry"
+	"github.com/moby/moby/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// muxFrame encodes payload as a frame of Docker's multiplexed stream format.
+func muxFrame(stream byte, payload string) []byte {
+	frame := make([]byte, 8, 8+len(payload))
+	frame[0] = stream
+	binary.BigEndian.PutUint32(frame[4:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// newFakeDockerClient returns a client talking to a fake Docker daemon that
+// serves the given handlers, keyed by method and path without API version.
+func newFakeDockerClient(t *testing.T, handlers map[string]http.HandlerFunc) *RealDockerClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if strings.HasPrefix(path, "/v") {
+			path = path[strings.Index(path[1:], "/")+1:]
+		}
+
+		handler, ok := handlers[r.Method+" "+path]
+		if !ok {
+			http.Error(w, "unexpected request "+r.Method+" "+path, http.StatusNotFound)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	cli, err := client.New(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithAPIVersion("1.47"))
+	require.NoError(t, err)
+	t.Cleanup(func() { cli.Close() })
+
+	return &RealDockerClient{client: cli}
+}
+
+func TestRealDockerClient_WaitForContainer(t *testing.T) {
+	docker := newFakeDockerClient(t, map[string]http.HandlerFunc{
+		"POST /containers/abc/wait": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"StatusCode": 3}`)
+		},
+	})
+
+	exitCode, err := docker.WaitForContainer(t.Context(), "abc")
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, exitCode)
+}
+
+func TestRealDockerClient_GetContainerLogs(t *testing.T) {
+	docker := newFakeDockerClient(t, map[string]http.HandlerFunc{
+		"GET /containers/abc/logs": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "1", r.URL.Query().Get("follow"))
+			w.Write(muxFrame(1, "building\n"))
+			w.Write(muxFrame(2, "warning: deprecated\n"))
+			w.Write(muxFrame(1, "done\n"))
+		},
+	})
+
+	var streamed strings.Builder
+	logs, err := docker.GetContainerLogs(t.Context(), "abc", &streamed, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "building\ndone\n", logs.Stdout)
+	assert.Equal(t, "warning: deprecated\n", logs.Stderr)
+	assert.Equal(t, "building\ndone\n", streamed.String())
+}
+
+func TestRealDockerClient_ExecInContainer(t *testing.T) {
+	docker := newFakeDockerClient(t, map[string]http.HandlerFunc{
+		"POST /containers/abc/exec": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"Id": "exec-1"}`)
+		},
+		"POST /exec/exec-1/start": func(w http.ResponseWriter, r *http.Request) {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			buf.WriteString("HTTP/1.1 101 UPGRADED\r\n" +
+				"Content-Type: application/vnd.docker.multiplexed-stream\r\n" +
+				"Connection: Upgrade\r\n" +
+				"Upgrade: tcp\r\n\r\n")
+			buf.Write(muxFrame(1, "hello\n"))
+			buf.Write(muxFrame(2, "oops\n"))
+			buf.Flush()
+		},
+		"GET /exec/exec-1/json": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"ID": "exec-1", "ContainerID": "abc", "Running": false, "ExitCode": 2}`)
+		},
+	})
+
+	var streamedStdout, streamedStderr strings.Builder
+	result, err := docker.ExecInContainer(t.Context(), "abc", &ExecConfig{
+		Cmd:    []string{"sh", "-c", "echo hello; echo oops >&2; exit 2"},
+		Stdout: &streamedStdout,
+		Stderr: &streamedStderr,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.ExitCode)
+	assert.Equal(t, "hello\n", result.Stdout)
+	assert.Equal(t, "oops\n", result.Stderr)
+	assert.Equal(t, "hello\n", streamedStdout.String())
+	assert.Equal(t, "oops\n", streamedStderr.String())
+}
+
+func TestRealDockerClient_ExecInContainer_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var created []container.ExecCreateRequest
+	docker := newFakeDockerClient(t, map[string]http.HandlerFunc{
+		"POST /containers/abc/exec": func(w http.ResponseWriter, r *http.Request) {
+			var req container.ExecCreateRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			created = append(created, req)
+			fmt.Fprintf(w, `{"Id": "exec-%d"}`, len(created))
+		},
+		// The command runs until the client goes away.
+		"POST /exec/exec-1/start": func(w http.ResponseWriter, r *http.Request) {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			buf.WriteString("HTTP/1.1 101 UPGRADED\r\n" +
+				"Content-Type: application/vnd.docker.multiplexed-stream\r\n" +
+				"Connection: Upgrade\r\n" +
+				"Upgrade: tcp\r\n\r\n")
+			buf.Write(muxFrame(1, "started\n"))
+			buf.Flush()
+			cancel()
+			io.Copy(io.Discard, conn)
+		},
+		"POST /exec/exec-2/start": func(w http.ResponseWriter, r *http.Request) {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			buf.WriteString("HTTP/1.1 101 UPGRADED\r\n" +
+				"Content-Type: application/vnd.docker.multiplexed-stream\r\n" +
+				"Connection: Upgrade\r\n" +
+				"Upgrade: tcp\r\n\r\n")
+			buf.Flush()
+		},
+		"GET /exec/exec-2/json": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"ID": "exec-2", "ContainerID": "abc", "Running": false, "ExitCode": 0}`)
+		},
+	})
+
+	_, err := docker.ExecInContainer(ctx, "abc", &ExecConfig{
+		Cmd: []string{"sleep", "infinity"},
+		Env: []string{"CI=true"},
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// The command is killed by a second exec, which looks for its
+	// processes by the marker in their environment.
+	require.Len(t, created, 2)
+	require.Len(t, created[0].Env, 2)
+	assert.Equal(t, "CI=true", created[0].Env[0])
+	marker := created[0].Env[1]
+	assert.True(t, strings.HasPrefix(marker, execMarkerEnv+"="), marker)
+	assert.Equal(t, []string{"sh", "-c", killExecScript, "sh", marker}, created[1].Cmd)
+}
+
+func TestPortBindings(t *testing.T) {
+	tests := []struct {
+		name     string
+		ports    []string
+		expected map[string][]string // Container port -> host IP:port bindings
+		wantErr  bool
+	}{
+		{name: "none", ports: nil, expected: nil},
+		{name: "same port", ports: []string{"5432:5432"}, expected: map[string][]string{"5432/tcp": {":5432"}}},
+		{name: "random host port", ports: []string{"6379"}, expected: map[string][]string{"6379/tcp": {":"}}},
+		{name: "host ip and protocol", ports: []string{"127.0.0.1:5353:53/udp"}, expected: map[string][]string{"53/udp": {"127.0.0.1:5353"}}},
+		{name: "ipv6 host ip", ports: []string{"[::1]:5432:5432"}, expected: map[string][]string{"5432/tcp": {"::1:5432"}}},
+		{name: "port range", ports: []string{"8000-8001:9000-9001"}, expected: map[string][]string{"9000/tcp": {":8000"}, "9001/tcp": {":8001"}}},
+		{name: "port bound twice", ports: []string{"8080:80", "8081:80"}, expected: map[string][]string{"80/tcp": {":8080", ":8081"}}},
+		{name: "invalid port", ports: []string{"http"}, wantErr: true},
+		{name: "invalid host ip", ports: []string{"localhost:80:80"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exposed, bindings, err := portBindings(tt.ports)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.expected == nil {
+				assert.Nil(t, exposed)
+				assert.Nil(t, bindings)
+				return
+			}
+
+			actual := make(map[string][]string)
+			for port, portBindings := range bindings {
+				assert.Contains(t, exposed, port)
+				for _, b := range portBindings {
+					hostIP := ""
+					if b.HostIP.IsValid() {
+						hostIP = b.HostIP.String()
+					}
+					actual[port.String()] = append(actual[port.String()], hostIP+":"+b.HostPort)
+				}
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestRealDockerClient_ContainerHealth(t *testing.T) {
+	docker := newFakeDockerClient(t, map[string]http.HandlerFunc{
+		"GET /containers/healthy/json": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"Id": "healthy", "State": {"Running": true, "Health": {"Status": "healthy"}}}`)
+		},
+		"GET /containers/plain/json": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"Id": "plain", "State": {"Running": true}}`)
+		},
+		"GET /containers/crashed/json": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"Id": "crashed", "State": {"Running": false, "ExitCode": 1, "Health": {"Status": "starting"}}}`)
+		},
+	})
+
+	status, err := docker.ContainerHealth(t.Context(), "healthy")
+	require.NoError(t, err)
+	assert.Equal(t, "healthy", status)
+
+	status, err = docker.ContainerHealth(t.Context(), "plain")
+	require.NoError(t, err)
+	assert.Equal(t, "none", status)
+
+	_, err = docker.ContainerHealth(t.Context(), "crashed")
+	assert.ErrorContains(t, err, "exited with code 1")
+}
+
+func TestRegistryHost(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{image: "postgres", expected: "docker.io"},
+		{image: "postgres:16", expected: "docker.io"},
+		{image: "bitnami/redis:7", expected: "docker.io"},
+		{image: "ghcr.io/acme/builder:1", expected: "ghcr.io"},
+		{image: "registry.local:5000/app", expected: "registry.local:5000"},
+		{image: "localhost/app", expected: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.expected, registryHost(tt.image))
+		})
+	}
+}
+
+func TestRealDockerClient_RegistryLogin(t *testing.T) {
+	var loginRequest registry.AuthConfig
+	pullAuth := make(map[string]string) // Image -> X-Registry-Auth header
+	docker := newFakeDockerClient(t, map[string]http.HandlerFunc{
+		"POST /auth": func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&loginRequest))
+			fmt.Fprint(w, `{"Status": "Login Succeeded"}`)
+		},
+		"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+			pullAuth[r.URL.Query().Get("fromImage")] = r.Header.Get(registry.AuthHeader)
+			fmt.Fprint(w, `{"status": "Downloaded newer image"}`)
+		},
+	})
+
+	err := docker.RegistryLogin(t.Context(), "ghcr.io/acme/builder:1", &Credentials{Username: "octocat", Password: "hunter2"})
+	require.NoError(t, err)
+	assert.Equal(t, registry.AuthConfig{Username: "octocat", Password: "hunter2", ServerAddress: "ghcr.io"}, loginRequest)
+
+	require.NoError(t, docker.PullImage(t.Context(), "ghcr.io/acme/builder:1"))
+	require.NoError(t, docker.PullImage(t.Context(), "postgres:16"))
+
+	decoded, err := base64.URLEncoding.DecodeString(pullAuth["ghcr.io/acme/builder"])
+	require.NoError(t, err)
+	var auth registry.AuthConfig
+	require.NoError(t, json.Unmarshal(decoded, &auth))
+	assert.Equal(t, registry.AuthConfig{Username: "octocat", Password: "hunter2", ServerAddress: "ghcr.io"}, auth)
+
+	// Images from other registries are pulled anonymously.
+	assert.Empty(t, pullAuth["postgres"])
+}
+
+func TestRealDockerClient_RegistryLogin_Failure(t *testing.T) {
+	docker := newFakeDockerClient(t, map[string]http.HandlerFunc{
+		"POST /auth": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message": "unauthorized: incorrect username or password"}`, http.StatusUnauthorized)
+		},
+	})
+
+	err := docker.RegistryLogin(t.Context(), "acme/private", &Credentials{Username: "octocat", Password: "wrong"})
+
+	assert.ErrorContains(t, err, "logging in to docker.io")
+}
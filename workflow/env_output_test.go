@@ -1,6 +1,7 @@
 package workflow
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -19,6 +20,7 @@ func TestExecutor_processStepOutputFiles_GITHUB_ENV(t *testing.T) {
 		name        string
 		fileContent string
 		expected    map[string]string
+		errMsg      string
 	}{
 		{
 			name:        "single environment variable",
@@ -35,11 +37,11 @@ func TestExecutor_processStepOutputFiles_GITHUB_ENV(t *testing.T) {
 			},
 		},
 		{
-			name:        "empty lines and whitespace",
-			fileContent: "\nNODE_VERSION=18.20.0\n\n  BUILD_NUMBER=1234  \n\n",
+			name:        "empty lines skipped and whitespace kept",
+			fileContent: "\nNODE_VERSION=18.20.0\n\nBUILD_NUMBER= 1234 \n\n",
 			expected: map[string]string{
 				"NODE_VERSION": "18.20.0",
-				"BUILD_NUMBER": "1234",
+				"BUILD_NUMBER": " 1234 ",
 			},
 		},
 		{
@@ -57,12 +59,9 @@ func TestExecutor_processStepOutputFiles_GITHUB_ENV(t *testing.T) {
 			expected:    map[string]string{},
 		},
 		{
-			name:        "malformed lines ignored",
+			name:        "malformed line",
 			fileContent: "VALID_VAR=value\nINVALID_LINE\nANOTHER_VALID=test",
-			expected: map[string]string{
-				"VALID_VAR":     "value",
-				"ANOTHER_VALID": "test",
-			},
+			errMsg:      `GITHUB_ENV: line 2: invalid format "INVALID_LINE"`,
 		},
 	}
 
@@ -74,7 +73,11 @@ func TestExecutor_processStepOutputFiles_GITHUB_ENV(t *testing.T) {
 			err := os.WriteFile(envFile, []byte(tt.fileContent), 0600)
 			require.NoError(t, err)
 
-			err = executor.processStepOutputFiles("test-step")
+			err = executor.processStepOutputFiles(&Step{ID: "test-step"})
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
 			assert.NoError(t, err)
 
 			assert.Equal(t, tt.expected, executor.runtime.DynamicEnv)
@@ -134,9 +137,9 @@ func TestExecutor_processStepOutputFiles_GITHUB_OUTPUT(t *testing.T) {
 		{
 			name:        "whitespace handling",
 			stepID:      "whitespace",
-			fileContent: "\n  version=1.2.3  \n\n  status=success  \n",
+			fileContent: "\nversion= 1.2.3 \n\nstatus=success\n",
 			expected: map[string]string{
-				"version": "1.2.3",
+				"version": " 1.2.3 ",
 				"status":  "success",
 			},
 		},
@@ -150,7 +153,7 @@ func TestExecutor_processStepOutputFiles_GITHUB_OUTPUT(t *testing.T) {
 			err := os.WriteFile(outputFile, []byte(tt.fileContent), 0600)
 			require.NoError(t, err)
 
-			err = executor.processStepOutputFiles(tt.stepID)
+			err = executor.processStepOutputFiles(&Step{ID: tt.stepID})
 			assert.NoError(t, err)
 
 			if len(tt.expected) > 0 {
@@ -186,7 +189,7 @@ func TestExecutor_processStepOutputFiles_NullByteHandling(t *testing.T) {
 	err = os.WriteFile(outputFile, []byte(outputContent), 0600)
 	require.NoError(t, err)
 
-	err = executor.processStepOutputFiles("test")
+	err = executor.processStepOutputFiles(&Step{ID: "test"})
 	assert.NoError(t, err)
 
 	expectedEnv := map[string]string{
@@ -206,7 +209,7 @@ func TestExecutor_processStepOutputFiles_NoTempDir(t *testing.T) {
 	executor := NewExecutor(&Analyzer{}, NewMockDockerClient(), NewMockGitRepo())
 	executor.runtime.TempDir = ""
 
-	err := executor.processStepOutputFiles("test")
+	err := executor.processStepOutputFiles(&Step{ID: "test"})
 	assert.NoError(t, err)
 
 	assert.Empty(t, executor.runtime.DynamicEnv)
@@ -220,9 +223,69 @@ func TestExecutor_processStepOutputFiles_MissingFiles(t *testing.T) {
 
 	executor.runtime.TempDir = tempDir
 
-	err := executor.processStepOutputFiles("test")
+	err := executor.processStepOutputFiles(&Step{ID: "test"})
 	assert.NoError(t, err)
 
 	assert.Empty(t, executor.runtime.DynamicEnv)
 	assert.Empty(t, executor.runtime.StepOutputs)
 }
+
+func TestExecutor_processStepOutputFiles_Heredoc(t *testing.T) {
+	executor := NewExecutor(&Analyzer{}, NewMockDockerClient(), NewMockGitRepo())
+	executor.runtime.TempDir = t.TempDir()
+
+	envFile := filepath.Join(executor.runtime.TempDir, "GITHUB_ENV")
+	outputFile := filepath.Join(executor.runtime.TempDir, "GITHUB_OUTPUT")
+	require.NoError(t, os.WriteFile(envFile, []byte("NOTES<<EOF\nfirst\nsecond\nEOF\n"), 0600))
+	require.NoError(t, os.WriteFile(outputFile, []byte("json<<JSON\n{\n  \"ok\": true\n}\nJSON\n"), 0600))
+
+	require.NoError(t, executor.processStepOutputFiles(&Step{ID: "build"}))
+
+	assert.Equal(t, "first\nsecond", executor.runtime.DynamicEnv["NOTES"])
+	assert.Equal(t, "{\n  \"ok\": true\n}", executor.runtime.StepOutputs["build"]["json"])
+}
+
+func TestExecutor_executeStep_UnterminatedHeredoc(t *testing.T) {
+	executor := newTestExecutor(t, NewMockDockerClient(), NewMockGitRepo())
+	executor.executors = []StepExecutor{stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_OUTPUT"), []byte("notes<<EOF\nnever closed\n"), 0600))
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	})}
+
+	err := executor.executeStep(t.Context(), &Step{ID: "notes", Name: "Notes", Run: "true"}, &Context{})
+
+	assert.ErrorContains(t, err, `GITHUB_OUTPUT: line 1: matching delimiter "EOF" not found`)
+	assert.Equal(t, "failure", executor.runtime.StepContext.Outcome)
+
+	// The file is emptied so that the next step does not fail on it too.
+	content, err := os.ReadFile(filepath.Join(executor.runtime.TempDir, "GITHUB_OUTPUT"))
+	require.NoError(t, err)
+	assert.Empty(t, content)
+}
+
+func TestExecutor_processStepOutputFiles_PathSummaryState(t *testing.T) {
+	executor := NewExecutor(&Analyzer{}, NewMockDockerClient(), NewMockGitRepo())
+	executor.runtime.TempDir = t.TempDir()
+	executor.runtime.Path = []string{"/opt/node/bin"}
+
+	step := &Step{ID: "setup", Uses: "./setup-tool"}
+	executor.runtime.PostSteps = []PostStep{{Step: &Step{ID: "other"}}, {Step: step}}
+
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(executor.runtime.TempDir, name), []byte(content), 0600))
+	}
+	write("GITHUB_PATH", "/opt/tool/bin\n")
+	write("GITHUB_STEP_SUMMARY", "### Tool\n\nInstalled.\n")
+	write("GITHUB_STATE", "cache-key=linux-abc\n")
+
+	require.NoError(t, executor.processStepOutputFiles(step))
+
+	assert.Equal(t, []string{"/opt/tool/bin", "/opt/node/bin"}, executor.runtime.Path)
+	assert.Equal(t, []string{"### Tool\n\nInstalled.\n"}, executor.runtime.Summaries)
+	assert.Nil(t, executor.runtime.PostSteps[0].State)
+	assert.Equal(t, map[string]string{"cache-key": "linux-abc"}, executor.runtime.PostSteps[1].State)
+
+	// A step that writes nothing adds no summary.
+	require.NoError(t, executor.processStepOutputFiles(&Step{ID: "next"}))
+	assert.Len(t, executor.runtime.Summaries, 1)
+}
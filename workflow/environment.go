@@ -0,0 +1,131 @@
+package workflow
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// stepEnv returns the environment a step runs with: the variables of
+// layeredEnv, then GitHub's default variables, which workflows cannot
+// override.
+func (r *Runtime) stepEnv(step *Step, base map[string]string) (map[string]string, error) {
+	env, err := r.layeredEnv(step, base)
+	if err != nil {
+		return nil, err
+	}
+
+	maps.Copy(env, githubEnv(r.expressionContext()))
+
+	return env, nil
+}
+
+// layeredEnv returns the variables of base, then the workflow's env, the
+// job's env, those set through GITHUB_ENV and the step's env, each overriding
+// the ones before. Expressions in values are evaluated with the env context
+// holding the variables of the layers beneath.
+func (r *Runtime) layeredEnv(step *Step, base map[string]string) (map[string]string, error) {
+	env := maps.Clone(base)
+	if env == nil {
+		env = make(map[string]string)
+	}
+
+	var jobEnv map[string]string
+	if r.JobContext != nil && r.JobContext.Job != nil {
+		jobEnv = r.JobContext.Job.Env
+	}
+
+	layers := []struct {
+		name  string
+		vars  map[string]string
+		exprs bool
+	}{
+		{name: "workflow", vars: r.WorkflowEnv, exprs: true},
+		{name: "job", vars: jobEnv, exprs: true},
+		{name: "GITHUB_ENV", vars: r.DynamicEnv},
+		{name: "step", vars: step.Env, exprs: true},
+	}
+
+	for _, layer := range layers {
+		if !layer.exprs {
+			maps.Copy(env, layer.vars)
+			continue
+		}
+
+		evaluator := NewEvaluator(r.expressionContext().withEnv(maps.Clone(env)))
+		for _, k := range slices.Sorted(maps.Keys(layer.vars)) {
+			value, err := evaluator.Interpolate(layer.vars[k])
+			if err != nil {
+				return nil, fmt.Errorf("%s env %s: %w", layer.name, k, err)
+			}
+			env[k] = value
+		}
+	}
+
+	return env, nil
+}
+
+// evaluator returns an evaluator for the expressions of step, such as those
+// in its run and with, whose env context holds the variables step runs with.
+func (r *Runtime) evaluator(step *Step) (*Evaluator, error) {
+	env, err := r.layeredEnv(step, nil)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating env: %w", err)
+	}
+
+	return NewEvaluator(r.expressionContext().withEnv(env)), nil
+}
+
+// expressionContext returns the context expressions of the running step are
+// evaluated in.
+func (r *Runtime) expressionContext() *Context {
+	if r.Context == nil {
+		return &Context{}
+	}
+	return r.Context
+}
+
+// githubEnv returns the default variables of a runner, derived from the
+// github context so that scripts see the values dryrun shows.
+func githubEnv(ctx *Context) map[string]string {
+	gh := ctx.GitHub
+
+	refType := ""
+	refName := gh.Ref
+	if name, ok := strings.CutPrefix(gh.Ref, "refs/heads/"); ok {
+		refType, refName = "branch", name
+	} else if name, ok := strings.CutPrefix(gh.Ref, "refs/tags/"); ok {
+		refType, refName = "tag", name
+	}
+
+	owner, _, _ := strings.Cut(gh.Repository, "/")
+
+	return map[string]string{
+		"CI":                      "true",
+		"GITHUB_ACTIONS":          "true",
+		"GITHUB_WORKSPACE":        "/github/workspace",
+		"GITHUB_EVENT_NAME":       gh.EventName,
+		"GITHUB_REF":              gh.Ref,
+		"GITHUB_REF_NAME":         refName,
+		"GITHUB_REF_TYPE":         refType,
+		"GITHUB_SHA":              gh.SHA,
+		"GITHUB_ACTOR":            gh.Actor,
+		"GITHUB_REPOSITORY":       gh.Repository,
+		"GITHUB_REPOSITORY_OWNER": owner,
+		"GITHUB_SERVER_URL":       "https://github.com",
+		"GITHUB_API_URL":          "https://api.github.com",
+		"GITHUB_GRAPHQL_URL":      "https://api.github.com/graphql",
+		"RUNNER_OS":               "Linux",
+		"RUNNER_ARCH":             "X64",
+	}
+}
+
+// envList formats env as KEY=value entries, sorted by key.
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		list = append(list, k+"="+env[k])
+	}
+	return list
+}
@@ -1,7 +1,10 @@
 package workflow
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -23,6 +26,93 @@ func NewEvaluator(ctx *Context) *Evaluator {
 }
 
 func (e *Evaluator) Evaluate(expr string) (*EvaluationResult, error) {
+	node, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.eval(node)
+}
+
+// Interpolate replaces each ${{ }} expression embedded in s with its value.
+func (e *Evaluator) Interpolate(s string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${{")
+		if start == -1 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		end := strings.Index(s[start:], "}}")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated expression %q", s[start:])
+		}
+		end += start
+
+		result, err := e.Evaluate(s[start+3 : end])
+		if err != nil {
+			return "", fmt.Errorf("evaluating %q: %w", s[start:end+2], err)
+		}
+
+		b.WriteString(s[:start])
+		b.WriteString(toString(result.Value))
+		s = s[end+2:]
+	}
+}
+
+// EvaluateCondition evaluates an if: condition. As in GitHub Actions, an
+// empty condition means success(), and a condition that does not call a
+// status check function only passes while the current status is success.
+func (e *Evaluator) EvaluateCondition(expr string) (*EvaluationResult, error) {
+	if strings.TrimSpace(expr) == "" {
+		ok := e.statusIs("success")
+		return &EvaluationResult{Value: ok, Trace: "success() -> " + formatValue(ok)}, nil
+	}
+
+	node, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasStatusCheck(node) && !e.statusIs("success") {
+		return &EvaluationResult{Value: false, Trace: "success() -> false"}, nil
+	}
+
+	result, err := e.eval(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EvaluationResult{Value: toBool(result.Value), Trace: result.Trace}, nil
+}
+
+// EvaluateBool evaluates a field such as continue-on-error that holds either
+// a boolean or an expression. An empty value is false.
+func (e *Evaluator) EvaluateBool(value string) (bool, error) {
+	if strings.TrimSpace(value) == "" {
+		return false, nil
+	}
+
+	result, err := e.Evaluate(value)
+	if err != nil {
+		return false, err
+	}
+
+	return toBool(result.Value), nil
+}
+
+// usesStatusCheck reports whether a condition calls success(), failure(),
+// cancelled() or always(), which lets it run after a failure.
+func usesStatusCheck(expr string) bool {
+	node, err := parseExpression(expr)
+	if err != nil {
+		return false
+	}
+	return hasStatusCheck(node)
+}
+
+func parseExpression(expr string) (Node, error) {
 	// Strip ${{ }} wrapper if present.
 	expr = strings.TrimSpace(expr)
 	if strings.HasPrefix(expr, "${{") && strings.HasSuffix(expr, "}}") {
@@ -43,7 +133,37 @@ func (e *Evaluator) Evaluate(expr string) (*EvaluationResult, error) {
 		return nil, fmt.Errorf("parsing: %w", err)
 	}
 
-	return e.eval(node)
+	return node, nil
+}
+
+func hasStatusCheck(node Node) bool {
+	switch n := node.(type) {
+	case *BinaryOpNode:
+		return hasStatusCheck(n.Left) || hasStatusCheck(n.Right)
+	case *UnaryOpNode:
+		return hasStatusCheck(n.Operand)
+	case *FunctionCallNode:
+		switch n.Name {
+		case "success", "failure", "cancelled", "always":
+			return true
+		}
+		for _, arg := range n.Args {
+			if hasStatusCheck(arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// statusIs reports whether the current job status is status. An unknown
+// status, as during analysis, counts as success.
+func (e *Evaluator) statusIs(status string) bool {
+	current := e.ctx.Job.Status
+	if current == "" {
+		current = "success"
+	}
+	return current == status
 }
 
 func (e *Evaluator) eval(node Node) (*EvaluationResult, error) {
@@ -73,6 +193,15 @@ func (e *Evaluator) eval(node Node) (*EvaluationResult, error) {
 		trace := fmt.Sprintf("%s %s %s -> %s", left.Trace, n.Op, right.Trace, formatValue(result))
 		return &EvaluationResult{Value: result, Trace: trace}, nil
 
+	case *UnaryOpNode:
+		operand, err := e.eval(n.Operand)
+		if err != nil {
+			return nil, err
+		}
+
+		result := !toBool(operand.Value)
+		return &EvaluationResult{Value: result, Trace: fmt.Sprintf("!%s -> %s", operand.Trace, formatValue(result))}, nil
+
 	case *FunctionCallNode:
 		var args []any
 		var argTraces []string
@@ -86,12 +215,38 @@ func (e *Evaluator) eval(node Node) (*EvaluationResult, error) {
 			args = append(args, r.Value)
 			argTraces = append(argTraces, r.Trace)
 		}
-		result, err := callFunction(n.Name, args)
+		result, err := e.callFunction(n.Name, args)
 		if err != nil {
 			return nil, err
 		}
 		trace := fmt.Sprintf("%s(%s) -> %s", n.Name, strings.Join(argTraces, ", "), formatValue(result))
 		return &EvaluationResult{Value: result, Trace: trace}, nil
+
+	case *PropertyAccessNode:
+		object, err := e.eval(n.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		val, ok := lookupValue(object.Value, splitPath(n.Path))
+		if !ok {
+			val = nil
+		}
+		return &EvaluationResult{Value: val, Trace: fmt.Sprintf("(%s).%s -> %s", object.Trace, n.Path, formatValue(val))}, nil
+
+	case *IndexNode:
+		object, err := e.eval(n.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err := e.eval(n.Index)
+		if err != nil {
+			return nil, err
+		}
+
+		val := lookupIndex(object.Value, index.Value)
+		return &EvaluationResult{Value: val, Trace: fmt.Sprintf("(%s)[%s] -> %s", object.Trace, index.Trace, formatValue(val))}, nil
 	}
 
 	return nil, fmt.Errorf("unknown node type: %T", node)
@@ -120,12 +275,15 @@ func applyBinaryOp(op string, left, right any) any {
 	return nil
 }
 
-func callFunction(name string, args []any) (any, error) {
+func (e *Evaluator) callFunction(name string, args []any) (any, error) {
 	switch name {
 	case "contains":
 		if len(args) != 2 {
 			return nil, fmt.Errorf("contains requires 2 arguments")
 		}
+		if arr, ok := args[0].([]any); ok {
+			return slices.ContainsFunc(arr, func(item any) bool { return equals(item, args[1]) }), nil
+		}
 		return strings.Contains(toString(args[0]), toString(args[1])), nil
 
 	case "startsWith":
@@ -158,19 +316,70 @@ func callFunction(name string, args []any) (any, error) {
 
 		return joinArray(args[0], sep), nil
 
+	case "toJSON":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("toJSON requires 1 argument")
+		}
+
+		data, err := json.MarshalIndent(args[0], "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("toJSON: %w", err)
+		}
+
+		return string(data), nil
+
+	case "fromJSON":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("fromJSON requires 1 argument")
+		}
+
+		var v any
+		if err := json.Unmarshal([]byte(toString(args[0])), &v); err != nil {
+			return nil, fmt.Errorf("fromJSON: %w", err)
+		}
+
+		return v, nil
+
+	case "hashFiles":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("hashFiles requires at least 1 argument")
+		}
+
+		patterns := make([]string, len(args))
+		for i, arg := range args {
+			patterns[i] = toString(arg)
+		}
+
+		return hashFiles(cmp.Or(e.ctx.GitHub.Workspace, "."), patterns)
+
 	case "always":
 		return true, nil
 	case "success":
-		return true, nil
+		return e.statusIs("success"), nil
 	case "failure":
-		return false, nil
+		return e.statusIs("failure"), nil
 	case "cancelled":
-		return false, nil
+		return e.statusIs("cancelled"), nil
 	}
 
 	return nil, fmt.Errorf("unknown function: %s", name)
 }
 
+// lookupIndex returns the element of an array at a numeric index, or the
+// property of an object named by index, or null if there is none.
+func lookupIndex(v, index any) any {
+	if arr, ok := v.([]any); ok {
+		i, isNumber := index.(float64)
+		if !isNumber || i < 0 || int(i) >= len(arr) || i != float64(int(i)) {
+			return nil
+		}
+		return arr[int(i)]
+	}
+
+	val, _ := lookupValue(v, []string{toString(index)})
+	return val
+}
+
 func equals(a, b any) bool {
 	return toString(a) == toString(b)
 }
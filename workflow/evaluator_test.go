@@ -0,0 +1,190 @@
+package workflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluator_EvaluateCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		condition string
+		expected  bool
+	}{
+		{name: "empty condition on success", status: "success", condition: "", expected: true},
+		{name: "empty condition on failure", status: "failure", condition: "", expected: false},
+		{name: "unknown status counts as success", status: "", condition: "success()", expected: true},
+		{name: "plain condition on success", status: "success", condition: "github.ref == 'refs/heads/main'", expected: true},
+		{name: "plain condition on failure", status: "failure", condition: "github.ref == 'refs/heads/main'", expected: false},
+		{name: "failure on failure", status: "failure", condition: "failure()", expected: true},
+		{name: "failure on success", status: "success", condition: "${{ failure() }}", expected: false},
+		{name: "always on cancelled", status: "cancelled", condition: "always()", expected: true},
+		{name: "cancelled on cancelled", status: "cancelled", condition: "cancelled()", expected: true},
+		{name: "success on skipped", status: "skipped", condition: "success()", expected: false},
+		{name: "negated status check", status: "failure", condition: "!cancelled()", expected: true},
+		{name: "status check combined", status: "failure", condition: "failure() && github.ref == 'refs/heads/dev'", expected: false},
+		{name: "job status", status: "failure", condition: "always() && job.status == 'failure'", expected: true},
+		{name: "non-boolean value", status: "success", condition: "github.ref", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &Context{
+				GitHub: GitHubContext{Ref: "refs/heads/main"},
+				Job:    JobContext{Status: tt.status},
+			}
+
+			result, err := NewEvaluator(ctx).EvaluateCondition(tt.condition)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+}
+
+func TestEvaluator_Evaluate_Not(t *testing.T) {
+	ctx := &Context{GitHub: GitHubContext{EventName: "push"}}
+
+	result, err := NewEvaluator(ctx).Evaluate("!(github.event_name == 'push')")
+	require.NoError(t, err)
+	assert.Equal(t, false, result.Value)
+}
+
+func TestEvaluator_Interpolate(t *testing.T) {
+	ctx := &Context{
+		GitHub: GitHubContext{Ref: "refs/heads/main"},
+		Matrix: map[string]any{"os": "ubuntu-22.04", "node": 20.0},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+		errMsg   string
+	}{
+		{input: "ubuntu-latest", expected: "ubuntu-latest"},
+		{input: "${{ matrix.os }}", expected: "ubuntu-22.04"},
+		{input: "node-${{ matrix.node }} on ${{matrix.os}}", expected: "node-20 on ubuntu-22.04"},
+		{input: "${{ github.ref == 'refs/heads/main' }}", expected: "true"},
+		{input: "${{ matrix.os", errMsg: "unterminated expression"},
+		{input: "${{ matrix.os == }}", errMsg: "evaluating \"${{ matrix.os == }}\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := NewEvaluator(ctx).Interpolate(tt.input)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestEvaluator_Evaluate_Functions(t *testing.T) {
+	ctx := &Context{
+		GitHub: GitHubContext{Event: map[string]any{
+			"pull_request": map[string]any{
+				"labels": []any{
+					map[string]any{"name": "bug"},
+					map[string]any{"name": "ui"},
+				},
+			},
+		}},
+		Matrix:  map[string]any{"os": "ubuntu"},
+		Secrets: map[string]string{"NPM_TOKEN": "s3cr3t"},
+		Steps: map[string]StepContext{
+			"set-version": {Outputs: map[string]string{"tag": "v1"}},
+		},
+	}
+
+	tests := []struct {
+		expr     string
+		expected any
+		errMsg   string
+	}{
+		{expr: "steps.set-version.outputs.tag", expected: "v1"},
+		{expr: "secrets['NPM_TOKEN']", expected: "s3cr3t"},
+		{expr: "github.event['pull_request'].labels[1].name", expected: "ui"},
+		{expr: "fromJSON('[1, 2]')[5]", expected: nil},
+		{expr: "contains('hello world', 'world')", expected: true},
+		{expr: "contains(github.event.pull_request.labels.*.name, 'bug')", expected: true},
+		{expr: "contains(github.event.pull_request.labels.*.name, 'docs')", expected: false},
+		{expr: "contains(fromJSON('[\"a\", \"b\"]'), 'b')", expected: true},
+		{expr: "github.event.pull_request.labels.*.name", expected: []any{"bug", "ui"}},
+		{expr: "fromJSON('3')", expected: 3.0},
+		{expr: "fromJSON('{\"os\": [\"ubuntu\", \"macos\"]}').os", expected: []any{"ubuntu", "macos"}},
+		{expr: "fromJSON('[{\"a\": {\"b\": 1}}, {\"a\": {\"b\": 2}}]').*.a.b", expected: []any{1.0, 2.0}},
+		{expr: "fromJSON('not json')", errMsg: "fromJSON"},
+		{expr: "toJSON(matrix)", expected: "{\n  \"os\": \"ubuntu\"\n}"},
+		{expr: "toJSON(fromJSON('[1, true]'))", expected: "[\n  1,\n  true\n]"},
+		{expr: "runner.os", expected: "Linux"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			result, err := NewEvaluator(ctx).Evaluate(tt.expr)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+}
+
+func TestEvaluator_Evaluate_HashFiles(t *testing.T) {
+	workspace := t.TempDir()
+	for name, content := range map[string]string{
+		"app/package-lock.json":  "app",
+		"web/package-lock.json":  "web",
+		"README.md":              "readme",
+		".git/package-lock.json": "git",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(workspace, filepath.Dir(name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, name), []byte(content), 0o644))
+	}
+
+	hash := func(contents ...string) string {
+		combined := sha256.New()
+		for _, content := range contents {
+			sum := sha256.Sum256([]byte(content))
+			combined.Write(sum[:])
+		}
+		return hex.EncodeToString(combined.Sum(nil))
+	}
+
+	tests := []struct {
+		expr     string
+		expected string
+		errMsg   string
+	}{
+		{expr: "hashFiles('**/package-lock.json')", expected: hash("app", "web")},
+		{expr: "hashFiles('**/package-lock.json', '!web/**')", expected: hash("app")},
+		{expr: "hashFiles('README.md', './app/*.json')", expected: hash("readme", "app")},
+		{expr: "hashFiles('**/go.sum')", expected: ""},
+		{expr: "hashFiles('[')", errMsg: "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			ctx := &Context{GitHub: GitHubContext{Workspace: workspace}}
+
+			result, err := NewEvaluator(ctx).Evaluate(tt.expr)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+}
@@ -1,10 +1,17 @@
 package workflow
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,10 +19,16 @@ import (
 type DockerClient interface {
 	CreateContainer(ctx context.Context, config *ContainerConfig) (string, error)
 	StartContainer(ctx context.Context, containerID string) error
-	ExecInContainer(ctx context.Context, containerID string, cmd []string) (*ExecResult, error)
+	ExecInContainer(ctx context.Context, containerID string, config *ExecConfig) (*ExecResult, error)
 	StopContainer(ctx context.Context, containerID string) error
 	RemoveContainer(ctx context.Context, containerID string) error
 	PullImage(ctx context.Context, image string) error
+	RegistryLogin(ctx context.Context, image string, credentials *Credentials) error
+	CreateNetwork(ctx context.Context, name string) (string, error)
+	RemoveNetwork(ctx context.Context, networkID string) error
+	ContainerHealth(ctx context.Context, containerID string) (string, error)
+	WaitForContainer(ctx context.Context, containerID string) (int, error)
+	GetContainerLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) (*ExecResult, error)
 	Close() error
 }
 
@@ -41,29 +54,81 @@ type Executor struct {
 	runtime   *Runtime
 	executors []StepExecutor
 	renderer  *RunRenderer
+	maxJobs   int           // Maximum number of job instances running at once, 0 for no limit
+	cleanup   bool          // Remove job containers once their job ends
+	platforms Platforms     // Runs-on label -> image of jobs without a container
+	jobSlots  chan struct{} // Semaphore enforcing maxJobs during Execute
+
+	summaries   *jobSummaries  // Job summaries of the run, shared with the executors of its jobs
+	summaryFile string         // File the job summaries are saved to, if any
+	annotations *annotationLog // Annotations of the run, shared with the executors of its jobs
+	hostPorts   *hostPorts     // Host ports the services of running jobs publish, shared likewise
+
+	releasePorts func() // Releases the host ports the job's services publish, if any
 }
 
 // Runtime tracks the execution state.
 type Runtime struct {
-	WorkingDir  string
-	Containers  map[string]*ContainerInfo
-	Networks    map[string]*NetworkInfo
-	Volumes     map[string]*VolumeInfo
-	JobContext  *ExecutionJobContext
-	StepContext *ExecutionStepContext
-	DynamicEnv  map[string]string            // Environment variables set during execution
-	StepOutputs map[string]map[string]string // step_id -> output_name -> value
-	TempDir     string                       // Directory for GITHUB_ENV and GITHUB_OUTPUT files
+	WorkingDir   string
+	Containers   map[string]*ContainerInfo
+	Networks     map[string]*NetworkInfo
+	Volumes      map[string]*VolumeInfo
+	JobContext   *ExecutionJobContext
+	StepContext  *ExecutionStepContext
+	DynamicEnv   map[string]string            // Environment variables set during execution
+	StepOutputs  map[string]map[string]string // step_id -> output_name -> value
+	TempDir      string                       // Directory for the file commands, such as GITHUB_ENV
+	JobContainer *ContainerInfo               // Container the job's run steps are executed in
+	RunnerImage  string                       // Image standing in for the runner of a job without a container
+	WorkflowEnv  map[string]string            // env: of the workflow, beneath the job's
+	Context      *Context                     // Context the running step's expressions are evaluated in
+	Path         []string                     // Directories added through GITHUB_PATH, latest first
+	Summaries    []string                     // Markdown written to GITHUB_STEP_SUMMARY, one entry per step
+	PostSteps    []PostStep                   // Post hooks of the actions run so far, in the order registered
+	Annotations  []Annotation                 // Errors, warnings and notices the job's steps reported
+}
+
+// PostStep is the post entry point of an action, run once its job's steps
+// are done.
+type PostStep struct {
+	Step  *Step
+	If    string            // Condition the hook runs under, always() unless post-if says otherwise
+	State map[string]string // Saved through GITHUB_STATE by the action's earlier entry points
+	Run   func(ctx context.Context, runtime *Runtime, state map[string]string) (*ExecutionStepResult, error)
 }
 
 // ContainerConfig holds container creation parameters.
 type ContainerConfig struct {
-	Image      string
+	Image          string
+	Cmd            []string
+	Env            []string
+	WorkingDir     string
+	Volumes        []VolumeMount
+	Networks       []string
+	NetworkAliases []string // Names the container is reachable by on its networks
+	NetworkMode    string   // Such as host, instead of joining Networks
+	Ports          []string // Published ports, as in docker run --publish
+	User           string
+	Entrypoint     []string
+	Hostname       string
+	Privileged     bool
+	Init           bool // Run an init process as PID 1, as with docker run --init
+	ExtraHosts     []string
+	CapAdd         []string
+	Tmpfs          map[string]string // Path -> mount options
+	ShmSize        int64             // Bytes, or 0 for docker's default
+	Memory         int64             // Bytes, or 0 for no limit
+	NanoCPUs       int64             // Billionths of a CPU, or 0 for no limit
+	Healthcheck    *Healthcheck
+}
+
+// ExecConfig holds the parameters of a command executed in a running container.
+type ExecConfig struct {
 	Cmd        []string
 	Env        []string
 	WorkingDir string
-	Volumes    []VolumeMount
-	Networks   []string
+	Stdout     io.Writer // Receives stdout as the command produces it, if set
+	Stderr     io.Writer // Receives stderr as the command produces it, if set
 }
 
 // ContainerInfo tracks running container details.
@@ -89,9 +154,10 @@ type VolumeInfo struct {
 
 // VolumeMount represents a volume mount configuration.
 type VolumeMount struct {
-	Source string
-	Target string
-	Type   string // bind, volume, tmpfs
+	Source   string
+	Target   string
+	Type     string // bind, volume, tmpfs
+	ReadOnly bool
 }
 
 // ExecResult contains command execution results.
@@ -105,6 +171,8 @@ type ExecResult struct {
 type ExecutionStepResult struct {
 	Success  bool
 	ExitCode int
+	Stdout   string
+	Stderr   string
 	Outputs  map[string]string
 	Error    error
 	Duration int64 // nanoseconds
@@ -133,11 +201,17 @@ type ActionOutput struct {
 
 // ActionRuns defines how an action executes.
 type ActionRuns struct {
-	Using string            `yaml:"using"` // docker, node16, node20, composite
-	Image string            `yaml:"image"` // for docker actions
-	Main  string            `yaml:"main"`  // for js actions
-	Steps []Step            `yaml:"steps"` // for composite actions
-	Env   map[string]string `yaml:"env"`
+	Using          string            `yaml:"using"`           // docker, node16, node20, composite
+	Image          string            `yaml:"image"`           // for docker actions
+	PreEntrypoint  string            `yaml:"pre-entrypoint"`  // for docker actions, run before the entrypoint
+	PostEntrypoint string            `yaml:"post-entrypoint"` // for docker actions, run once the job's steps are done
+	Pre            string            `yaml:"pre"`             // for js actions, run before main
+	PreIf          string            `yaml:"pre-if"`          // condition of pre or pre-entrypoint
+	Main           string            `yaml:"main"`            // for js actions
+	Post           string            `yaml:"post"`            // for js actions, run once the job's steps are done
+	PostIf         string            `yaml:"post-if"`         // condition of post or post-entrypoint
+	Steps          []Step            `yaml:"steps"`           // for composite actions
+	Env            map[string]string `yaml:"env"`
 }
 
 // ExecutionJobContext holds job-level execution context.
@@ -164,23 +238,39 @@ func NewExecutor(analyzer *Analyzer, docker DockerClient, git ExecutorGitRepo) *
 		analyzer: analyzer,
 		docker:   docker,
 		git:      git,
-		runtime: &Runtime{
-			Containers:  make(map[string]*ContainerInfo),
-			Networks:    make(map[string]*NetworkInfo),
-			Volumes:     make(map[string]*VolumeInfo),
-			DynamicEnv:  make(map[string]string),
-			StepOutputs: make(map[string]map[string]string),
-		},
+		runtime:  newRuntime(""),
 		executors: []StepExecutor{
 			&ShellStepExecutor{Docker: docker, renderer: NewRunRenderer()},
-			&ActionStepExecutor{Docker: docker, Git: git},
+			&ActionStepExecutor{Docker: docker, Git: git, renderer: NewRunRenderer()},
 		},
-		renderer: NewRunRenderer(),
+		renderer:    NewRunRenderer(),
+		cleanup:     true,
+		platforms:   DefaultPlatforms(),
+		summaries:   &jobSummaries{},
+		annotations: &annotationLog{},
+		hostPorts:   &hostPorts{},
 	}
 }
 
-// Execute runs the workflow with the given context.
+// newRuntime creates empty execution state for the given working directory.
+func newRuntime(workingDir string) *Runtime {
+	return &Runtime{
+		WorkingDir:  workingDir,
+		Containers:  make(map[string]*ContainerInfo),
+		Networks:    make(map[string]*NetworkInfo),
+		Volumes:     make(map[string]*VolumeInfo),
+		DynamicEnv:  make(map[string]string),
+		StepOutputs: make(map[string]map[string]string),
+	}
+}
+
+// Execute runs the workflow with the given context. Jobs are scheduled as a
+// DAG: each job starts as soon as every job it needs has finished.
 func (e *Executor) Execute(ctx context.Context, workflow *Workflow, triggerContext *Context) error {
+	if err := workflow.Validate(); err != nil {
+		return fmt.Errorf("invalid workflow: %w", err)
+	}
+
 	if err := e.setupTempDirectory(); err != nil {
 		return fmt.Errorf("setting up temp directory: %w", err)
 	}
@@ -191,64 +281,700 @@ func (e *Executor) Execute(ctx context.Context, workflow *Workflow, triggerConte
 		return fmt.Errorf("workflow analysis failed")
 	}
 
+	e.jobSlots = nil
+	if e.maxJobs > 0 {
+		e.jobSlots = make(chan struct{}, e.maxJobs)
+	}
+
+	// Group job instances by job, keeping the analysis order.
+	var pending []string
+	instances := make(map[string][]JobResult)
 	for _, jobResult := range analysis.Jobs {
-		if !jobResult.WouldRun {
+		if _, seen := instances[jobResult.ID]; !seen {
+			pending = append(pending, jobResult.ID)
+		}
+		instances[jobResult.ID] = append(instances[jobResult.ID], jobResult)
+	}
+
+	type completion struct {
+		jobID   string
+		result  string
+		outputs map[string]string
+		err     error
+	}
+
+	finished := make(chan completion)
+	results := make(map[string]string)  // Job ID -> success, failure, cancelled or skipped
+	statuses := make(map[string]string) // Job ID -> status seen by the jobs that need it
+	jobs := make(map[string]JobContext) // Jobs context of the jobs that have finished
+	running := 0
+
+	var errs []error
+	for len(pending) > 0 || running > 0 {
+		var waiting []string
+		for _, jobID := range pending {
+			job := workflow.Jobs[jobID]
+			if !e.needsSatisfied(job, instances, results) {
+				waiting = append(waiting, jobID)
+				continue
+			}
+
+			status := needsStatus(ctx, job, results, statuses)
+			runContext := triggerContext.withJobs(maps.Clone(jobs)).withNeeds(needsContext(job, jobs)).withJobStatus(status)
+
+			running++
+			go func() {
+				result, outputs, err := e.executeJobInstances(ctx, workflow, instances[jobID], runContext)
+				finished <- completion{jobID: jobID, result: result, outputs: outputs, err: err}
+			}()
+			statuses[jobID] = status
+		}
+		pending = waiting
+
+		if running == 0 {
+			break
+		}
+
+		c := <-finished
+		running--
+		results[c.jobID] = c.result
+		jobs[c.jobID] = JobContext{Status: c.result, Outputs: c.outputs}
+		if c.result == "failure" || c.result == "cancelled" {
+			statuses[c.jobID] = c.result
+		}
+		if c.err != nil {
+			errs = append(errs, c.err)
+		}
+	}
+
+	if len(pending) > 0 {
+		errs = append(errs, fmt.Errorf("could not schedule jobs: %s", strings.Join(pending, ", ")))
+	}
+
+	if err := e.writeSummaryFile(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// needsStatus returns the status a job starts with, derived from the jobs it
+// needs: failure if any of them failed or inherited a failure, cancelled if
+// the run was cancelled, skipped if any of them was skipped, and success
+// otherwise. Status check functions in the job's condition see this status.
+func needsStatus(ctx context.Context, job Job, results, statuses map[string]string) string {
+	status := "success"
+	for _, dep := range job.Needs.Jobs {
+		switch {
+		case statuses[dep] == "failure":
+			return "failure"
+		case statuses[dep] == "cancelled":
+			status = "cancelled"
+		case results[dep] == "skipped" && status == "success":
+			status = "skipped"
+		}
+	}
+
+	if ctx.Err() != nil {
+		return "cancelled"
+	}
+
+	return status
+}
+
+// SetMaxParallelJobs limits how many job instances run at once. Zero or a
+// negative value removes the limit.
+func (e *Executor) SetMaxParallelJobs(n int) {
+	e.maxJobs = max(n, 0)
+}
+
+// SetCleanup sets whether job containers are removed once their job ends.
+// When disabled they are left running so they can be inspected.
+func (e *Executor) SetCleanup(cleanup bool) {
+	e.cleanup = cleanup
+}
+
+// SetPlatforms sets the images that stand in for the runners selected by
+// runs-on labels, replacing the defaults.
+func (e *Executor) SetPlatforms(platforms Platforms) {
+	e.platforms = platforms
+}
+
+// needsSatisfied reports whether every job that job needs has finished.
+// Needs that do not name a job in the workflow do not block scheduling.
+func (e *Executor) needsSatisfied(job Job, instances map[string][]JobResult, results map[string]string) bool {
+	for _, dep := range job.Needs.Jobs {
+		if _, done := results[dep]; !done && instances[dep] != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// executeJobInstances runs every instance of a job, honoring the job's
+// strategy: at most max-parallel instances run at once, and when fail-fast is
+// enabled a failing instance cancels its siblings. It returns the combined
+// result of the instances: failure if any failed, cancelled if any was
+// cancelled, skipped if all were skipped, and success otherwise. As on GitHub,
+// the outputs of a matrix job are those of its instances merged in the order
+// they finished, with empty values not overriding set ones.
+func (e *Executor) executeJobInstances(ctx context.Context, workflow *Workflow, instances []JobResult, triggerContext *Context) (string, map[string]string, error) {
+	jobID := instances[0].ID
+	job, exists := workflow.Jobs[jobID]
+	if !exists {
+		return "failure", nil, fmt.Errorf("job %s not found in workflow", jobID)
+	}
+
+	failFast := true
+	if job.Strategy != nil {
+		matrix := job.Strategy.Matrix
+		if matrix.Dynamic() {
+			// A job that will not run, as when a job it needs failed, has no
+			// matrix to evaluate.
+			condition, err := NewEvaluator(triggerContext).EvaluateCondition(job.If)
+			if err == nil && !condition.Value.(bool) {
+				e.renderer.RenderJobSkipped(jobID, skipReason(job.If, triggerContext.Job.Status))
+				return "skipped", nil, nil
+			}
+
+			// Evaluated again now that the outputs of the jobs it needs are known.
+			evaluated, err := matrix.Evaluate(NewEvaluator(triggerContext))
+			if err != nil {
+				e.renderer.RenderJobError(jobID, 0)
+				return "failure", nil, fmt.Errorf("job %s has an invalid matrix: %w", jobID, err)
+			}
+			matrix = evaluated
+		}
+
+		combos, err := matrix.Expand()
+		if err != nil {
+			e.renderer.RenderJobError(jobID, 0)
+			return "failure", nil, fmt.Errorf("job %s has an invalid matrix: %w", jobID, err)
+		}
+
+		if job.Strategy.Matrix.Dynamic() {
+			instances = make([]JobResult, 0, len(combos))
+			for _, combo := range combos {
+				instances = append(instances, JobResult{ID: jobID, Name: combo.Name(jobID), Matrix: combo.Values})
+			}
+		}
+
+		if job.Strategy.FailFast != nil {
+			failFast = *job.Strategy.FailFast
+		}
+	}
+
+	maxParallel := len(instances)
+	if job.Strategy != nil && job.Strategy.MaxParallel > 0 && job.Strategy.MaxParallel < maxParallel {
+		maxParallel = job.Strategy.MaxParallel
+	}
+
+	groupCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		results []string
+		outputs = make(map[string]string)
+	)
+
+	record := func(result string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		results = append(results, result)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	recordOutputs := func(instanceOutputs map[string]string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for name, value := range instanceOutputs {
+			if value != "" || outputs[name] == "" {
+				outputs[name] = value
+			}
+		}
+	}
+
+	slots := make(chan struct{}, maxParallel)
+	for _, jobResult := range instances {
+		instance := job
+		instance.Name = jobResult.Name
+		instance.Defaults.Run = job.Defaults.Run.withFallback(workflow.Defaults.Run)
+		instanceContext := triggerContext.withMatrix(jobResult.Matrix)
+
+		condition, err := NewEvaluator(instanceContext).EvaluateCondition(job.If)
+		if err != nil {
+			e.renderer.RenderJobError(instance.Name, 0)
+			record("failure", fmt.Errorf("job %s: evaluating condition: %w", instance.Name, err))
+			continue
+		}
+
+		if !condition.Value.(bool) {
+			e.renderer.RenderJobSkipped(instance.Name, skipReason(job.If, triggerContext.Job.Status))
+			record("skipped", nil)
+			continue
+		}
+
+		if !acquire(groupCtx, slots, e.jobSlots) {
+			e.renderer.RenderJobCancelled(instance.Name, cancelReason(groupCtx))
+			record("cancelled", nil)
 			continue
 		}
 
-		job, exists := workflow.Jobs[jobResult.Name]
-		if !exists {
-			return fmt.Errorf("job %s not found in workflow", jobResult.Name)
+		jobExecutor, err := e.forJob(instance.Name)
+		if err != nil {
+			release(slots, e.jobSlots)
+			record("failure", fmt.Errorf("preparing job %s: %w", instance.Name, err))
+			continue
 		}
+		jobExecutor.runtime.WorkflowEnv = workflow.Env
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer release(slots, e.jobSlots)
+
+			err := jobExecutor.executeJob(groupCtx, &instance, instanceContext)
+			status := jobExecutor.runtime.JobContext.Status
+			recordOutputs(jobExecutor.runtime.JobContext.Outputs)
+
+			var timeout *TimeoutError
+			if err == nil || (status == "cancelled" && !errors.As(err, &timeout)) {
+				record(status, nil)
+				return
+			}
+
+			continueOnError, evalErr := NewEvaluator(instanceContext).EvaluateBool(job.ContinueOnError)
+			if evalErr != nil {
+				err = errors.Join(err, fmt.Errorf("evaluating continue-on-error: %w", evalErr))
+			} else if continueOnError {
+				jobExecutor.renderer.RenderWarning(fmt.Sprintf("job %s failed, continuing because continue-on-error is set", instance.Name))
+				record("success", nil)
+				return
+			}
+
+			record(status, fmt.Errorf("job %s failed: %w", instance.Name, err))
+			if failFast {
+				cancel(errSiblingFailed)
+			}
+		}()
+	}
 
-		if err := e.executeJob(ctx, &job, triggerContext); err != nil {
-			return fmt.Errorf("job %s failed: %w", jobResult.Name, err)
+	wg.Wait()
+
+	result := "skipped"
+	for _, r := range results {
+		switch {
+		case r == "failure":
+			result = "failure"
+		case r == "cancelled" && result != "failure":
+			result = "cancelled"
+		case r == "success" && result == "skipped":
+			result = "success"
 		}
 	}
 
-	return nil
+	return result, outputs, errors.Join(errs...)
 }
 
-// executeJob runs a single job.
+// errSiblingFailed cancels the instances of a matrix job once one of them
+// fails with fail-fast enabled.
+var errSiblingFailed = errors.New("a sibling matrix job failed")
+
+// skipReason explains why a job whose condition evaluated to false was
+// skipped, given the status it inherited from the jobs it needs.
+func skipReason(condition, status string) string {
+	if usesStatusCheck(condition) {
+		return "condition evaluated to false"
+	}
+
+	switch status {
+	case "failure":
+		return "dependency failed"
+	case "cancelled":
+		return "the run was cancelled"
+	case "skipped":
+		return "dependency was skipped"
+	}
+
+	return "condition evaluated to false"
+}
+
+// acquire takes a slot from each semaphore in order, giving back what it took
+// and returning false if ctx is done first. Nil semaphores are unlimited.
+func acquire(ctx context.Context, semaphores ...chan struct{}) bool {
+	for i, sem := range semaphores {
+		if sem == nil {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			release(semaphores[:i]...)
+			return false
+		}
+
+		if ctx.Err() != nil {
+			release(semaphores[:i+1]...)
+			return false
+		}
+	}
+	return true
+}
+
+// release gives back a slot to each non-nil semaphore.
+func release(semaphores ...chan struct{}) {
+	for _, sem := range semaphores {
+		if sem != nil {
+			<-sem
+		}
+	}
+}
+
+// forJob returns an executor with its own runtime state and output prefix, so
+// that a job instance can run alongside others without sharing step outputs
+// or environment files. The run's summaries, annotations and host ports are
+// shared with the other job executors.
+func (e *Executor) forJob(name string) (*Executor, error) {
+	runtime := newRuntime(e.runtime.WorkingDir)
+
+	if e.runtime.TempDir != "" {
+		tempDir, err := makeTempDir(e.runtime.TempDir, "job-")
+		if err != nil {
+			return nil, fmt.Errorf("creating job temp directory: %w", err)
+		}
+		runtime.TempDir = tempDir
+	}
+
+	renderer := e.renderer.WithPrefix(name)
+
+	executors := make([]StepExecutor, 0, len(e.executors))
+	for _, executor := range e.executors {
+		switch original := executor.(type) {
+		case *ShellStepExecutor:
+			executor = &ShellStepExecutor{Docker: original.Docker, renderer: renderer}
+		case *ActionStepExecutor:
+			executor = &ActionStepExecutor{Docker: original.Docker, Git: original.Git, renderer: renderer}
+		}
+		executors = append(executors, executor)
+	}
+
+	return &Executor{
+		analyzer:    e.analyzer,
+		docker:      e.docker,
+		git:         e.git,
+		runtime:     runtime,
+		executors:   executors,
+		renderer:    renderer,
+		cleanup:     e.cleanup,
+		platforms:   e.platforms,
+		summaries:   e.summaries,
+		annotations: e.annotations,
+		hostPorts:   e.hostPorts,
+	}, nil
+}
+
+// executeJob runs a single job. Once a step fails, the remaining steps only
+// run if their condition calls a status check function such as failure() or
+// always(). The job is cancelled once it runs past its timeout-minutes. Its
+// run steps all execute in one container that, along with the job's service
+// containers, lives as long as the job.
 func (e *Executor) executeJob(ctx context.Context, job *Job, triggerContext *Context) error {
 	e.renderer.RenderJobStart(job.Name)
 
 	e.runtime.JobContext = &ExecutionJobContext{
 		Job:       job,
+		Matrix:    triggerContext.Matrix,
 		Outputs:   make(map[string]string),
 		Status:    "in_progress",
 		StartTime: getCurrentTime(),
 	}
 
+	reason := ""
 	defer func() {
 		e.runtime.JobContext.EndTime = getCurrentTime()
 		duration := e.runtime.JobContext.EndTime - e.runtime.JobContext.StartTime
 		status := e.runtime.JobContext.Status
 
-		if status == "success" {
-			e.processJobOutputs(job)
-		}
-
-		if status == "success" {
+		switch status {
+		case "success":
 			e.renderer.RenderJobSuccess(job.Name, duration)
-		} else {
+		case "cancelled":
+			e.renderer.RenderJobCancelled(job.Name, reason)
+		default:
 			e.renderer.RenderJobError(job.Name, duration)
 		}
 	}()
 
+	minutes, err := timeoutMinutes(job.TimeoutMinutes, triggerContext)
+	if err != nil {
+		e.runtime.JobContext.Status = "failure"
+		return err
+	}
+	if minutes == 0 {
+		minutes = defaultJobTimeoutMinutes
+	}
+
+	ctx, cancel := withTimeout(ctx, minutes)
+	defer cancel()
+
+	err = e.startJobContainers(ctx, job, triggerContext)
+	defer e.stopJobContainers()
+	if err != nil {
+		e.runtime.JobContext.Status = "failure"
+		return fmt.Errorf("starting job containers: %w", err)
+	}
+
+	jobContext := triggerContext.withJobStatus("success")
+	jobContext.Steps = make(map[string]StepContext)
+	e.runtime.Context = jobContext
+
+	var firstErr error
 	for i, step := range job.Steps {
+		if ctx.Err() != nil {
+			jobContext.Job.Status = "cancelled"
+		}
+
+		name, err := e.stepName(&step)
+		if err != nil {
+			err = fmt.Errorf("step %s: evaluating name: %w", step.Name, err)
+			e.renderer.RenderStepError(step.Name, err)
+			e.recordStep(jobContext, &step, "failure", "failure")
+			jobContext.Job.Status = "failure"
+			firstErr = cmp.Or(firstErr, err)
+			continue
+		}
+		step.Name = name
+
+		condition, err := e.stepCondition(&step)
+		if err != nil {
+			err = fmt.Errorf("step %s: evaluating condition: %w", step.Name, err)
+			e.renderer.RenderStepError(step.Name, err)
+			e.recordStep(jobContext, &step, "failure", "failure")
+			jobContext.Job.Status = "failure"
+			firstErr = cmp.Or(firstErr, err)
+			continue
+		}
+
+		if !condition.Value.(bool) {
+			e.renderer.RenderStepSkipped(step.Name)
+			e.recordStep(jobContext, &step, "skipped", "skipped")
+			continue
+		}
+
 		e.renderer.RenderStepStart(i+1, len(job.Steps), step.Name)
 
-		if err := e.executeStep(ctx, &step, triggerContext); err != nil {
+		// Steps that run after cancellation, such as if: always() cleanup,
+		// must not be cancelled themselves.
+		stepCtx := ctx
+		if ctx.Err() != nil {
+			stepCtx = context.WithoutCancel(ctx)
+		}
+
+		err = e.runStep(stepCtx, &step, jobContext)
+		if err == nil {
+			e.renderer.RenderStepSuccess(step.Name)
+			e.recordStep(jobContext, &step, "success", "success")
+			continue
+		}
+
+		var timeout *TimeoutError
+		outcome := "failure"
+		switch {
+		case ctx.Err() != nil:
+			outcome = "cancelled"
+			e.renderer.RenderStepCancelled(step.Name, cancelReason(ctx))
+		case errors.As(err, &timeout):
+			outcome = "cancelled"
+			e.renderer.RenderStepCancelled(step.Name, timeout.Error())
+		}
+
+		continueOnError, evalErr := NewEvaluator(jobContext).EvaluateBool(step.ContinueOnError)
+		if evalErr != nil {
+			err = errors.Join(err, fmt.Errorf("evaluating continue-on-error: %w", evalErr))
+		} else if continueOnError && ctx.Err() == nil {
+			e.renderer.RenderStepFailureIgnored(step.Name, err)
+			e.recordStep(jobContext, &step, outcome, "success")
+			continue
+		}
+
+		e.recordStep(jobContext, &step, outcome, outcome)
+		jobContext.Job.Status = "failure"
+		if ctx.Err() != nil {
+			jobContext.Job.Status = "cancelled"
+		}
+		firstErr = cmp.Or(firstErr, fmt.Errorf("step %s failed: %w", step.Name, err))
+	}
+
+	if err := e.runPostSteps(ctx, jobContext); err != nil {
+		firstErr = cmp.Or(firstErr, err)
+	}
+	e.recordJobSummary(job.Name)
+	e.annotations.add(e.runtime.Annotations...)
+
+	switch {
+	case jobContext.Job.Status == "cancelled":
+		e.runtime.JobContext.Status = "cancelled"
+		reason = cancelReason(ctx)
+
+		// A job that timed out fails the run, unlike one cancelled from outside.
+		var timeout *TimeoutError
+		if errors.As(context.Cause(ctx), &timeout) {
+			return timeout
+		}
+	case firstErr != nil:
+		e.runtime.JobContext.Status = "failure"
+	default:
+		if err := e.processJobOutputs(job); err != nil {
 			e.runtime.JobContext.Status = "failure"
-			return fmt.Errorf("step %s failed: %w", step.Name, err)
+			return fmt.Errorf("evaluating outputs: %w", err)
 		}
+		e.runtime.JobContext.Status = "success"
+	}
 
-		e.renderer.RenderStepSuccess(step.Name)
+	return firstErr
+}
+
+// runPostSteps runs the post hooks of the actions the job's steps used, in
+// the reverse order of those steps, each if its condition holds against the
+// state the job ended in. A failing hook fails the job.
+func (e *Executor) runPostSteps(ctx context.Context, jobContext *Context) error {
+	// Like if: always() steps, hooks run even once the job is cancelled.
+	ctx = context.WithoutCancel(ctx)
+
+	var firstErr error
+	for i := len(e.runtime.PostSteps) - 1; i >= 0; i-- {
+		post := e.runtime.PostSteps[i]
+		name := "Post " + cmp.Or(post.Step.Name, post.Step.ID, post.Step.Uses)
+
+		evaluator, err := e.runtime.evaluator(&Step{})
+		if err != nil {
+			return fmt.Errorf("step %s: %w", name, err)
+		}
+		condition, err := evaluator.EvaluateCondition(post.If)
+		if err != nil {
+			err = fmt.Errorf("step %s: evaluating condition: %w", name, err)
+			e.renderer.RenderStepError(name, err)
+			jobContext.Job.Status = "failure"
+			firstErr = cmp.Or(firstErr, err)
+			continue
+		}
+		if !condition.Value.(bool) {
+			e.renderer.RenderStepSkipped(name)
+			continue
+		}
+
+		e.renderer.RenderStepStart(len(e.runtime.PostSteps)-i, len(e.runtime.PostSteps), name)
+
+		result, err := post.Run(ctx, e.runtime, post.State)
+		if err == nil && !result.Success {
+			err = fmt.Errorf("step failed with exit code %d", result.ExitCode)
+		}
+		if err != nil {
+			e.renderer.RenderStepError(name, err)
+			if jobContext.Job.Status != "cancelled" {
+				jobContext.Job.Status = "failure"
+			}
+			firstErr = cmp.Or(firstErr, fmt.Errorf("step %s failed: %w", name, err))
+			continue
+		}
+		e.renderer.RenderStepSuccess(name)
 	}
 
-	e.runtime.JobContext.Status = "success"
-	return nil
+	return firstErr
+}
+
+// stepCondition evaluates the if: of step just before it would run, against
+// the state of the job so far: the outcomes and outputs of earlier steps, and
+// the env including variables they set through GITHUB_ENV.
+func (e *Executor) stepCondition(step *Step) (*EvaluationResult, error) {
+	evaluator, err := e.runtime.evaluator(&Step{})
+	if err != nil {
+		return nil, err
+	}
+
+	return evaluator.EvaluateCondition(step.If)
+}
+
+// stepName returns the name of step with its expressions evaluated.
+func (e *Executor) stepName(step *Step) (string, error) {
+	if !strings.Contains(step.Name, "${{") {
+		return step.Name, nil
+	}
+
+	evaluator, err := e.runtime.evaluator(step)
+	if err != nil {
+		return "", err
+	}
+
+	return evaluator.Interpolate(step.Name)
+}
+
+// runStep executes a step, cancelling it once it runs past its
+// timeout-minutes. A step that times out returns a TimeoutError.
+func (e *Executor) runStep(ctx context.Context, step *Step, jobContext *Context) error {
+	minutes, err := timeoutMinutes(step.TimeoutMinutes, jobContext)
+	if err != nil {
+		return err
+	}
+
+	stepCtx, cancel := withTimeout(ctx, minutes)
+	defer cancel()
+
+	err = e.executeStep(stepCtx, step, jobContext)
+
+	var timeout *TimeoutError
+	if err != nil && ctx.Err() == nil && errors.As(context.Cause(stepCtx), &timeout) {
+		return timeout
+	}
+
+	return err
+}
+
+// cancelReason describes why ctx was cancelled.
+func cancelReason(ctx context.Context) string {
+	cause := context.Cause(ctx)
+
+	var timeout *TimeoutError
+	switch {
+	case errors.As(cause, &timeout):
+		return timeout.Error()
+	case cause == nil || errors.Is(cause, context.Canceled):
+		return "the run was cancelled"
+	}
+
+	return cause.Error()
+}
+
+// recordStep stores the outcome and conclusion of a step, which differ when
+// continue-on-error lets a failed step pass, and exposes them to later steps
+// through the steps context.
+func (e *Executor) recordStep(jobContext *Context, step *Step, outcome, conclusion string) {
+	if e.runtime.StepContext == nil || e.runtime.StepContext.Step != step {
+		e.runtime.StepContext = &ExecutionStepContext{
+			Step:    step,
+			Outputs: make(map[string]string),
+		}
+	}
+	e.runtime.StepContext.Outcome = outcome
+	e.runtime.StepContext.Conclusion = conclusion
+
+	if step.ID == "" {
+		return
+	}
+
+	jobContext.Steps[step.ID] = StepContext{
+		Outcome:    outcome,
+		Conclusion: conclusion,
+		Outputs:    e.runtime.StepOutputs[step.ID],
+	}
 }
 
 // executeStep runs a single step.
@@ -257,34 +983,50 @@ func (e *Executor) executeStep(ctx context.Context, step *Step, triggerContext *
 		Step:    step,
 		Outputs: make(map[string]string),
 	}
+	e.runtime.Context = triggerContext
 
 	for _, executor := range e.executors {
 		if executor.CanExecute(step) {
 			result, err := executor.Execute(ctx, step, e.runtime)
-			if err != nil {
-				e.runtime.StepContext.Outcome = "failure"
-				e.runtime.StepContext.Conclusion = "failure"
-				e.renderer.RenderStepError(step.Name, err)
-				return err
+
+			// As on a runner, file commands are read whatever the step's
+			// outcome, so that what a failed step wrote is neither lost nor
+			// credited to the step after it. Invalid ones fail the step.
+			fileErr := e.processStepOutputFiles(step)
+			if fileErr != nil {
+				fileErr = fmt.Errorf("processing file commands: %w", fileErr)
 			}
 
-			if result.Success {
-				e.runtime.StepContext.Outcome = "success"
-				e.runtime.StepContext.Conclusion = "success"
-			} else {
+			if err != nil || !result.Success || fileErr != nil {
 				e.runtime.StepContext.Outcome = "failure"
 				e.runtime.StepContext.Conclusion = "failure"
+			}
+
+			switch {
+			case err != nil:
+				if ctx.Err() == nil {
+					e.renderer.RenderStepError(step.Name, err)
+					if fileErr != nil {
+						e.renderer.RenderStepError(step.Name, fileErr)
+					}
+				}
+				return err
+			case !result.Success:
+				if fileErr != nil {
+					e.renderer.RenderStepError(step.Name, fileErr)
+				}
 				return fmt.Errorf("step failed with exit code %d", result.ExitCode)
+			case fileErr != nil:
+				e.renderer.RenderStepError(step.Name, fileErr)
+				return fileErr
 			}
 
+			e.runtime.StepContext.Outcome = "success"
+			e.runtime.StepContext.Conclusion = "success"
 			for k, v := range result.Outputs {
 				e.runtime.StepContext.Outputs[k] = v
 			}
 
-			if err := e.processStepOutputFiles(step.ID); err != nil {
-				e.renderer.RenderWarning("failed to process output files: " + err.Error())
-			}
-
 			return nil
 		}
 	}
@@ -299,7 +1041,7 @@ func (e *Executor) SetWorkingDirectory(workingDir string) {
 
 // setupTempDirectory creates a temporary directory for GitHub environment files.
 func (e *Executor) setupTempDirectory() error {
-	tempDir, err := os.MkdirTemp("", "rehearse-github-")
+	tempDir, err := makeTempDir("", "rehearse-github-")
 	if err != nil {
 		return fmt.Errorf("creating temp directory: %w", err)
 	}
@@ -308,6 +1050,21 @@ func (e *Executor) setupTempDirectory() error {
 	return nil
 }
 
+// makeTempDir creates a temporary directory as os.MkdirTemp does, readable by
+// the user a container started with --user runs as, for whom it would
+// otherwise be off limits.
+func makeTempDir(dir, pattern string) (string, error) {
+	tempDir, err := os.MkdirTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tempDir, 0o755); err != nil {
+		os.RemoveAll(tempDir)
+		return "", err
+	}
+	return tempDir, nil
+}
+
 // cleanupTempDirectory removes the temporary directory.
 func (e *Executor) cleanupTempDirectory() {
 	if e.runtime.TempDir != "" {
@@ -316,134 +1073,89 @@ func (e *Executor) cleanupTempDirectory() {
 	}
 }
 
-// processStepOutputFiles processes GITHUB_ENV and GITHUB_OUTPUT files after step execution.
-func (e *Executor) processStepOutputFiles(stepID string) error {
+// processStepOutputFiles applies what a step wrote to its file commands, then
+// empties them for the next step: variables set through GITHUB_ENV and
+// GITHUB_OUTPUT, directories added through GITHUB_PATH, markdown written to
+// GITHUB_STEP_SUMMARY, and state saved through GITHUB_STATE, which goes to
+// the post hook of the step's action.
+func (e *Executor) processStepOutputFiles(step *Step) error {
 	if e.runtime.TempDir == "" {
 		return nil
 	}
 
-	envFile := e.runtime.TempDir + "/GITHUB_ENV"
-	if content, err := os.ReadFile(envFile); err == nil && len(content) > 0 {
-		contentStr := strings.TrimSpace(string(content))
-		contentStr = strings.ReplaceAll(contentStr, "\x00", "") // Remove null bytes
-
-		if contentStr != "" {
-			lines := strings.Split(contentStr, "\n")
-			for _, line := range lines {
-				if line = strings.TrimSpace(line); line != "" {
-					if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
-						key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
-						if key != "" { // Only add non-empty keys
-							e.runtime.DynamicEnv[key] = value
-							e.renderer.RenderEnvironmentSet(key, value)
-						}
-					}
-				}
-			}
-		}
-
-		if err := os.WriteFile(envFile, []byte{}, 0600); err != nil {
-			return fmt.Errorf("failed to clear GITHUB_ENV file: %w", err)
-		}
+	env, err := readFileCommand(filepath.Join(e.runtime.TempDir, "GITHUB_ENV"))
+	if err != nil {
+		return fmt.Errorf("GITHUB_ENV: %w", err)
+	}
+	for _, v := range env {
+		e.runtime.DynamicEnv[v.Name] = v.Value
+		e.renderer.RenderEnvironmentSet(v.Name, v.Value)
 	}
 
-	outputFile := e.runtime.TempDir + "/GITHUB_OUTPUT"
-	if content, err := os.ReadFile(outputFile); err == nil && len(content) > 0 {
-		if e.runtime.StepOutputs[stepID] == nil {
-			e.runtime.StepOutputs[stepID] = make(map[string]string)
-		}
+	outputs, err := readFileCommand(filepath.Join(e.runtime.TempDir, "GITHUB_OUTPUT"))
+	if err != nil {
+		return fmt.Errorf("GITHUB_OUTPUT: %w", err)
+	}
+	if len(outputs) > 0 && e.runtime.StepOutputs[step.ID] == nil {
+		e.runtime.StepOutputs[step.ID] = make(map[string]string)
+	}
+	for _, v := range outputs {
+		e.runtime.StepOutputs[step.ID][v.Name] = v.Value
+		e.renderer.RenderOutputSet(step.ID, v.Name, v.Value)
+	}
 
-		contentStr := strings.TrimSpace(string(content))
-		contentStr = strings.ReplaceAll(contentStr, "\x00", "") // Remove null bytes
+	dirs, err := readPathFile(filepath.Join(e.runtime.TempDir, "GITHUB_PATH"))
+	if err != nil {
+		return fmt.Errorf("GITHUB_PATH: %w", err)
+	}
+	for _, dir := range dirs {
+		e.runtime.addPath(dir)
+	}
 
-		if contentStr != "" {
-			lines := strings.Split(contentStr, "\n")
-			for _, line := range lines {
-				if line = strings.TrimSpace(line); line != "" {
-					if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
-						key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
-						if key != "" { // Only add non-empty keys
-							e.runtime.StepOutputs[stepID][key] = value
-							e.renderer.RenderOutputSet(stepID, key, value)
-						}
-					}
-				}
-			}
-		}
+	summary, err := takeFile(filepath.Join(e.runtime.TempDir, "GITHUB_STEP_SUMMARY"))
+	if err != nil {
+		return fmt.Errorf("GITHUB_STEP_SUMMARY: %w", err)
+	}
+	if strings.TrimSpace(summary) != "" {
+		e.runtime.Summaries = append(e.runtime.Summaries, summary)
+	}
 
-		if err := os.WriteFile(outputFile, []byte{}, 0600); err != nil {
-			return fmt.Errorf("failed to clear GITHUB_OUTPUT file: %w", err)
-		}
+	state, err := readFileCommand(filepath.Join(e.runtime.TempDir, "GITHUB_STATE"))
+	if err != nil {
+		return fmt.Errorf("GITHUB_STATE: %w", err)
 	}
+	e.runtime.saveState(step, state)
 
 	return nil
 }
 
-// processJobOutputs processes job outputs based on the job's outputs configuration.
-func (e *Executor) processJobOutputs(job *Job) {
+// processJobOutputs evaluates the job's outputs once its steps have run.
+func (e *Executor) processJobOutputs(job *Job) error {
 	if len(job.Outputs) == 0 {
-		return
+		return nil
 	}
 
 	if e.runtime.JobContext == nil {
-		return
-	}
-
-	e.renderer.RenderJobOutputsStart()
-	for outputName, outputExpression := range job.Outputs {
-		value := e.evaluateOutputExpression(outputExpression)
-
-		e.runtime.JobContext.Outputs[outputName] = value
-		e.renderer.RenderJobOutput(outputName, value)
+		return nil
 	}
-}
 
-// evaluateOutputExpression evaluates a simple output expression.
-// This is a basic implementation that handles common patterns like ${{ steps.stepid.outputs.outputname }}
-func (e *Executor) evaluateOutputExpression(expression string) string {
-	// Remove ${{ }} wrapper if present, handling various whitespace
-	expr := strings.TrimSpace(expression)
-	if strings.HasPrefix(expr, "${{") && strings.HasSuffix(expr, "}}") {
-		// Extract content between ${{ and }}
-		inner := expr[3 : len(expr)-2]
-		// Clean up all types of whitespace (spaces, tabs, newlines)
-		inner = strings.TrimSpace(inner)
-		// Normalize internal whitespace - replace any whitespace sequences with single spaces
-		parts := strings.Fields(inner)
-		expr = strings.Join(parts, " ")
-	}
-
-	// Handle steps.stepid.outputs.outputname pattern
-	if strings.HasPrefix(expr, "steps.") && strings.Contains(expr, ".outputs.") {
-		parts := strings.Split(expr, ".")
-		if len(parts) >= 4 && parts[0] == "steps" && parts[2] == "outputs" {
-			stepID := parts[1]
-			outputName := parts[3]
-
-			if stepOutputs, exists := e.runtime.StepOutputs[stepID]; exists {
-				if value, exists := stepOutputs[outputName]; exists {
-					return value
-				}
-			}
-		}
+	evaluator, err := e.runtime.evaluator(&Step{})
+	if err != nil {
+		return err
 	}
 
-	if strings.HasPrefix(expr, "env.") {
-		envVar := expr[4:] // Remove "env." prefix
-		if value, exists := e.runtime.DynamicEnv[envVar]; exists {
-			return value
+	e.renderer.RenderJobOutputsStart()
+	for _, outputName := range slices.Sorted(maps.Keys(job.Outputs)) {
+		value, err := evaluator.Interpolate(job.Outputs[outputName])
+		if err != nil {
+			return fmt.Errorf("output %s: %w", outputName, err)
 		}
 
-		return ""
-	}
-
-	// If we get here, it's either a literal value or an unresolved expression
-	// If it looks like an expression that we couldn't resolve, return empty string
-	if strings.HasPrefix(expr, "steps.") || strings.HasPrefix(expr, "env.") {
-		return ""
+		e.runtime.JobContext.Outputs[outputName] = value
+		e.renderer.RenderJobOutput(outputName, value)
 	}
 
-	return expression
+	return nil
 }
 
 // getCurrentTime returns current unix timestamp in seconds.
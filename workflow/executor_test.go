@@ -1,11 +1,18 @@
 package workflow
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/goccy/go-yaml"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestExecutor_NewExecutor(t *testing.T) {
@@ -26,32 +33,21 @@ func TestExecutor_NewExecutor(t *testing.T) {
 func TestExecutor_executeStep_ShellStep(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	mockGit := NewMockGitRepo()
-	executor := NewExecutor(&Analyzer{}, mockDocker, mockGit)
+	executor := newTestExecutor(t, mockDocker, mockGit)
 
+	executor.runtime.RunnerImage = "ubuntu:latest"
 	step := CreateTestStep("test-step", "Test Step", "echo 'Hello World'")
 
-	expectedConfig := &ContainerConfig{
-		Image:      "ubuntu:latest",
-		Cmd:        []string{"sh", "-c", "echo 'Hello World'"},
-		WorkingDir: "/github/workspace",
-		Volumes: []VolumeMount{
-			{Source: "/tmp/test", Target: "/github/workspace", Type: "bind"},
-		},
-	}
-
 	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil)
 	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
-		return config.Image == expectedConfig.Image &&
-			len(config.Cmd) == 3 &&
-			config.Cmd[0] == "sh" &&
-			config.Cmd[1] == "-c" &&
-			config.Cmd[2] == "echo 'Hello World'" &&
-			config.WorkingDir == expectedConfig.WorkingDir
+		return config.Image == "ubuntu:latest" &&
+			config.WorkingDir == "/github/workspace"
 	})).Return("container-123", nil)
-
 	mockDocker.On("StartContainer", mock.Anything, "container-123").Return(nil)
-	mockDocker.On("StopContainer", mock.Anything, "container-123").Return(nil)
-	mockDocker.On("RemoveContainer", mock.Anything, "container-123").Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "container-123", mock.MatchedBy(func(config *ExecConfig) bool {
+		return stepScript(executor.runtime.TempDir, config) == "echo 'Hello World'" &&
+			config.WorkingDir == "/github/workspace"
+	})).Return(&ExecResult{}, nil)
 
 	ctx := t.Context()
 	err := executor.executeStep(ctx, step, &Context{})
@@ -64,7 +60,7 @@ func TestExecutor_executeStep_ShellStep(t *testing.T) {
 func TestExecutor_executeStep_ActionStep(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	mockGit := NewMockGitRepo()
-	executor := NewExecutor(&Analyzer{}, mockDocker, mockGit)
+	executor := newTestExecutor(t, mockDocker, mockGit)
 
 	step := CreateTestActionStep("checkout", "Checkout", "actions/checkout@v4", map[string]string{
 		"token": "github_pat_123",
@@ -77,6 +73,8 @@ func TestExecutor_executeStep_ActionStep(t *testing.T) {
 	mockDocker.On("PullImage", mock.Anything, "actions/checkout:v4").Return(nil)
 	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("action-container-123", nil)
 	mockDocker.On("StartContainer", mock.Anything, "action-container-123").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "action-container-123").Return(&ExecResult{}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "action-container-123").Return(0, nil)
 	mockDocker.On("StopContainer", mock.Anything, "action-container-123").Return(nil)
 	mockDocker.On("RemoveContainer", mock.Anything, "action-container-123").Return(nil)
 
@@ -91,7 +89,7 @@ func TestExecutor_executeStep_ActionStep(t *testing.T) {
 func TestExecutor_executeStep_NoExecutorFound(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	mockGit := NewMockGitRepo()
-	executor := NewExecutor(&Analyzer{}, mockDocker, mockGit)
+	executor := newTestExecutor(t, mockDocker, mockGit)
 
 	step := &Step{
 		ID:   "invalid-step",
@@ -108,7 +106,7 @@ func TestExecutor_executeStep_NoExecutorFound(t *testing.T) {
 func TestExecutor_executeJob(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	mockGit := NewMockGitRepo()
-	executor := NewExecutor(&Analyzer{}, mockDocker, mockGit)
+	executor := newTestExecutor(t, mockDocker, mockGit)
 
 	job := &Job{
 		Name:   "test-job",
@@ -119,30 +117,66 @@ func TestExecutor_executeJob(t *testing.T) {
 		},
 	}
 
-	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil).Twice()
+	// Both steps run in the same container, which lives as long as the job.
+	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil).Once()
 	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("container-1", nil).Once()
 	mockDocker.On("StartContainer", mock.Anything, "container-1").Return(nil).Once()
+	mockDocker.On("ExecInContainer", mock.Anything, "container-1", mock.AnythingOfType("*workflow.ExecConfig")).Return(&ExecResult{}, nil).Twice()
 	mockDocker.On("StopContainer", mock.Anything, "container-1").Return(nil).Once()
 	mockDocker.On("RemoveContainer", mock.Anything, "container-1").Return(nil).Once()
 
-	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("container-2", nil).Once()
-	mockDocker.On("StartContainer", mock.Anything, "container-2").Return(nil).Once()
-	mockDocker.On("StopContainer", mock.Anything, "container-2").Return(nil).Once()
-	mockDocker.On("RemoveContainer", mock.Anything, "container-2").Return(nil).Once()
-
 	ctx := t.Context()
 	err := executor.executeJob(ctx, job, &Context{})
 
 	assert.NoError(t, err)
 	assert.Equal(t, "success", executor.runtime.JobContext.Status)
 	assert.GreaterOrEqual(t, executor.runtime.JobContext.EndTime, executor.runtime.JobContext.StartTime)
+	assert.Nil(t, executor.runtime.JobContainer)
+	mockDocker.AssertExpectations(t)
+}
+
+func TestExecutor_executeJob_NonRootUser(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor, err := newTestExecutor(t, mockDocker, NewMockGitRepo()).forJob("test-job")
+	require.NoError(t, err)
+
+	job := &Job{
+		Name:      "test-job",
+		RunsOn:    RunsOn{Labels: []string{"ubuntu-latest"}},
+		Container: &Container{Image: "node:20", Options: "--user 1001"},
+		Steps:     []Step{{ID: "step1", Name: "Step 1", Run: "echo hi >> \"$GITHUB_OUTPUT\""}},
+	}
+
+	// The step runs as a user who owns neither the temp directory nor the
+	// file commands, so both must be open to others.
+	mode := func(name string) os.FileMode {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		return info.Mode().Perm()
+	}
+
+	mockDocker.On("PullImage", mock.Anything, "node:20").Return(nil).Once()
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.User == "1001"
+	})).Return("container-1", nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, "container-1").Return(nil).Once()
+	mockDocker.On("ExecInContainer", mock.Anything, "container-1", mock.AnythingOfType("*workflow.ExecConfig")).Run(func(args mock.Arguments) {
+		assert.Equal(t, os.FileMode(0o755), mode(executor.runtime.TempDir))
+		for _, name := range fileCommands {
+			assert.Equal(t, os.FileMode(0o666), mode(filepath.Join(executor.runtime.TempDir, name)), name)
+		}
+	}).Return(&ExecResult{}, nil).Once()
+	mockDocker.On("StopContainer", mock.Anything, "container-1").Return(nil).Once()
+	mockDocker.On("RemoveContainer", mock.Anything, "container-1").Return(nil).Once()
+
+	require.NoError(t, executor.executeJob(t.Context(), job, &Context{}))
 	mockDocker.AssertExpectations(t)
 }
 
 func TestExecutor_executeJob_StepFailure(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	mockGit := NewMockGitRepo()
-	executor := NewExecutor(&Analyzer{}, mockDocker, mockGit)
+	executor := newTestExecutor(t, mockDocker, mockGit)
 
 	job := &Job{
 		Name:   "failing-job",
@@ -153,7 +187,11 @@ func TestExecutor_executeJob_StepFailure(t *testing.T) {
 	}
 
 	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil)
-	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("", assert.AnError)
+	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("container-1", nil)
+	mockDocker.On("StartContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "container-1", mock.AnythingOfType("*workflow.ExecConfig")).Return(&ExecResult{ExitCode: 1}, nil)
+	mockDocker.On("StopContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "container-1").Return(nil)
 
 	ctx := t.Context()
 	err := executor.executeJob(ctx, job, &Context{})
@@ -161,6 +199,160 @@ func TestExecutor_executeJob_StepFailure(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "step Failing Step failed")
 	assert.Equal(t, "failure", executor.runtime.JobContext.Status)
+	mockDocker.AssertExpectations(t)
+}
+
+func TestExecutor_executeJob_ContainerStartFailure(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	job := &Job{
+		Name:   "broken-job",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps:  []Step{{ID: "step", Name: "Step", Run: "true"}},
+	}
+
+	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("container-1", nil)
+	mockDocker.On("StartContainer", mock.Anything, "container-1").Return(assert.AnError)
+	mockDocker.On("StopContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "container-1").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Contains(t, err.Error(), "starting job container")
+	assert.Equal(t, "failure", executor.runtime.JobContext.Status)
+	mockDocker.AssertExpectations(t)
+	mockDocker.AssertNotCalled(t, "ExecInContainer", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExecutor_executeJob_NoCleanup(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+	executor.SetCleanup(false)
+
+	job := &Job{
+		Name:   "kept-job",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps:  []Step{{ID: "step", Name: "Step", Run: "true"}},
+	}
+
+	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("container-1", nil)
+	mockDocker.On("StartContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "container-1", mock.AnythingOfType("*workflow.ExecConfig")).Return(&ExecResult{}, nil)
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+	mockDocker.AssertNotCalled(t, "StopContainer", mock.Anything, mock.Anything)
+	mockDocker.AssertNotCalled(t, "RemoveContainer", mock.Anything, mock.Anything)
+}
+
+func TestExecutor_executeJob_Expressions(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	container := &Container{
+		Image: "node:${{ matrix.node }}",
+		Env:   map[string]string{"NODE_ENV": "${{ matrix.env }}"},
+	}
+	job := &Job{
+		Name:      "test (20, ci)",
+		Container: container,
+		Steps:     []Step{{ID: "test", Name: "Test on node ${{ matrix.node }}", Run: "npm test"}},
+		Outputs:   map[string]string{"node": "${{ matrix.node }}", "outcome": "${{ steps.test.outcome }}"},
+	}
+
+	mockDocker.On("PullImage", mock.Anything, "node:20").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.Image == "node:20" && slices.Contains(config.Env, "NODE_ENV=ci")
+	})).Return("container-1", nil)
+	mockDocker.On("StartContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "container-1", mock.AnythingOfType("*workflow.ExecConfig")).Return(&ExecResult{}, nil)
+	mockDocker.On("StopContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "container-1").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, (&Context{}).withMatrix(map[string]any{"node": 20, "env": "ci"}))
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+	assert.Equal(t, map[string]string{"node": "20", "outcome": "success"}, executor.runtime.JobContext.Outputs)
+	assert.Equal(t, "Test on node 20", executor.runtime.StepContext.Step.Name)
+
+	// The container is shared with the job's other matrix instances.
+	assert.Equal(t, "node:${{ matrix.node }}", container.Image)
+}
+
+func TestExecutor_executeJob_OutputEvaluationFailure(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	job := &Job{
+		Name:    "outputs-job",
+		RunsOn:  RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps:   []Step{{ID: "action", Name: "Action", Uses: "docker://alpine:latest"}},
+		Outputs: map[string]string{"version": "${{ steps.action.outputs.version == }}"},
+	}
+
+	mockDocker.On("PullImage", mock.Anything, "alpine:latest").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("action-container", nil)
+	mockDocker.On("StartContainer", mock.Anything, "action-container").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "action-container").Return(&ExecResult{}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "action-container").Return(0, nil)
+	mockDocker.On("StopContainer", mock.Anything, "action-container").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "action-container").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	assert.ErrorContains(t, err, "evaluating outputs: output version")
+	assert.Equal(t, "failure", executor.runtime.JobContext.Status)
+}
+
+func TestExecutor_executeJob_NoRunSteps(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	mockGit := NewMockGitRepo()
+	executor := newTestExecutor(t, mockDocker, mockGit)
+
+	job := &Job{
+		Name:   "action-job",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps:  []Step{{ID: "action", Name: "Action", Uses: "docker://alpine:latest"}},
+	}
+
+	mockDocker.On("PullImage", mock.Anything, "alpine:latest").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("action-container", nil)
+	mockDocker.On("StartContainer", mock.Anything, "action-container").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "action-container").Return(&ExecResult{}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "action-container").Return(0, nil)
+	mockDocker.On("StopContainer", mock.Anything, "action-container").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "action-container").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+	mockDocker.AssertNumberOfCalls(t, "CreateContainer", 1)
+}
+
+func TestExecutor_executeJob_NoRunStepsUnmappedRunner(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	// A composite action's run steps would start the job container, so a
+	// job without run steps of its own still needs an image for its runner.
+	job := &Job{
+		Name:   "action-job",
+		RunsOn: RunsOn{Labels: []string{"windows-latest"}},
+		Steps:  []Step{{ID: "action", Name: "Action", Uses: "./.github/actions/build"}},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	assert.ErrorContains(t, err, "no image for runs-on windows-latest")
+	mockDocker.AssertNotCalled(t, "CreateContainer", mock.Anything, mock.Anything)
 }
 
 func TestExecutor_Execute_Integration(t *testing.T) {
@@ -175,7 +367,7 @@ func TestExecutor_Execute_Integration(t *testing.T) {
 }
 
 func TestRuntime_ContextManagement(t *testing.T) {
-	runtime := CreateTestRuntime("/tmp/test")
+	runtime := CreateTestRuntime(t, "/tmp/test")
 
 	assert.NotNil(t, runtime.JobContext)
 	assert.Equal(t, "test-job", runtime.JobContext.Job.Name)
@@ -211,3 +403,699 @@ func TestGetCurrentTime(t *testing.T) {
 	_ = before
 	_ = after
 }
+
+func newDAGWorkflow() *Workflow {
+	step := []Step{{ID: "run", Name: "Run", Run: "true"}}
+	return &Workflow{
+		Name: "DAG",
+		Jobs: map[string]Job{
+			"lint":   {RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}}, Steps: step},
+			"test":   {RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}}, Steps: step},
+			"deploy": {RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}}, Needs: Needs{Jobs: []string{"lint", "test"}}, Steps: step},
+		},
+	}
+}
+
+// jobEvents records when each job's steps start and finish.
+type jobEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (j *jobEvents) executor(delay time.Duration, fail string) StepExecutor {
+	return stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		name := runtime.JobContext.Job.Name
+
+		j.mu.Lock()
+		j.events = append(j.events, "start "+name)
+		j.mu.Unlock()
+
+		time.Sleep(delay)
+
+		j.mu.Lock()
+		j.events = append(j.events, "end "+name)
+		j.mu.Unlock()
+
+		if name == fail {
+			return &ExecutionStepResult{Success: false, ExitCode: 1}, nil
+		}
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	})
+}
+
+func TestExecutor_Execute_DAG(t *testing.T) {
+	wf := newDAGWorkflow()
+	recorder := &jobEvents{}
+
+	executor := newStrategyExecutor(wf, recorder.executor(20*time.Millisecond, ""))
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	assert.NoError(t, err)
+	assert.Len(t, recorder.events, 6)
+
+	// Independent jobs start before either of them finishes.
+	assert.ElementsMatch(t, []string{"start lint", "start test"}, recorder.events[:2])
+	assert.Equal(t, []string{"start deploy", "end deploy"}, recorder.events[4:])
+}
+
+func TestExecutor_Execute_MaxParallelJobs(t *testing.T) {
+	wf := newDAGWorkflow()
+	recorder := &recordingStepExecutor{delay: 20 * time.Millisecond}
+
+	executor := newStrategyExecutor(wf, recorder)
+	executor.SetMaxParallelJobs(1)
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	assert.NoError(t, err)
+	assert.Len(t, recorder.ran, 3)
+	assert.Equal(t, int32(1), recorder.peak)
+}
+
+func TestExecutor_Execute_DependencyFailure(t *testing.T) {
+	wf := newDAGWorkflow()
+	recorder := &jobEvents{}
+
+	executor := newStrategyExecutor(wf, recorder.executor(0, "lint"))
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	assert.ErrorContains(t, err, "job lint failed")
+	assert.NotContains(t, recorder.events, "start deploy")
+}
+
+func TestExecutor_Execute_StatusChecks(t *testing.T) {
+	runsOn := RunsOn{Labels: []string{"ubuntu-latest"}}
+	wf := &Workflow{
+		Name: "Status",
+		Jobs: map[string]Job{
+			"build": {
+				RunsOn: runsOn,
+				Steps: []Step{
+					{Name: "compile", Run: "false"},
+					{Name: "cleanup", If: "failure()", Run: "true"},
+					{Name: "package", Run: "true"},
+					{Name: "report", If: "always()", Run: "true"},
+				},
+			},
+			"deploy": {
+				RunsOn: runsOn,
+				Needs:  Needs{Jobs: []string{"build"}},
+				Steps:  []Step{{Name: "ship", Run: "true"}},
+			},
+			"notify": {
+				RunsOn: runsOn,
+				Needs:  Needs{Jobs: []string{"deploy"}},
+				If:     "always()",
+				Steps:  []Step{{Name: "message", Run: "true"}},
+			},
+			"rollback": {
+				RunsOn: runsOn,
+				Needs:  Needs{Jobs: []string{"deploy"}},
+				If:     "failure()",
+				Steps:  []Step{{Name: "revert", Run: "true"}},
+			},
+			"celebrate": {
+				RunsOn: runsOn,
+				Needs:  Needs{Jobs: []string{"deploy"}},
+				If:     "success()",
+				Steps:  []Step{{Name: "party", Run: "true"}},
+			},
+		},
+	}
+
+	var (
+		mu  sync.Mutex
+		ran []string
+	)
+	executor := newStrategyExecutor(wf, stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		mu.Lock()
+		ran = append(ran, runtime.JobContext.Job.Name+"/"+step.Name)
+		mu.Unlock()
+
+		if step.Run == "false" {
+			return &ExecutionStepResult{Success: false, ExitCode: 1}, nil
+		}
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	}))
+
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	assert.ErrorContains(t, err, "job build failed")
+	assert.ElementsMatch(t, []string{
+		"build/compile",
+		"build/cleanup",
+		"build/report",
+		"notify/message",
+		"rollback/revert",
+	}, ran)
+}
+
+func TestNeedsStatus(t *testing.T) {
+	job := Job{Needs: Needs{Jobs: []string{"a", "b"}}}
+
+	tests := []struct {
+		name     string
+		results  map[string]string
+		statuses map[string]string
+		expected string
+	}{
+		{
+			name:     "all succeeded",
+			results:  map[string]string{"a": "success", "b": "success"},
+			statuses: map[string]string{"a": "success", "b": "success"},
+			expected: "success",
+		},
+		{
+			name:     "one failed",
+			results:  map[string]string{"a": "success", "b": "failure"},
+			statuses: map[string]string{"a": "success", "b": "failure"},
+			expected: "failure",
+		},
+		{
+			name:     "failure inherited through a skipped job",
+			results:  map[string]string{"a": "skipped", "b": "success"},
+			statuses: map[string]string{"a": "failure", "b": "success"},
+			expected: "failure",
+		},
+		{
+			name:     "one skipped",
+			results:  map[string]string{"a": "skipped", "b": "success"},
+			statuses: map[string]string{"a": "success", "b": "success"},
+			expected: "skipped",
+		},
+		{
+			name:     "one cancelled",
+			results:  map[string]string{"a": "cancelled", "b": "skipped"},
+			statuses: map[string]string{"a": "cancelled", "b": "success"},
+			expected: "cancelled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, needsStatus(t.Context(), job, tt.results, tt.statuses))
+		})
+	}
+}
+
+func TestExecutor_Execute_ContinueOnError(t *testing.T) {
+	var wf Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: Continue on error
+on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    continue-on-error: ${{ matrix.experimental }}
+    strategy:
+      matrix:
+        version: [stable]
+        experimental: [false]
+        include:
+          - version: nightly
+            experimental: true
+    steps:
+      - id: flaky
+        run: flaky
+        continue-on-error: true
+      - id: check
+        if: steps.flaky.outcome == 'failure' && steps.flaky.conclusion == 'success'
+        run: check
+      - run: tests-${{ matrix.version }}
+  after:
+    runs-on: ubuntu-latest
+    needs: test
+    steps:
+      - run: after
+`), &wf))
+
+	var (
+		mu  sync.Mutex
+		ran []string
+	)
+	executor := newStrategyExecutor(&wf, stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		name := runtime.JobContext.Job.Name + "/" + step.Run
+
+		mu.Lock()
+		ran = append(ran, name)
+		mu.Unlock()
+
+		if step.Run == "flaky" || name == "test (nightly, true)/tests-${{ matrix.version }}" {
+			return &ExecutionStepResult{Success: false, ExitCode: 1}, nil
+		}
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	}))
+
+	err := executor.Execute(t.Context(), &wf, executor.analyzer.ctx)
+
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"test (stable, false)/flaky",
+		"test (stable, false)/check",
+		"test (stable, false)/tests-${{ matrix.version }}",
+		"test (nightly, true)/flaky",
+		"test (nightly, true)/check",
+		"test (nightly, true)/tests-${{ matrix.version }}",
+		"after/after",
+	}, ran)
+}
+
+func TestExecutor_executeJob_StepOutcomeAndConclusion(t *testing.T) {
+	executor := newTestExecutor(t, NewMockDockerClient(), NewMockGitRepo())
+	executor.executors = []StepExecutor{stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		return &ExecutionStepResult{Success: step.Run != "false", ExitCode: 1, Outputs: make(map[string]string)}, nil
+	})}
+
+	job := &Job{
+		Name:   "test",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps: []Step{
+			{ID: "ignored", Name: "Ignored", Run: "false", ContinueOnError: "true"},
+			{ID: "skipped", Name: "Skipped", If: "steps.ignored.conclusion == 'failure'", Run: "true"},
+			{ID: "failed", Name: "Failed", Run: "false", ContinueOnError: "${{ steps.skipped.outcome != 'skipped' }}"},
+		},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	assert.ErrorContains(t, err, "step Failed failed")
+	assert.Equal(t, "failure", executor.runtime.JobContext.Status)
+	assert.Equal(t, "failure", executor.runtime.StepContext.Outcome)
+	assert.Equal(t, "failure", executor.runtime.StepContext.Conclusion)
+}
+
+func TestExecutor_executeJob_FailedStepFileCommands(t *testing.T) {
+	executor := newTestExecutor(t, NewMockDockerClient(), NewMockGitRepo())
+	executor.executors = []StepExecutor{stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		if step.ID == "first" {
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_OUTPUT"), []byte("leaked=from-failed-step\n"), 0o600))
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_ENV"), []byte("FROM_FIRST=yes\n"), 0o600))
+			return &ExecutionStepResult{Success: false, ExitCode: 1, Outputs: make(map[string]string)}, nil
+		}
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	})}
+
+	job := &Job{
+		Name:   "test",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps: []Step{
+			{ID: "first", Name: "First", Run: "exit 1", ContinueOnError: "true"},
+			{ID: "second", Name: "Second", Run: "true"},
+		},
+	}
+
+	require.NoError(t, executor.executeJob(t.Context(), job, &Context{}))
+
+	// What the failed step wrote is its own, not the next step's.
+	assert.Equal(t, map[string]string{"leaked": "from-failed-step"}, executor.runtime.StepOutputs["first"])
+	assert.Empty(t, executor.runtime.StepOutputs["second"])
+	assert.Equal(t, "yes", executor.runtime.DynamicEnv["FROM_FIRST"])
+}
+
+func TestExecutor_executeJob_StepConditionsSeeLiveState(t *testing.T) {
+	executor := newTestExecutor(t, NewMockDockerClient(), NewMockGitRepo())
+
+	var ran []string
+	executor.executors = []StepExecutor{stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		ran = append(ran, step.ID)
+		if step.ID == "detect" {
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_OUTPUT"), []byte("changed=true\n"), 0o600))
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_ENV"), []byte("DEPLOY=yes\n"), 0o600))
+		}
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	})}
+
+	job := &Job{
+		Name:   "test",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Env:    map[string]string{"STAGE": "prod"},
+		Steps: []Step{
+			{ID: "detect", Name: "Detect", Run: "true"},
+			{ID: "build", Name: "Build", If: "steps.detect.outputs.changed == 'true'", Run: "true"},
+			{ID: "unchanged", Name: "Unchanged", If: "steps.detect.outputs.changed == 'false'", Run: "true"},
+			{ID: "deploy", Name: "Deploy", If: "env.DEPLOY == 'yes' && env.STAGE == 'prod'", Run: "true"},
+			{ID: "after-build", Name: "After build", If: "steps.build.outcome == 'success'", Run: "true"},
+		},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"detect", "build", "deploy", "after-build"}, ran)
+}
+
+func TestExecutor_executeJob_NodeActionPostState(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	mockGit := NewMockGitRepo()
+	executor := newTestExecutor(t, mockDocker, mockGit)
+
+	mockGit.On("GetActionMetadata", mock.AnythingOfType("string")).Return(&ActionMetadata{
+		Name: "Cache",
+		Runs: ActionRuns{Using: "node20", Main: "main.js", Post: "post.js"},
+	}, nil)
+
+	// The main entry point saves state and logs a workflow command.
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return slices.Equal(config.Cmd, []string{"node", "main.js"})
+	})).Return("main-container", nil).Once()
+	mockDocker.On("GetContainerLogs", mock.Anything, "main-container").
+		Run(func(args mock.Arguments) {
+			require.NoError(t, os.WriteFile(filepath.Join(executor.runtime.TempDir, "GITHUB_STATE"), []byte("cache-key=linux-abc\n"), 0o600))
+		}).
+		Return(&ExecResult{Stdout: "::notice::Cache not found\n"}, nil).Once()
+
+	// The post entry point gets it back in its environment.
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return slices.Equal(config.Cmd, []string{"node", "post.js"}) && slices.Contains(config.Env, "STATE_cache-key=linux-abc")
+	})).Return("post-container", nil).Once()
+	mockDocker.On("GetContainerLogs", mock.Anything, "post-container").Return(&ExecResult{}, nil).Once()
+
+	for _, id := range []string{"main-container", "post-container"} {
+		mockDocker.On("StartContainer", mock.Anything, id).Return(nil).Once()
+		mockDocker.On("WaitForContainer", mock.Anything, id).Return(0, nil).Once()
+		mockDocker.On("StopContainer", mock.Anything, id).Return(nil).Once()
+		mockDocker.On("RemoveContainer", mock.Anything, id).Return(nil).Once()
+	}
+
+	job := &Job{
+		Name:   "build",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps:  []Step{{ID: "cache", Name: "Cache", Uses: "./.github/actions/cache"}},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+	require.Len(t, executor.runtime.Annotations, 1)
+	assert.Equal(t, "Cache not found", executor.runtime.Annotations[0].Message)
+}
+
+func TestExecutor_executeJob_NodeActionPreState(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	mockGit := NewMockGitRepo()
+	executor := newTestExecutor(t, mockDocker, mockGit)
+
+	mockGit.On("GetActionMetadata", mock.AnythingOfType("string")).Return(&ActionMetadata{
+		Name: "Setup",
+		Runs: ActionRuns{Using: "node20", Pre: "pre.js", Main: "main.js", Post: "post.js"},
+	}, nil)
+
+	// The pre entry point saves state before main runs.
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return slices.Equal(config.Cmd, []string{"node", "pre.js"})
+	})).Return("pre-container", nil).Once()
+	mockDocker.On("GetContainerLogs", mock.Anything, "pre-container").
+		Run(func(args mock.Arguments) {
+			require.NoError(t, os.WriteFile(filepath.Join(executor.runtime.TempDir, "GITHUB_STATE"), []byte("started=1\n"), 0o600))
+		}).
+		Return(&ExecResult{}, nil).Once()
+
+	// Main gets it and saves its own.
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return slices.Equal(config.Cmd, []string{"node", "main.js"}) && slices.Contains(config.Env, "STATE_started=1")
+	})).Return("main-container", nil).Once()
+	mockDocker.On("GetContainerLogs", mock.Anything, "main-container").
+		Run(func(args mock.Arguments) {
+			require.NoError(t, os.WriteFile(filepath.Join(executor.runtime.TempDir, "GITHUB_STATE"), []byte("path=/tmp/tool\n"), 0o600))
+		}).
+		Return(&ExecResult{}, nil).Once()
+
+	// Post gets both.
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return slices.Equal(config.Cmd, []string{"node", "post.js"}) &&
+			slices.Contains(config.Env, "STATE_started=1") && slices.Contains(config.Env, "STATE_path=/tmp/tool")
+	})).Return("post-container", nil).Once()
+	mockDocker.On("GetContainerLogs", mock.Anything, "post-container").Return(&ExecResult{}, nil).Once()
+
+	for _, id := range []string{"pre-container", "main-container", "post-container"} {
+		mockDocker.On("StartContainer", mock.Anything, id).Return(nil).Once()
+		mockDocker.On("WaitForContainer", mock.Anything, id).Return(0, nil).Once()
+		mockDocker.On("StopContainer", mock.Anything, id).Return(nil).Once()
+		mockDocker.On("RemoveContainer", mock.Anything, id).Return(nil).Once()
+	}
+
+	job := &Job{
+		Name:   "build",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps:  []Step{{ID: "setup", Name: "Setup", Uses: "./.github/actions/setup"}},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+}
+
+func TestExecutor_executeJob_NodeActionPreFails(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	mockGit := NewMockGitRepo()
+	executor := newTestExecutor(t, mockDocker, mockGit)
+
+	mockGit.On("GetActionMetadata", mock.AnythingOfType("string")).Return(&ActionMetadata{
+		Name: "Setup",
+		Runs: ActionRuns{Using: "node20", Pre: "pre.js", Main: "main.js"},
+	}, nil)
+
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return slices.Equal(config.Cmd, []string{"node", "pre.js"})
+	})).Return("pre-container", nil).Once()
+	mockDocker.On("GetContainerLogs", mock.Anything, "pre-container").Return(&ExecResult{}, nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, "pre-container").Return(nil).Once()
+	mockDocker.On("WaitForContainer", mock.Anything, "pre-container").Return(1, nil).Once()
+	mockDocker.On("StopContainer", mock.Anything, "pre-container").Return(nil).Once()
+	mockDocker.On("RemoveContainer", mock.Anything, "pre-container").Return(nil).Once()
+
+	job := &Job{
+		Name:   "build",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps:  []Step{{ID: "setup", Name: "Setup", Uses: "./.github/actions/setup"}},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	// Main never runs.
+	require.Error(t, err)
+	mockDocker.AssertExpectations(t)
+}
+
+func TestExecutor_executeJob_PostSteps(t *testing.T) {
+	executor := newTestExecutor(t, NewMockDockerClient(), NewMockGitRepo())
+	executor.SetSummaryFile(filepath.Join(t.TempDir(), "summary.md"))
+
+	var ran []string
+	post := func(name, condition string) PostStep {
+		return PostStep{
+			If: condition,
+			Run: func(ctx context.Context, runtime *Runtime, state map[string]string) (*ExecutionStepResult, error) {
+				ran = append(ran, name+":"+state["key"])
+				return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+			},
+		}
+	}
+
+	executor.executors = []StepExecutor{stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		ran = append(ran, step.ID)
+		switch step.ID {
+		case "cache", "setup":
+			hook := post("post-"+step.ID, "always()")
+			if step.ID == "setup" {
+				hook = post("post-"+step.ID, "success()")
+			}
+			hook.Step = step
+			runtime.PostSteps = append(runtime.PostSteps, hook)
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_STATE"), []byte("key="+step.ID+"-state\n"), 0o600))
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_STEP_SUMMARY"), []byte("- "+step.ID+"\n"), 0o600))
+		case "test":
+			return &ExecutionStepResult{Success: false, ExitCode: 1, Outputs: make(map[string]string)}, nil
+		}
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	})}
+
+	job := &Job{
+		Name:   "build",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps: []Step{
+			{ID: "cache", Uses: "actions/cache@v4"},
+			{ID: "setup", Uses: "actions/setup-go@v5"},
+			{ID: "test", Run: "go test ./..."},
+		},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+	require.Error(t, err)
+
+	// Hooks run in reverse, each with the state its step saved, and only if
+	// their condition holds once the job has failed.
+	assert.Equal(t, []string{"cache", "setup", "test", "post-cache:cache-state"}, ran)
+
+	require.NoError(t, executor.writeSummaryFile())
+	content, err := os.ReadFile(executor.summaryFile)
+	require.NoError(t, err)
+	assert.Equal(t, "## build\n\n- cache\n\n- setup\n", string(content))
+}
+
+func TestExecutor_executeJob_CollectsAnnotations(t *testing.T) {
+	executor := newTestExecutor(t, NewMockDockerClient(), NewMockGitRepo())
+	executor.executors = []StepExecutor{stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		runtime.Annotations = append(runtime.Annotations, Annotation{Level: "warning", Message: step.ID})
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	})}
+
+	job := &Job{
+		Name:   "lint",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps:  []Step{{ID: "vet", Run: "go vet"}, {ID: "staticcheck", Run: "staticcheck"}},
+	}
+
+	require.NoError(t, executor.executeJob(t.Context(), job, &Context{}))
+
+	assert.Equal(t, []Annotation{
+		{Level: "warning", Message: "vet"},
+		{Level: "warning", Message: "staticcheck"},
+	}, executor.Annotations())
+}
+
+func TestExecutor_Execute_JobConditionsSeeLiveResults(t *testing.T) {
+	runsOn := RunsOn{Labels: []string{"ubuntu-latest"}}
+	wf := &Workflow{
+		Name: "results",
+		Jobs: map[string]Job{
+			"build": {RunsOn: runsOn, Steps: []Step{{Name: "compile", Run: "false"}}},
+			"report": {
+				RunsOn: runsOn,
+				Needs:  Needs{Jobs: []string{"build"}},
+				If:     "always() && jobs.build.status == 'failure'",
+				Steps:  []Step{{Name: "report", Run: "true"}},
+			},
+			"publish": {
+				RunsOn: runsOn,
+				Needs:  Needs{Jobs: []string{"build"}},
+				If:     "always() && jobs.build.status == 'success'",
+				Steps:  []Step{{Name: "publish", Run: "true"}},
+			},
+		},
+	}
+
+	var (
+		mu  sync.Mutex
+		ran []string
+	)
+	executor := newStrategyExecutor(wf, stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		mu.Lock()
+		ran = append(ran, runtime.JobContext.Job.Name)
+		mu.Unlock()
+
+		return &ExecutionStepResult{Success: step.Run != "false", ExitCode: 1, Outputs: make(map[string]string)}, nil
+	}))
+
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	// The analyzer expected build to succeed; the conditions see that it failed.
+	assert.ErrorContains(t, err, "job build failed")
+	assert.ElementsMatch(t, []string{"build", "report"}, ran)
+}
+
+func TestExecutor_Execute_NeedsOutputs(t *testing.T) {
+	runsOn := RunsOn{Labels: []string{"ubuntu-latest"}}
+	wf := &Workflow{
+		Name: "handoff",
+		Jobs: map[string]Job{
+			"build": {
+				RunsOn:   runsOn,
+				Strategy: &Strategy{Matrix: &Matrix{Dimensions: []MatrixDimension{{Key: "arch", Values: []any{"amd64", "arm64"}}}}},
+				Steps:    []Step{{ID: "image", Name: "image", Run: "tag"}},
+				Outputs:  map[string]string{"tag": "${{ steps.image.outputs.tag }}"},
+			},
+			"deploy": {
+				RunsOn: runsOn,
+				Needs:  Needs{Jobs: []string{"build"}},
+				If:     "needs.build.result == 'success'",
+				Steps:  []Step{{Name: "deploy", Run: "deploy ${{ needs.build.outputs.tag }} after ${{ needs.build.result }}"}},
+			},
+		},
+	}
+
+	var (
+		mu       sync.Mutex
+		commands []string
+	)
+	executor := newStrategyExecutor(wf, stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		evaluator, err := runtime.evaluator(step)
+		require.NoError(t, err)
+		command, err := evaluator.Interpolate(step.Run)
+		require.NoError(t, err)
+
+		mu.Lock()
+		commands = append(commands, command)
+		mu.Unlock()
+
+		// Only one matrix instance sets the output; the other's empty value
+		// must not override it.
+		if step.Run == "tag" && runtime.Context.Matrix["arch"] == "amd64" {
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_OUTPUT"), []byte("tag=ghcr.io/acme/app:v1\n"), 0o600))
+		}
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	}))
+
+	require.NoError(t, executor.Execute(t.Context(), wf, executor.analyzer.ctx))
+	assert.Contains(t, commands, "deploy ghcr.io/acme/app:v1 after success")
+}
+
+func TestExecutor_Execute_DynamicMatrix(t *testing.T) {
+	runsOn := RunsOn{Labels: []string{"ubuntu-latest"}}
+	wf := &Workflow{
+		Name: "dynamic",
+		Jobs: map[string]Job{
+			"setup": {
+				RunsOn: runsOn,
+				Steps:  []Step{{ID: "plan", Name: "plan", Run: "plan"}},
+				Outputs: map[string]string{
+					"os":     "${{ steps.plan.outputs.os }}",
+					"matrix": "${{ steps.plan.outputs.matrix }}",
+				},
+			},
+			"vector": {
+				RunsOn:   runsOn,
+				Needs:    Needs{Jobs: []string{"setup"}},
+				Strategy: &Strategy{Matrix: &Matrix{Dimensions: []MatrixDimension{{Key: "os", Expression: "${{ fromJSON(needs.setup.outputs.os) }}"}}}},
+				Steps:    []Step{{Name: "test", Run: "vector ${{ matrix.os }}"}},
+			},
+			"whole": {
+				RunsOn:   runsOn,
+				Needs:    Needs{Jobs: []string{"setup"}},
+				Strategy: &Strategy{Matrix: &Matrix{Expression: "${{ fromJSON(needs.setup.outputs.matrix) }}"}},
+				Steps:    []Step{{Name: "test", Run: "whole ${{ matrix.go }} ${{ matrix.race }}"}},
+			},
+		},
+	}
+
+	var (
+		mu       sync.Mutex
+		commands []string
+	)
+	executor := newStrategyExecutor(wf, stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		evaluator, err := runtime.evaluator(step)
+		require.NoError(t, err)
+		command, err := evaluator.Interpolate(step.Run)
+		require.NoError(t, err)
+
+		mu.Lock()
+		commands = append(commands, command)
+		mu.Unlock()
+
+		if step.Run == "plan" {
+			output := "os=[\"ubuntu\", \"alpine\"]\nmatrix={\"go\": [\"1.24\", \"1.25\"], \"include\": [{\"go\": \"1.25\", \"race\": true}]}\n"
+			require.NoError(t, os.WriteFile(filepath.Join(runtime.TempDir, "GITHUB_OUTPUT"), []byte(output), 0o600))
+		}
+		return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+	}))
+
+	require.NoError(t, executor.Execute(t.Context(), wf, executor.analyzer.ctx))
+	assert.ElementsMatch(t, []string{
+		"plan",
+		"vector ubuntu",
+		"vector alpine",
+		"whole 1.24 ",
+		"whole 1.25 true",
+	}, commands)
+}
@@ -4,16 +4,17 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestShellStepExecutor_evaluateExpressions(t *testing.T) {
-	executor := CreateTestShellExecutor(NewMockDockerClient())
+func TestRuntime_evaluator(t *testing.T) {
 	runtime := createTestRuntimeWithOutputs()
 
 	tests := []struct {
 		name     string
 		input    string
 		expected string
+		wantErr  string
 	}{
 		{
 			name:     "no expressions",
@@ -66,157 +67,55 @@ func TestShellStepExecutor_evaluateExpressions(t *testing.T) {
 			expected: "echo ''",
 		},
 		{
-			name:     "complex multi-line with expressions",
-			input:    "echo 'App: ${{ env.APP_NAME }}'\necho 'Version: ${{ steps.version.outputs.number }}'",
-			expected: "echo 'App: MyApp'\necho 'Version: 1.2.3'",
+			name:     "step environment",
+			input:    "echo '${{ env.STEP_VAR }}'",
+			expected: "echo 'from step'",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := executor.evaluateExpressions(tt.input, runtime)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
-func TestShellStepExecutor_evaluateExpression(t *testing.T) {
-	executor := CreateTestShellExecutor(NewMockDockerClient())
-	runtime := createTestRuntimeWithOutputs()
-
-	tests := []struct {
-		name       string
-		expression string
-		expected   string
-	}{
 		{
-			name:       "valid step output",
-			expression: "steps.version.outputs.number",
-			expected:   "1.2.3",
+			name:     "other contexts",
+			input:    "echo '${{ github.repository }} on ${{ matrix.os }}'",
+			expected: "echo 'octo-org/app on ubuntu-latest'",
 		},
 		{
-			name:       "valid step output with different step",
-			expression: "steps.build.outputs.status",
-			expected:   "success",
+			name:     "operators and functions",
+			input:    "echo '${{ format('{0}-{1}', env.APP_NAME, steps.version.outputs.number) }}' ${{ steps.build.outputs.status == 'success' }}",
+			expected: "echo 'MyApp-1.2.3' true",
 		},
 		{
-			name:       "valid environment variable",
-			expression: "env.APP_NAME",
-			expected:   "MyApp",
+			name:    "unknown function",
+			input:   "echo '${{ toYAML(github) }}'",
+			wantErr: "unknown function: toYAML",
 		},
 		{
-			name:       "valid environment variable different var",
-			expression: "env.DEPLOY_STAGE",
-			expected:   "production",
+			name:    "unterminated expression",
+			input:   "echo '${{ env.APP_NAME'",
+			wantErr: "unterminated expression",
 		},
 		{
-			name:       "nonexistent step",
-			expression: "steps.missing.outputs.value",
-			expected:   "",
-		},
-		{
-			name:       "nonexistent output",
-			expression: "steps.version.outputs.missing",
-			expected:   "",
-		},
-		{
-			name:       "nonexistent environment variable",
-			expression: "env.MISSING_VAR",
-			expected:   "",
-		},
-		{
-			name:       "malformed step expression - missing outputs",
-			expression: "steps.version.number",
-			expected:   "",
-		},
-		{
-			name:       "malformed step expression - too few parts",
-			expression: "steps.version",
-			expected:   "",
-		},
-		{
-			name:       "malformed env expression",
-			expression: "environment.VAR",
-			expected:   "",
-		},
-		{
-			name:       "unknown expression type",
-			expression: "unknown.expression.type",
-			expected:   "",
-		},
-		{
-			name:       "empty expression",
-			expression: "",
-			expected:   "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := executor.evaluateExpression(tt.expression, runtime)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
-func TestExecutor_evaluateOutputExpression(t *testing.T) {
-	mockDocker := NewMockDockerClient()
-	mockGit := NewMockGitRepo()
-	executor := NewExecutor(&Analyzer{}, mockDocker, mockGit)
-
-	executor.runtime = createTestRuntimeWithOutputs()
-
-	tests := []struct {
-		name       string
-		expression string
-		expected   string
-	}{
-		{
-			name:       "step output with wrapper",
-			expression: "${{ steps.version.outputs.number }}",
-			expected:   "1.2.3",
-		},
-		{
-			name:       "step output without wrapper",
-			expression: "steps.version.outputs.number",
-			expected:   "1.2.3",
-		},
-		{
-			name:       "environment variable with wrapper",
-			expression: "${{ env.APP_NAME }}",
-			expected:   "MyApp",
-		},
-		{
-			name:       "environment variable without wrapper",
-			expression: "env.DEPLOY_STAGE",
-			expected:   "production",
-		},
-		{
-			name:       "nonexistent step output",
-			expression: "${{ steps.missing.outputs.value }}",
-			expected:   "",
-		},
-		{
-			name:       "literal string (not an expression)",
-			expression: "literal-value",
-			expected:   "literal-value",
-		},
-		{
-			name:       "complex expression with whitespace",
-			expression: "${{  steps.build.outputs.status  }}",
-			expected:   "success",
+			name:     "complex multi-line with expressions",
+			input:    "echo 'App: ${{ env.APP_NAME }}'\necho 'Version: ${{ steps.version.outputs.number }}'",
+			expected: "echo 'App: MyApp'\necho 'Version: 1.2.3'",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := executor.evaluateOutputExpression(tt.expression)
+			evaluator, err := runtime.evaluator(&Step{Env: map[string]string{"STEP_VAR": "from step"}})
+			require.NoError(t, err)
+
+			result, err := evaluator.Interpolate(tt.input)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
 
-// createTestRuntimeWithOutputs creates a runtime with test step outputs and environment variables.
+// createTestRuntimeWithOutputs creates a runtime with test step outputs and
+// environment variables, exposed to expressions through its context.
 func createTestRuntimeWithOutputs() *Runtime {
 	runtime := &Runtime{
 		DynamicEnv:  make(map[string]string),
@@ -242,5 +141,9 @@ func createTestRuntimeWithOutputs() *Runtime {
 		"test_count": "156",
 	}
 
+	runtime.Context = stepsContext(runtime.StepOutputs)
+	runtime.Context.GitHub.Repository = "octo-org/app"
+	runtime.Context.Matrix = map[string]any{"os": "ubuntu-latest"}
+
 	return runtime
 }
@@ -0,0 +1,199 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// fileCommands are the files through which steps pass information back to
+// the runner, each named after the variable that holds its path.
+var fileCommands = []string{"GITHUB_ENV", "GITHUB_OUTPUT", "GITHUB_PATH", "GITHUB_STATE", "GITHUB_STEP_SUMMARY"}
+
+// fileCommandEnv creates the file commands in tempDir that do not exist yet
+// and returns the variables that point steps at them, as seen from containers
+// that mount tempDir at /github/env.
+func fileCommandEnv(tempDir string) ([]string, error) {
+	env := make([]string, 0, len(fileCommands))
+	for _, name := range fileCommands {
+		path := filepath.Join(tempDir, name)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := createFileCommand(path); err != nil {
+				return nil, fmt.Errorf("failed to create %s file: %w", name, err)
+			}
+		}
+		env = append(env, name+"=/github/env/"+name)
+	}
+
+	return env, nil
+}
+
+// createFileCommand creates an empty file command that anyone can write to,
+// since a container started with --user may run its steps as a user other
+// than the one that owns the file. The mode is set after creating the file so
+// that the umask does not narrow it.
+func createFileCommand(path string) error {
+	if err := os.WriteFile(path, []byte{}, 0o666); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0o666)
+}
+
+// fileCommandMounts returns the mount that gives an action's container the
+// job's file commands, if it has any.
+func (r *Runtime) fileCommandMounts() []VolumeMount {
+	if r.TempDir == "" {
+		return nil
+	}
+	return []VolumeMount{{Source: r.TempDir, Target: "/github/env", Type: "bind"}}
+}
+
+// fileCommandVar is a variable a step sets through a file command such as
+// GITHUB_ENV or GITHUB_OUTPUT.
+type fileCommandVar struct {
+	Name  string
+	Value string
+}
+
+// parseFileCommand parses the contents of a GITHUB_ENV, GITHUB_OUTPUT or
+// GITHUB_STATE file as the runner does. Each variable is set either on one
+// line as NAME=value, or over several with a heredoc:
+//
+//	NAME<<DELIMITER
+//	first line
+//	second line
+//	DELIMITER
+//
+// Whichever of = and << comes first on a line decides its form. Names, values
+// and delimiters are taken as they are, spaces included. The value of a
+// heredoc is every line up to the one that is exactly the delimiter, which
+// must be there. Empty lines are skipped, but any other line that is neither
+// form is an error.
+func parseFileCommand(content string) ([]fileCommandVar, error) {
+	content = strings.ReplaceAll(content, "\x00", "")
+	lines := strings.Split(content, "\n")
+
+	var vars []fileCommandVar
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		lineNum := i + 1
+
+		equals := strings.Index(line, "=")
+		heredoc := strings.Index(line, "<<")
+
+		switch {
+		case line == "":
+			continue
+
+		case equals >= 0 && (heredoc < 0 || equals < heredoc):
+			name, value := line[:equals], line[equals+1:]
+			if name == "" {
+				return nil, fmt.Errorf("line %d: invalid format %q: name must not be empty", lineNum, line)
+			}
+			vars = append(vars, fileCommandVar{Name: name, Value: value})
+
+		case heredoc >= 0:
+			name, delimiter := line[:heredoc], line[heredoc+2:]
+			if name == "" || delimiter == "" {
+				return nil, fmt.Errorf("line %d: invalid format %q: name and delimiter must not be empty", lineNum, line)
+			}
+
+			var value []string
+			terminated := false
+			for i++; i < len(lines); i++ {
+				valueLine := strings.TrimSuffix(lines[i], "\r")
+				if valueLine == delimiter {
+					terminated = true
+					break
+				}
+				value = append(value, valueLine)
+			}
+			if !terminated {
+				return nil, fmt.Errorf("line %d: matching delimiter %q not found for %s", lineNum, delimiter, name)
+			}
+			vars = append(vars, fileCommandVar{Name: name, Value: strings.Join(value, "\n")})
+
+		default:
+			return nil, fmt.Errorf("line %d: invalid format %q", lineNum, line)
+		}
+	}
+
+	return vars, nil
+}
+
+// readFileCommand parses the file command at path and empties it, so that
+// the next step starts with a clean file. A missing file sets nothing.
+func readFileCommand(path string) ([]fileCommandVar, error) {
+	content, err := takeFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFileCommand(content)
+}
+
+// readPathFile returns the directories listed in the GITHUB_PATH file at
+// path, one per line, and empties it.
+func readPathFile(path string) ([]string, error) {
+	content, err := takeFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for line := range strings.Lines(strings.ReplaceAll(content, "\x00", "")) {
+		if dir := strings.TrimSpace(line); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs, nil
+}
+
+// takeFile returns the contents of the file at path and empties it. A
+// missing file is empty.
+func takeFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || len(content) == 0 {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Truncated rather than recreated, so that it keeps its mode.
+	if err := os.Truncate(path, 0); err != nil {
+		return "", fmt.Errorf("clearing file: %w", err)
+	}
+
+	return string(content), nil
+}
+
+// addPath puts dir in front of the directories added through GITHUB_PATH so
+// far, as the runner prepends each one to PATH.
+func (r *Runtime) addPath(dir string) {
+	r.Path = slices.Insert(slices.DeleteFunc(r.Path, func(d string) bool { return d == dir }), 0, dir)
+}
+
+// prependPath wraps cmd so that it runs with dirs in front of the PATH it
+// would otherwise have, which only the container knows.
+func prependPath(cmd []string, dirs []string) []string {
+	if len(dirs) == 0 {
+		return cmd
+	}
+	return append([]string{"sh", "-c", `PATH="$0:$PATH"; exec "$@"`, strings.Join(dirs, ":")}, cmd...)
+}
+
+// stateEnv returns the STATE_ variables through which an action's post hook
+// receives the state its main entry point saved.
+func stateEnv(state map[string]string) []string {
+	env := make([]string, 0, len(state))
+	for _, name := range slices.Sorted(maps.Keys(state)) {
+		env = append(env, "STATE_"+name+"="+state[name])
+	}
+	return env
+}
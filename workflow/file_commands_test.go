@@ -0,0 +1,181 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFileCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []fileCommandVar
+		errMsg   string
+	}{
+		{
+			name:     "single line",
+			content:  "version=1.2.3\n",
+			expected: []fileCommandVar{{Name: "version", Value: "1.2.3"}},
+		},
+		{
+			name:    "heredoc",
+			content: "changelog<<EOF\n- Fix login\n\n- Add search\nEOF\n",
+			expected: []fileCommandVar{
+				{Name: "changelog", Value: "- Fix login\n\n- Add search"},
+			},
+		},
+		{
+			name:    "heredoc among single lines",
+			content: "before=1\nmatrix<<ghadelimiter_123\n{\"os\": [\"ubuntu\", \"macos\"]}\nghadelimiter_123\nafter=2\n",
+			expected: []fileCommandVar{
+				{Name: "before", Value: "1"},
+				{Name: "matrix", Value: `{"os": ["ubuntu", "macos"]}`},
+				{Name: "after", Value: "2"},
+			},
+		},
+		{
+			name:     "empty heredoc",
+			content:  "empty<<EOF\nEOF\n",
+			expected: []fileCommandVar{{Name: "empty", Value: ""}},
+		},
+		{
+			name:     "heredoc keeps indentation and only ends on the exact delimiter",
+			content:  "script<<EOF\n  if x; then\n    EOF\n  fi\nEOF\n",
+			expected: []fileCommandVar{{Name: "script", Value: "  if x; then\n    EOF\n  fi"}},
+		},
+		{
+			name:     "windows line endings",
+			content:  "a=1\r\nnotes<<EOF\r\nline\r\nEOF\r\n",
+			expected: []fileCommandVar{{Name: "a", Value: "1"}, {Name: "notes", Value: "line"}},
+		},
+		{
+			name:     "equals before heredoc marker",
+			content:  "shift=a<<b\n",
+			expected: []fileCommandVar{{Name: "shift", Value: "a<<b"}},
+		},
+		{
+			name:     "heredoc marker before equals",
+			content:  "query<<END\nname=value\nEND\n",
+			expected: []fileCommandVar{{Name: "query", Value: "name=value"}},
+		},
+		{
+			name:     "later value wins",
+			content:  "a=1\na=2\n",
+			expected: []fileCommandVar{{Name: "a", Value: "1"}, {Name: "a", Value: "2"}},
+		},
+		{
+			name:     "spaces are kept",
+			content:  "padded= a \nnotes<<EOF \n line \nEOF\nEOF \n",
+			expected: []fileCommandVar{{Name: "padded", Value: " a "}, {Name: "notes", Value: " line \nEOF"}},
+		},
+		{
+			name:    "neither form",
+			content: "ok=1\njust some text\n",
+			errMsg:  `line 2: invalid format "just some text"`,
+		},
+		{
+			name:    "blank line of spaces",
+			content: "ok=1\n  \n",
+			errMsg:  `line 2: invalid format "  "`,
+		},
+		{
+			name:    "unterminated heredoc",
+			content: "ok=1\nnotes<<EOF\nline one\nline two\n",
+			errMsg:  `line 2: matching delimiter "EOF" not found for notes`,
+		},
+		{
+			name:    "empty delimiter",
+			content: "notes<<\nline\n",
+			errMsg:  "name and delimiter must not be empty",
+		},
+		{
+			name:    "empty heredoc name",
+			content: "<<EOF\nline\nEOF\n",
+			errMsg:  "name and delimiter must not be empty",
+		},
+		{
+			name:    "empty name",
+			content: "=value\n",
+			errMsg:  "name must not be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars, err := parseFileCommand(tt.content)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, vars)
+		})
+	}
+}
+
+func TestReadPathFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GITHUB_PATH")
+	require.NoError(t, os.WriteFile(path, []byte("/opt/go/bin\n\n  /home/runner/.local/bin \r\n"), 0600))
+
+	dirs, err := readPathFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/opt/go/bin", "/home/runner/.local/bin"}, dirs)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, content)
+}
+
+func TestRuntime_addPath(t *testing.T) {
+	runtime := &Runtime{}
+	runtime.addPath("/opt/go/bin")
+	runtime.addPath("/opt/node/bin")
+	runtime.addPath("/opt/go/bin")
+
+	assert.Equal(t, []string{"/opt/go/bin", "/opt/node/bin"}, runtime.Path)
+}
+
+func TestPrependPath(t *testing.T) {
+	cmd := []string{"bash", "-e", "/github/env/step.sh"}
+
+	assert.Equal(t, cmd, prependPath(cmd, nil))
+	assert.Equal(t,
+		[]string{"sh", "-c", `PATH="$0:$PATH"; exec "$@"`, "/opt/go/bin:/opt/node/bin", "bash", "-e", "/github/env/step.sh"},
+		prependPath(cmd, []string{"/opt/go/bin", "/opt/node/bin"}),
+	)
+}
+
+func TestFileCommandEnv(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "GITHUB_ENV"), []byte("KEEP=1\n"), 0600))
+
+	env, err := fileCommandEnv(tempDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"GITHUB_ENV=/github/env/GITHUB_ENV",
+		"GITHUB_OUTPUT=/github/env/GITHUB_OUTPUT",
+		"GITHUB_PATH=/github/env/GITHUB_PATH",
+		"GITHUB_STATE=/github/env/GITHUB_STATE",
+		"GITHUB_STEP_SUMMARY=/github/env/GITHUB_STEP_SUMMARY",
+	}, env)
+	for _, name := range fileCommands {
+		assert.FileExists(t, filepath.Join(tempDir, name))
+	}
+
+	// Existing files are left as they are.
+	content, err := os.ReadFile(filepath.Join(tempDir, "GITHUB_ENV"))
+	require.NoError(t, err)
+	assert.Equal(t, "KEEP=1\n", string(content))
+}
+
+func TestStateEnv(t *testing.T) {
+	assert.Equal(t,
+		[]string{"STATE_cache-key=linux-abc", "STATE_pid=42"},
+		stateEnv(map[string]string{"pid": "42", "cache-key": "linux-abc"}),
+	)
+}
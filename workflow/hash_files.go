@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// hashFiles returns the SHA-256 of the SHA-256 of each file under root that
+// matches patterns, as the hashFiles function does on GitHub, or "" when no
+// file matches. A pattern starting with ! excludes the files it matches, and
+// a later pattern overrides an earlier one.
+func hashFiles(root string, patterns []string) (string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.TrimPrefix(pattern, "!"), ""); err != nil {
+			return "", fmt.Errorf("hashFiles: invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	combined := sha256.New()
+	matched := false
+
+	// WalkDir visits files in lexical order, so the hash does not depend on
+	// the order the filesystem lists them in.
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		if !matchesPatterns(filepath.ToSlash(rel), patterns) {
+			return nil
+		}
+
+		sum, err := hashFile(name)
+		if err != nil {
+			return err
+		}
+
+		combined.Write(sum)
+		matched = true
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hashFiles: %w", err)
+	}
+
+	if !matched {
+		return "", nil
+	}
+
+	return hex.EncodeToString(combined.Sum(nil)), nil
+}
+
+func hashFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// matchesPatterns reports whether the last of patterns that matches name
+// includes it.
+func matchesPatterns(name string, patterns []string) bool {
+	included := false
+	for _, pattern := range patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "./")
+
+		if matchGlob(strings.Split(pattern, "/"), strings.Split(name, "/")) {
+			included = !exclude
+		}
+	}
+	return included
+}
+
+// matchGlob matches the segments of a slash-separated path against those of
+// a pattern, in which ** matches any number of segments.
+func matchGlob(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := range len(name) + 1 {
+			if matchGlob(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchGlob(pattern[1:], name[1:])
+}
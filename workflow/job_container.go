@@ -0,0 +1,278 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/telton/rehearse/internal/logger"
+)
+
+// The job container's entrypoint and command keep it running between steps,
+// whatever the image would run by default.
+var (
+	jobContainerEntrypoint = []string{"tail"}
+	jobContainerCmd        = []string{"-f", "/dev/null"}
+)
+
+// startJobContainers starts the job's service containers, then the container
+// that its run steps execute in, so that tools installed and files written by
+// one step are still there for the next, as on a real runner. Jobs without
+// run steps do not get a job container, and jobs without a container: run in
+// the image their runs-on labels map to.
+func (e *Executor) startJobContainers(ctx context.Context, job *Job, triggerContext *Context) error {
+	if err := evaluateContainers(job, triggerContext); err != nil {
+		return err
+	}
+
+	// Resolved even for jobs without run steps, since the run steps of
+	// composite actions start the job container when they need it.
+	if job.Container == nil {
+		image, err := e.runnerImage(job, triggerContext)
+		if err != nil {
+			return err
+		}
+		e.runtime.RunnerImage = image
+	}
+
+	if err := e.startServices(ctx, job); err != nil {
+		return fmt.Errorf("starting services: %w", err)
+	}
+
+	if !slices.ContainsFunc(job.Steps, func(step Step) bool { return step.Run != "" }) {
+		return nil
+	}
+
+	for _, executor := range e.executors {
+		if shell, ok := executor.(*ShellStepExecutor); ok {
+			return shell.startJobContainer(ctx, e.runtime)
+		}
+	}
+
+	return nil
+}
+
+// evaluateContainers replaces the job's container and services with copies
+// whose expressions, such as ${{ matrix.image }}, are evaluated. The originals
+// are shared with the job's other matrix instances and are left untouched.
+func evaluateContainers(job *Job, triggerContext *Context) error {
+	evaluator := NewEvaluator(triggerContext)
+
+	container, err := job.Container.evaluate(evaluator)
+	if err != nil {
+		return fmt.Errorf("evaluating container: %w", err)
+	}
+	job.Container = container
+
+	if job.Services == nil {
+		return nil
+	}
+
+	services := make(map[string]*Container, len(job.Services))
+	for id, service := range job.Services {
+		if services[id], err = service.evaluate(evaluator); err != nil {
+			return fmt.Errorf("evaluating service %s: %w", id, err)
+		}
+	}
+	job.Services = services
+
+	return nil
+}
+
+// evaluate returns a copy of the container with the expressions in its
+// settings replaced by their values.
+func (c *Container) evaluate(evaluator *Evaluator) (*Container, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	var err error
+	evaluated := &Container{}
+	if evaluated.Image, err = evaluator.Interpolate(c.Image); err != nil {
+		return nil, fmt.Errorf("image: %w", err)
+	}
+	if evaluated.Options, err = evaluator.Interpolate(c.Options); err != nil {
+		return nil, fmt.Errorf("options: %w", err)
+	}
+	if evaluated.Ports, err = interpolateAll(evaluator, c.Ports); err != nil {
+		return nil, fmt.Errorf("ports: %w", err)
+	}
+	if evaluated.Volumes, err = interpolateAll(evaluator, c.Volumes); err != nil {
+		return nil, fmt.Errorf("volumes: %w", err)
+	}
+
+	if c.Env != nil {
+		evaluated.Env = make(map[string]string, len(c.Env))
+		for k, v := range c.Env {
+			if evaluated.Env[k], err = evaluator.Interpolate(v); err != nil {
+				return nil, fmt.Errorf("env %s: %w", k, err)
+			}
+		}
+	}
+
+	if c.Credentials != nil {
+		evaluated.Credentials = &Credentials{}
+		if evaluated.Credentials.Username, err = evaluator.Interpolate(c.Credentials.Username); err != nil {
+			return nil, fmt.Errorf("credentials: %w", err)
+		}
+		if evaluated.Credentials.Password, err = evaluator.Interpolate(c.Credentials.Password); err != nil {
+			return nil, fmt.Errorf("credentials: %w", err)
+		}
+	}
+
+	return evaluated, nil
+}
+
+// interpolateAll evaluates the expressions in each of values.
+func interpolateAll(evaluator *Evaluator, values []string) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	evaluated := make([]string, len(values))
+	for i, value := range values {
+		var err error
+		if evaluated[i], err = evaluator.Interpolate(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return evaluated, nil
+}
+
+// stopJobContainers tears down the job container, then the services.
+func (e *Executor) stopJobContainers() {
+	e.stopJobContainer()
+	e.stopServices()
+}
+
+// stopJobContainer stops and removes the job container. When cleanup is
+// disabled the container is left running so it can be inspected.
+func (e *Executor) stopJobContainer() {
+	container := e.runtime.JobContainer
+	if container == nil {
+		return
+	}
+	e.runtime.JobContainer = nil
+
+	if !e.cleanup {
+		e.renderer.RenderContainerKept("job container", container.ID)
+		return
+	}
+
+	removeContainer(e.docker, container.ID)
+}
+
+// startJobContainer pulls the job's image and starts a container from it that
+// idles until the job ends, recording it as the runtime's job container.
+func (e *ShellStepExecutor) startJobContainer(ctx context.Context, runtime *Runtime) error {
+	config := &ContainerConfig{Image: runtime.RunnerImage}
+	var credentials *Credentials
+	custom := runtime.JobContext != nil && runtime.JobContext.Job.Container != nil
+	if !custom && config.Image == "" {
+		return fmt.Errorf("no image to run the job in")
+	}
+	if custom {
+		var err error
+		config, err = containerConfigFor(runtime.JobContext.Job.Container, runtime.WorkingDir)
+		if err != nil {
+			return fmt.Errorf("invalid job container: %w", err)
+		}
+		credentials = runtime.JobContext.Job.Container.Credentials
+	}
+
+	if e.renderer != nil {
+		e.renderer.RenderDockerPull(config.Image)
+	}
+	if err := pullContainerImage(ctx, e.Docker, config.Image, credentials); err != nil {
+		return err
+	}
+
+	config.Volumes = append([]VolumeMount{
+		{
+			Source: runtime.WorkingDir,
+			Target: "/github/workspace",
+			Type:   "bind",
+		},
+	}, config.Volumes...)
+
+	if runtime.TempDir != "" {
+		config.Volumes = append(config.Volumes, VolumeMount{
+			Source: runtime.TempDir,
+			Target: "/github/env",
+			Type:   "bind",
+		})
+	}
+
+	config.Entrypoint = jobContainerEntrypoint
+	config.Cmd = jobContainerCmd
+	config.WorkingDir = "/github/workspace"
+	if custom || len(runtime.Networks) == 0 {
+		config.Networks = runtime.networkNames()
+	} else {
+		// A job without a container runs on the host on GitHub, where
+		// its services are reached on localhost through the ports they
+		// publish, rather than by hostname.
+		config.NetworkMode = "host"
+	}
+
+	return startContainer(ctx, e.Docker, config, func(container *ContainerInfo) {
+		runtime.JobContainer = container
+	})
+}
+
+// startContainer creates a container from config and starts it, handing it
+// to record in between. Recording it before starting means that a container
+// that fails to start is still removed with the job.
+func startContainer(ctx context.Context, docker DockerClient, config *ContainerConfig, record func(*ContainerInfo)) error {
+	containerID, err := docker.CreateContainer(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed to create container: %w", err)
+	}
+
+	container := &ContainerInfo{
+		ID:       containerID,
+		Image:    config.Image,
+		Status:   "created",
+		Networks: config.Networks,
+	}
+	record(container)
+
+	if err := docker.StartContainer(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	container.Status = "running"
+
+	return nil
+}
+
+// removeContainer stops and removes a container, logging rather than
+// returning failures, with attrs identifying it in the log. It does not take
+// a context: the job may have been cancelled, but its containers must still
+// go.
+func removeContainer(docker DockerClient, containerID string, attrs ...any) {
+	ctx := context.Background()
+	attrs = append(attrs, "container_id", containerID)
+	if err := docker.StopContainer(ctx, containerID); err != nil {
+		logger.Warn("Failed to stop container", append(attrs, "error", err)...)
+	}
+	if err := docker.RemoveContainer(ctx, containerID); err != nil {
+		logger.Warn("Failed to remove container", append(attrs, "error", err)...)
+	}
+}
+
+// pullContainerImage pulls the image of a job or service container, logging
+// in to its registry first when credentials are given.
+func pullContainerImage(ctx context.Context, docker DockerClient, image string, credentials *Credentials) error {
+	if credentials != nil {
+		if err := docker.RegistryLogin(ctx, image, credentials); err != nil {
+			return fmt.Errorf("failed to log in to registry for %s: %w", image, err)
+		}
+	}
+
+	if err := docker.PullImage(ctx, image); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestExecutor_processJobOutputs(t *testing.T) {
@@ -185,8 +186,9 @@ func TestExecutor_processJobOutputs(t *testing.T) {
 			}
 			executor.runtime.StepOutputs = tt.stepOutputs
 			executor.runtime.DynamicEnv = tt.dynamicEnv
+			executor.runtime.Context = stepsContext(tt.stepOutputs)
 
-			executor.processJobOutputs(tt.job)
+			require.NoError(t, executor.processJobOutputs(tt.job))
 
 			assert.Equal(t, tt.expectedOutputs, executor.runtime.JobContext.Outputs,
 				"Job outputs don't match expected values")
@@ -218,8 +220,9 @@ func TestExecutor_processJobOutputs_WithExpressionWhitespace(t *testing.T) {
 	}
 	executor.runtime.StepOutputs = stepOutputs
 	executor.runtime.DynamicEnv = make(map[string]string)
+	executor.runtime.Context = stepsContext(stepOutputs)
 
-	executor.processJobOutputs(job)
+	require.NoError(t, executor.processJobOutputs(job))
 
 	expectedValue := "1.2.3"
 	assert.Equal(t, expectedValue, executor.runtime.JobContext.Outputs["normal"])
@@ -242,7 +245,7 @@ func TestExecutor_processJobOutputs_EmptyJobContext(t *testing.T) {
 	}
 
 	assert.NotPanics(t, func() {
-		executor.processJobOutputs(job)
+		assert.NoError(t, executor.processJobOutputs(job))
 	})
 }
 
@@ -286,8 +289,9 @@ func TestExecutor_processJobOutputs_Integration(t *testing.T) {
 		"DEPLOY_TARGET": "production",
 		"BUILD_ENV":     "ci",
 	}
+	executor.runtime.Context = stepsContext(executor.runtime.StepOutputs)
 
-	executor.processJobOutputs(job)
+	require.NoError(t, executor.processJobOutputs(job))
 
 	expectedOutputs := map[string]string{
 		"app-version":   "2.1.0",
@@ -298,3 +302,44 @@ func TestExecutor_processJobOutputs_Integration(t *testing.T) {
 
 	assert.Equal(t, expectedOutputs, executor.runtime.JobContext.Outputs)
 }
+
+func TestExecutor_processJobOutputs_Expressions(t *testing.T) {
+	executor := NewExecutor(&Analyzer{}, NewMockDockerClient(), NewMockGitRepo())
+
+	job := &Job{
+		Name: "expressions-job",
+		Env:  map[string]string{"REGISTRY": "ghcr.io"},
+		Outputs: map[string]string{
+			"image":  "${{ env.REGISTRY }}/${{ github.repository }}:${{ steps.build.outputs.version }}",
+			"target": "${{ matrix.os }}",
+			"passed": "${{ steps.build.outcome == 'success' }}",
+		},
+	}
+
+	executor.runtime.JobContext = &ExecutionJobContext{Job: job, Outputs: make(map[string]string)}
+	executor.runtime.Context = stepsContext(map[string]map[string]string{"build": {"version": "1.2.3"}})
+	executor.runtime.Context.GitHub.Repository = "octo-org/app"
+	executor.runtime.Context.Matrix = map[string]any{"os": "ubuntu-24.04"}
+
+	require.NoError(t, executor.processJobOutputs(job))
+
+	assert.Equal(t, map[string]string{
+		"image":  "ghcr.io/octo-org/app:1.2.3",
+		"target": "ubuntu-24.04",
+		"passed": "true",
+	}, executor.runtime.JobContext.Outputs)
+
+	job.Outputs["broken"] = "${{ steps.build.outputs.version == }}"
+	err := executor.processJobOutputs(job)
+	assert.ErrorContains(t, err, "output broken")
+}
+
+// stepsContext returns a context in which the steps with the given outputs
+// have succeeded.
+func stepsContext(outputs map[string]map[string]string) *Context {
+	ctx := &Context{Steps: make(map[string]StepContext)}
+	for id, stepOutputs := range outputs {
+		ctx.Steps[id] = StepContext{Outcome: "success", Conclusion: "success", Outputs: stepOutputs}
+	}
+	return ctx
+}
@@ -0,0 +1,312 @@
+package workflow
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Matrix represents a strategy matrix, keeping its keys in declaration order.
+type Matrix struct {
+	Dimensions []MatrixDimension
+	Include    []MatrixCombination
+	Exclude    []MatrixCombination
+	Expression string // Set when the whole matrix is a ${{ }} expression
+}
+
+// MatrixDimension is a single matrix key and the values it can take.
+type MatrixDimension struct {
+	Key        string
+	Values     []any
+	Expression string // Set when the values are a ${{ }} expression
+}
+
+// MatrixCombination is an ordered set of matrix key/value pairs.
+type MatrixCombination struct {
+	Keys   []string
+	Values map[string]any
+}
+
+func (m *Matrix) UnmarshalYAML(unmarshal func(any) error) error {
+	// Try an expression first.
+	var expr string
+	if err := unmarshal(&expr); err == nil {
+		m.Expression = expr
+		return nil
+	}
+
+	var entries yaml.MapSlice
+	if err := unmarshal(&entries); err != nil {
+		return err
+	}
+
+	// Decode include and exclude separately so their keys keep their order.
+	var modifiers struct {
+		Include []yaml.MapSlice `yaml:"include"`
+		Exclude []yaml.MapSlice `yaml:"exclude"`
+	}
+	if err := unmarshal(&modifiers); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		key := fmt.Sprint(entry.Key)
+		switch key {
+		case "include", "exclude":
+			continue
+		}
+
+		switch value := entry.Value.(type) {
+		case []any:
+			m.Dimensions = append(m.Dimensions, MatrixDimension{Key: key, Values: value})
+		case string:
+			if !strings.Contains(value, "${{") {
+				return fmt.Errorf("matrix vector '%s' must be a list", key)
+			}
+			m.Dimensions = append(m.Dimensions, MatrixDimension{Key: key, Expression: value})
+		default:
+			return fmt.Errorf("matrix vector '%s' must be a list", key)
+		}
+	}
+
+	for _, inc := range modifiers.Include {
+		m.Include = append(m.Include, newMatrixCombination(inc))
+	}
+	for _, exc := range modifiers.Exclude {
+		m.Exclude = append(m.Exclude, newMatrixCombination(exc))
+	}
+
+	return nil
+}
+
+func newMatrixCombination(entries yaml.MapSlice) MatrixCombination {
+	c := MatrixCombination{Values: make(map[string]any)}
+	for _, entry := range entries {
+		c.set(fmt.Sprint(entry.Key), entry.Value)
+	}
+	return c
+}
+
+// Dynamic reports whether the matrix, or any of its vectors, is a ${{ }}
+// expression, which may use the outputs of the jobs the job needs.
+func (m *Matrix) Dynamic() bool {
+	if m == nil {
+		return false
+	}
+	return m.Expression != "" || slices.ContainsFunc(m.Dimensions, func(dim MatrixDimension) bool {
+		return dim.Expression != ""
+	})
+}
+
+// Evaluate returns the matrix with its expressions evaluated. A matrix
+// expression must evaluate to an object, as fromJSON(needs.setup.outputs.matrix)
+// does, whose vectors are taken in sorted order since JSON objects are
+// unordered. A vector expression must evaluate to an array.
+func (m *Matrix) Evaluate(eval *Evaluator) (*Matrix, error) {
+	if m.Expression != "" {
+		result, err := eval.Evaluate(m.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("evaluating matrix %s: %w", m.Expression, err)
+		}
+
+		object, ok := result.Value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("matrix %s must evaluate to an object", m.Expression)
+		}
+
+		return matrixFromObject(object)
+	}
+
+	evaluated := &Matrix{Include: m.Include, Exclude: m.Exclude}
+	for _, dim := range m.Dimensions {
+		if dim.Expression != "" {
+			result, err := eval.Evaluate(dim.Expression)
+			if err != nil {
+				return nil, fmt.Errorf("evaluating matrix vector '%s': %w", dim.Key, err)
+			}
+
+			values, ok := result.Value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("matrix vector '%s' must evaluate to a list", dim.Key)
+			}
+			dim = MatrixDimension{Key: dim.Key, Values: values}
+		}
+		evaluated.Dimensions = append(evaluated.Dimensions, dim)
+	}
+
+	return evaluated, nil
+}
+
+// matrixFromObject returns the matrix an evaluated matrix expression
+// describes.
+func matrixFromObject(object map[string]any) (*Matrix, error) {
+	m := &Matrix{}
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		switch key {
+		case "include", "exclude":
+			entries, ok := object[key].([]any)
+			if !ok {
+				return nil, fmt.Errorf("matrix %s must be a list", key)
+			}
+
+			for _, entry := range entries {
+				values, ok := entry.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("matrix %s entries must be objects", key)
+				}
+
+				var c MatrixCombination
+				for _, k := range slices.Sorted(maps.Keys(values)) {
+					c.set(k, values[k])
+				}
+
+				if key == "include" {
+					m.Include = append(m.Include, c)
+				} else {
+					m.Exclude = append(m.Exclude, c)
+				}
+			}
+
+		default:
+			values, ok := object[key].([]any)
+			if !ok {
+				return nil, fmt.Errorf("matrix vector '%s' must be a list", key)
+			}
+			m.Dimensions = append(m.Dimensions, MatrixDimension{Key: key, Values: values})
+		}
+	}
+
+	return m, nil
+}
+
+// Expand returns every concrete combination of the matrix. Exclusions are
+// applied to the cartesian product first, then includes are merged in the
+// same way GitHub does: an include entry is added to every combination whose
+// original values it does not overwrite, and becomes a new combination when it
+// cannot be added to any of them.
+func (m *Matrix) Expand() ([]MatrixCombination, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	if m.Dynamic() {
+		return nil, fmt.Errorf("matrix has expressions that have not been evaluated")
+	}
+
+	var combos []MatrixCombination
+	if len(m.Dimensions) > 0 {
+		combos = []MatrixCombination{{Values: make(map[string]any)}}
+		for _, dim := range m.Dimensions {
+			if len(dim.Values) == 0 {
+				return nil, fmt.Errorf("matrix vector '%s' does not contain any values", dim.Key)
+			}
+
+			var next []MatrixCombination
+			for _, combo := range combos {
+				for _, v := range dim.Values {
+					c := combo.clone()
+					c.set(dim.Key, v)
+					next = append(next, c)
+				}
+			}
+			combos = next
+		}
+	}
+
+	var kept []MatrixCombination
+	for _, combo := range combos {
+		excluded := false
+		for _, exc := range m.Exclude {
+			if combo.matches(exc) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, combo)
+		}
+	}
+
+	original := make(map[string]bool)
+	for _, dim := range m.Dimensions {
+		original[dim.Key] = true
+	}
+
+	base := len(kept)
+	for _, inc := range m.Include {
+		added := false
+		for i := range base {
+			if !kept[i].accepts(inc, original) {
+				continue
+			}
+			for _, k := range inc.Keys {
+				kept[i].set(k, inc.Values[k])
+			}
+			added = true
+		}
+
+		if !added {
+			kept = append(kept, inc.clone())
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("matrix must define at least one vector")
+	}
+
+	return kept, nil
+}
+
+// Name returns the display name of a job instance running this combination.
+func (c MatrixCombination) Name(base string) string {
+	values := make([]string, 0, len(c.Keys))
+	for _, k := range c.Keys {
+		values = append(values, toString(c.Values[k]))
+	}
+	return fmt.Sprintf("%s (%s)", base, strings.Join(values, ", "))
+}
+
+func (c *MatrixCombination) set(key string, value any) {
+	if c.Values == nil {
+		c.Values = make(map[string]any)
+	}
+	if _, exists := c.Values[key]; !exists {
+		c.Keys = append(c.Keys, key)
+	}
+	c.Values[key] = value
+}
+
+func (c MatrixCombination) clone() MatrixCombination {
+	return MatrixCombination{
+		Keys:   slices.Clone(c.Keys),
+		Values: maps.Clone(c.Values),
+	}
+}
+
+// matches reports whether every key/value pair in other is present in c.
+func (c MatrixCombination) matches(other MatrixCombination) bool {
+	for _, k := range other.Keys {
+		v, ok := c.Values[k]
+		if !ok || !equals(v, other.Values[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// accepts reports whether inc can be merged into c without overwriting any of
+// the original matrix values.
+func (c MatrixCombination) accepts(inc MatrixCombination, original map[string]bool) bool {
+	for _, k := range inc.Keys {
+		if !original[k] {
+			continue
+		}
+		if v, ok := c.Values[k]; ok && !equals(v, inc.Values[k]) {
+			return false
+		}
+	}
+	return true
+}
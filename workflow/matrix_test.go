@@ -0,0 +1,307 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrix_Expand(t *testing.T) {
+	tests := []struct {
+		name          string
+		matrix        string
+		expectedNames []string
+	}{
+		{
+			name: "cartesian product keeps declaration order",
+			matrix: `
+os: [ubuntu, macos]
+go: ["1.22", "1.23"]
+`,
+			expectedNames: []string{
+				"test (ubuntu, 1.22)",
+				"test (ubuntu, 1.23)",
+				"test (macos, 1.22)",
+				"test (macos, 1.23)",
+			},
+		},
+		{
+			name: "partial exclude",
+			matrix: `
+os: [ubuntu, macos]
+go: ["1.22", "1.23"]
+exclude:
+  - os: macos
+`,
+			expectedNames: []string{
+				"test (ubuntu, 1.22)",
+				"test (ubuntu, 1.23)",
+			},
+		},
+		{
+			name: "include extends matching combinations",
+			matrix: `
+os: [ubuntu, macos]
+go: ["1.22"]
+include:
+  - os: ubuntu
+    experimental: true
+`,
+			expectedNames: []string{
+				"test (ubuntu, 1.22, true)",
+				"test (macos, 1.22)",
+			},
+		},
+		{
+			name: "include that overwrites original values adds a combination",
+			matrix: `
+os: [ubuntu]
+go: ["1.22"]
+include:
+  - os: windows
+    go: "1.21"
+`,
+			expectedNames: []string{
+				"test (ubuntu, 1.22)",
+				"test (windows, 1.21)",
+			},
+		},
+		{
+			name: "include can overwrite values added by a previous include",
+			matrix: `
+fruit: [apple, pear]
+animal: [cat]
+include:
+  - color: green
+  - color: pink
+    animal: cat
+  - fruit: apple
+    shape: circle
+`,
+			expectedNames: []string{
+				"test (apple, cat, pink, circle)",
+				"test (pear, cat, pink)",
+			},
+		},
+		{
+			name: "include only",
+			matrix: `
+include:
+  - site: production
+  - site: staging
+`,
+			expectedNames: []string{
+				"test (production)",
+				"test (staging)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Matrix
+			require.NoError(t, yaml.Unmarshal([]byte(tt.matrix), &m))
+
+			combos, err := m.Expand()
+			require.NoError(t, err)
+
+			var names []string
+			for _, combo := range combos {
+				names = append(names, combo.Name("test"))
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestMatrix_Expand_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		matrix   string
+		expected string
+	}{
+		{
+			name:     "empty vector",
+			matrix:   "os: []",
+			expected: "matrix vector 'os' does not contain any values",
+		},
+		{
+			name: "everything excluded",
+			matrix: `
+os: [ubuntu]
+exclude:
+  - os: ubuntu
+`,
+			expected: "matrix must define at least one vector",
+		},
+		{
+			name:     "expression",
+			matrix:   "${{ fromJSON(needs.setup.outputs.matrix) }}",
+			expected: "have not been evaluated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Matrix
+			require.NoError(t, yaml.Unmarshal([]byte(tt.matrix), &m))
+
+			_, err := m.Expand()
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestMatrix_UnmarshalYAML_Vectors(t *testing.T) {
+	var m Matrix
+	require.NoError(t, yaml.Unmarshal([]byte("os: ${{ fromJSON(needs.setup.outputs.os) }}\ngo: [\"1.25\"]"), &m))
+	assert.Equal(t, []MatrixDimension{
+		{Key: "os", Expression: "${{ fromJSON(needs.setup.outputs.os) }}"},
+		{Key: "go", Values: []any{"1.25"}},
+	}, m.Dimensions)
+	assert.True(t, m.Dynamic())
+
+	for _, matrix := range []string{"os: ubuntu", "os: 3", "os: {name: ubuntu}"} {
+		t.Run(matrix, func(t *testing.T) {
+			var m Matrix
+			assert.ErrorContains(t, yaml.Unmarshal([]byte(matrix), &m), "matrix vector 'os' must be a list")
+		})
+	}
+}
+
+func TestMatrix_Evaluate(t *testing.T) {
+	ctx := &Context{
+		Needs: map[string]JobContext{
+			"setup": {Outputs: map[string]string{
+				"os":     `["ubuntu", "alpine"]`,
+				"matrix": `{"os": ["ubuntu"], "go": ["1.24", "1.25"], "exclude": [{"go": "1.24"}], "include": [{"os": "ubuntu", "race": true}]}`,
+				"scalar": `"ubuntu"`,
+			}},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		matrix        string
+		expectedNames []string
+		errMsg        string
+	}{
+		{
+			name:          "vector expression",
+			matrix:        "os: ${{ fromJSON(needs.setup.outputs.os) }}\ngo: [\"1.25\"]",
+			expectedNames: []string{"test (ubuntu, 1.25)", "test (alpine, 1.25)"},
+		},
+		{
+			name:          "matrix expression with sorted vectors",
+			matrix:        "${{ fromJSON(needs.setup.outputs.matrix) }}",
+			expectedNames: []string{"test (1.25, ubuntu, true)"},
+		},
+		{
+			name:   "vector expression that is not a list",
+			matrix: "os: ${{ fromJSON(needs.setup.outputs.scalar) }}",
+			errMsg: "matrix vector 'os' must evaluate to a list",
+		},
+		{
+			name:   "matrix expression that is not an object",
+			matrix: "${{ fromJSON(needs.setup.outputs.os) }}",
+			errMsg: "must evaluate to an object",
+		},
+		{
+			name:   "output that is not yet known",
+			matrix: "${{ fromJSON(needs.build.outputs.matrix) }}",
+			errMsg: "fromJSON",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Matrix
+			require.NoError(t, yaml.Unmarshal([]byte(tt.matrix), &m))
+
+			evaluated, err := m.Evaluate(NewEvaluator(ctx))
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+
+			combos, err := evaluated.Expand()
+			require.NoError(t, err)
+
+			var names []string
+			for _, combo := range combos {
+				names = append(names, combo.Name("test"))
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestAnalyzer_Analyze_Matrix(t *testing.T) {
+	wf := &Workflow{
+		Name: "Matrix",
+		Jobs: map[string]Job{
+			"test": {
+				RunsOn: RunsOn{Labels: []string{"${{ matrix.os }}-latest"}},
+				Strategy: &Strategy{
+					Matrix: &Matrix{
+						Dimensions: []MatrixDimension{
+							{Key: "os", Values: []any{"ubuntu", "macos"}},
+						},
+					},
+				},
+				Steps: []Step{
+					{Name: "Only on ubuntu", If: "matrix.os == 'ubuntu'", Run: "echo hi"},
+				},
+			},
+		},
+	}
+
+	ctx := &Context{
+		Jobs:   make(map[string]JobContext),
+		Matrix: make(map[string]any),
+	}
+
+	result := NewAnalyzer(wf, ctx).Analyze()
+
+	require.Len(t, result.Jobs, 2)
+
+	assert.Equal(t, "test", result.Jobs[0].ID)
+	assert.Equal(t, "test (ubuntu)", result.Jobs[0].Name)
+	assert.Equal(t, map[string]any{"os": "ubuntu"}, result.Jobs[0].Matrix)
+	assert.Equal(t, "ubuntu-latest", result.Jobs[0].RunsOn)
+	assert.True(t, result.Jobs[0].Steps[0].WouldRun)
+
+	assert.Equal(t, "test (macos)", result.Jobs[1].Name)
+	assert.Equal(t, "macos-latest", result.Jobs[1].RunsOn)
+	assert.False(t, result.Jobs[1].Steps[0].WouldRun)
+
+	assert.Empty(t, ctx.Matrix)
+}
+
+func TestAnalyzer_Analyze_PendingMatrix(t *testing.T) {
+	wf := &Workflow{
+		Name: "Matrix",
+		Jobs: map[string]Job{
+			"test": {
+				RunsOn:   RunsOn{Labels: []string{"ubuntu-latest"}},
+				Strategy: &Strategy{Matrix: &Matrix{Expression: "${{ fromJSON(needs.setup.outputs.matrix) }}"}},
+				Steps:    []Step{{Run: "echo hi"}},
+			},
+		},
+	}
+
+	ctx := &Context{
+		Jobs:   make(map[string]JobContext),
+		Matrix: make(map[string]any),
+	}
+
+	result := NewAnalyzer(wf, ctx).Analyze()
+
+	require.Len(t, result.Jobs, 1)
+	assert.Equal(t, "test", result.Jobs[0].Name)
+	assert.True(t, result.Jobs[0].PendingMatrix)
+	assert.True(t, result.Jobs[0].WouldRun)
+}
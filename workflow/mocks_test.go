@@ -2,7 +2,12 @@ package workflow
 
 import (
 	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
 	"sync"
+	"testing"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -70,8 +75,8 @@ func (m *MockDockerClient) StartContainer(ctx context.Context, containerID strin
 }
 
 // ExecInContainer mocks command execution in container.
-func (m *MockDockerClient) ExecInContainer(ctx context.Context, containerID string, cmd []string) (*ExecResult, error) {
-	args := m.Called(ctx, containerID, cmd)
+func (m *MockDockerClient) ExecInContainer(ctx context.Context, containerID string, config *ExecConfig) (*ExecResult, error) {
+	args := m.Called(ctx, containerID, config)
 
 	if args.Error(1) != nil {
 		return nil, args.Error(1)
@@ -119,6 +124,55 @@ func (m *MockDockerClient) PullImage(ctx context.Context, image string) error {
 	return args.Error(0)
 }
 
+// RegistryLogin mocks logging in to a registry.
+func (m *MockDockerClient) RegistryLogin(ctx context.Context, image string, credentials *Credentials) error {
+	args := m.Called(ctx, image, credentials)
+	return args.Error(0)
+}
+
+// CreateNetwork mocks network creation.
+func (m *MockDockerClient) CreateNetwork(ctx context.Context, name string) (string, error) {
+	args := m.Called(ctx, name)
+	return args.String(0), args.Error(1)
+}
+
+// RemoveNetwork mocks network removal.
+func (m *MockDockerClient) RemoveNetwork(ctx context.Context, networkID string) error {
+	args := m.Called(ctx, networkID)
+	return args.Error(0)
+}
+
+// ContainerHealth mocks container health inspection.
+func (m *MockDockerClient) ContainerHealth(ctx context.Context, containerID string) (string, error) {
+	args := m.Called(ctx, containerID)
+	return args.String(0), args.Error(1)
+}
+
+// WaitForContainer mocks waiting for a container to exit.
+func (m *MockDockerClient) WaitForContainer(ctx context.Context, containerID string) (int, error) {
+	args := m.Called(ctx, containerID)
+	return args.Int(0), args.Error(1)
+}
+
+// GetContainerLogs mocks following a container's logs, writing the output
+// of the returned result to stdout and stderr when they are given.
+func (m *MockDockerClient) GetContainerLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) (*ExecResult, error) {
+	args := m.Called(ctx, containerID)
+
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+
+	result := args.Get(0).(*ExecResult)
+	if stdout != nil {
+		io.WriteString(stdout, result.Stdout)
+	}
+	if stderr != nil {
+		io.WriteString(stderr, result.Stderr)
+	}
+	return result, nil
+}
+
 // Close mocks client closing.
 func (m *MockDockerClient) Close() error {
 	args := m.Called()
@@ -196,13 +250,16 @@ func NewTestDockerClient() (DockerClient, error) {
 	return NewMockDockerClient(), nil
 }
 
-// CreateTestRuntime creates a runtime for testing.
-func CreateTestRuntime(workingDir string) *Runtime {
+// CreateTestRuntime creates a runtime for testing, with a temp directory
+// for the files steps exchange with the runner.
+func CreateTestRuntime(t *testing.T, workingDir string) *Runtime {
 	return &Runtime{
-		WorkingDir: workingDir,
-		Containers: make(map[string]*ContainerInfo),
-		Networks:   make(map[string]*NetworkInfo),
-		Volumes:    make(map[string]*VolumeInfo),
+		WorkingDir:  workingDir,
+		TempDir:     t.TempDir(),
+		RunnerImage: "ubuntu:latest", // As ubuntu-latest maps to by default
+		Containers:  make(map[string]*ContainerInfo),
+		Networks:    make(map[string]*NetworkInfo),
+		Volumes:     make(map[string]*VolumeInfo),
 		JobContext: &ExecutionJobContext{
 			Job: &Job{
 				Name:   "test-job",
@@ -218,6 +275,14 @@ func CreateTestRuntime(workingDir string) *Runtime {
 	}
 }
 
+// newTestExecutor creates an executor for testing, with a temp directory as
+// Execute would set up.
+func newTestExecutor(t *testing.T, docker DockerClient, git ExecutorGitRepo) *Executor {
+	executor := NewExecutor(&Analyzer{}, docker, git)
+	executor.runtime.TempDir = t.TempDir()
+	return executor
+}
+
 // CreateTestStep creates a step for testing.
 func CreateTestStep(id, name, run string) *Step {
 	return &Step{
@@ -284,6 +349,21 @@ func AssertContainerConfig(t interface {
 	}
 }
 
+// stepScript returns the script an exec runs with the default shell, read
+// from the file the job container sees in /github/env, or "" if the exec does
+// not run a step script that way.
+func stepScript(tempDir string, config *ExecConfig) string {
+	if len(config.Cmd) != 4 || config.Cmd[2] != defaultShellCmd {
+		return ""
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, path.Base(config.Cmd[3])))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
 // AssertEnvironmentContains checks if environment contains expected variables.
 func AssertEnvironmentContains(t interface {
 	Errorf(format string, args ...any)
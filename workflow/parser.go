@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 
 	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	yamlparser "github.com/goccy/go-yaml/parser"
 )
 
 // Parse reads and parses a workflow file.
@@ -20,7 +22,77 @@ func Parse(path string) (*Workflow, error) {
 		return nil, fmt.Errorf("parse workflow file: %w", err)
 	}
 
-	return &w, err
+	// Keep the syntax tree around so that errors can point at source lines.
+	file, err := yamlparser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("parse workflow file: %w", err)
+	}
+	w.source = file
+
+	return &w, nil
+}
+
+// entry returns the mapping entry at the given key path in the workflow
+// source, or nil when the workflow was not parsed from a file.
+func (w *Workflow) entry(keys ...string) *ast.MappingValueNode {
+	if w.source == nil || len(w.source.Docs) == 0 {
+		return nil
+	}
+
+	node := w.source.Docs[0].Body
+	var found *ast.MappingValueNode
+	for _, key := range keys {
+		found = nil
+		for _, value := range mappingValues(node) {
+			if value.Key != nil && value.Key.GetToken().Value == key {
+				found = value
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		node = found.Value
+	}
+
+	return found
+}
+
+// line returns the source line of the mapping entry at the given key path, or
+// 0 when it is unknown.
+func (w *Workflow) line(keys ...string) int {
+	if entry := w.entry(keys...); entry != nil {
+		return entry.Key.GetToken().Position.Line
+	}
+	return 0
+}
+
+// needsLine returns the source line where job lists dep in its needs.
+func (w *Workflow) needsLine(job, dep string) int {
+	entry := w.entry("jobs", job, "needs")
+	if entry == nil {
+		return w.line("jobs", job)
+	}
+
+	if seq, ok := entry.Value.(*ast.SequenceNode); ok {
+		for _, value := range seq.Values {
+			if value.GetToken().Value == dep {
+				return value.GetToken().Position.Line
+			}
+		}
+	}
+
+	return entry.Key.GetToken().Position.Line
+}
+
+func mappingValues(node ast.Node) []*ast.MappingValueNode {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}
+	}
+	return nil
 }
 
 // FindWorkflows finds all workflow files in the .github/workflows directory.
@@ -0,0 +1,305 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telton/rehearse/internal/mask"
+)
+
+const orderedWorkflow = `
+name: Ordered
+on: push
+jobs:
+  zeta:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo zeta
+  alpha:
+    runs-on: ubuntu-latest
+    needs: [mid, zeta]
+    steps:
+      - run: echo alpha
+  mid:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo mid
+  beta:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo beta
+`
+
+func TestWorkflow_JobIDs(t *testing.T) {
+	var wf Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(orderedWorkflow), &wf))
+
+	assert.Equal(t, []string{"zeta", "alpha", "mid", "beta"}, wf.JobIDs())
+
+	// Jobs added after parsing come last, sorted.
+	wf.Jobs["late"] = Job{}
+	wf.Jobs["early"] = Job{}
+	assert.Equal(t, []string{"zeta", "alpha", "mid", "beta", "early", "late"}, wf.JobIDs())
+}
+
+func TestAnalyzer_Analyze_DeclarationOrder(t *testing.T) {
+	var wf Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(orderedWorkflow), &wf))
+
+	for range 20 {
+		ctx := &Context{
+			Jobs:   make(map[string]JobContext),
+			Matrix: make(map[string]any),
+		}
+		result := NewAnalyzer(&wf, ctx).Analyze()
+
+		var order []string
+		for _, job := range result.Jobs {
+			order = append(order, job.ID)
+		}
+		assert.Equal(t, []string{"zeta", "mid", "alpha", "beta"}, order)
+	}
+}
+
+func TestAnalyzer_Analyze_NeedsResult(t *testing.T) {
+	var wf Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(`
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    if: github.ref == 'refs/heads/release'
+    steps:
+      - run: make
+  notify:
+    needs: build
+    if: always() && needs.build.result == 'skipped'
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo skipped
+`), &wf))
+
+	ctx := &Context{
+		GitHub: GitHubContext{Ref: "refs/heads/main"},
+		Jobs:   make(map[string]JobContext),
+		Matrix: make(map[string]any),
+	}
+	result := NewAnalyzer(&wf, ctx).Analyze()
+
+	require.Len(t, result.Jobs, 2)
+	assert.False(t, result.Jobs[0].WouldRun)
+	assert.True(t, result.Jobs[1].WouldRun)
+}
+
+func TestAnalyzer_Analyze_MasksSecretsInTraces(t *testing.T) {
+	var wf Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(`
+on: push
+jobs:
+  deploy:
+    if: secrets.DEPLOY_KEY != ''
+    runs-on: ubuntu-latest
+    steps:
+      - run: ./deploy
+`), &wf))
+
+	mask.Add("analyzer-deploy-key")
+	ctx := &Context{
+		Secrets: map[string]string{"DEPLOY_KEY": "analyzer-deploy-key"},
+		Jobs:    make(map[string]JobContext),
+		Matrix:  make(map[string]any),
+	}
+	result := NewAnalyzer(&wf, ctx).Analyze()
+
+	require.Len(t, result.Jobs, 1)
+	assert.True(t, result.Jobs[0].WouldRun)
+	assert.Contains(t, result.Jobs[0].Condition.Trace, "secrets.DEPLOY_KEY -> '***'")
+	assert.NotContains(t, result.Jobs[0].Condition.Trace, "analyzer-deploy-key")
+}
+
+func TestContainer_UnmarshalYAML(t *testing.T) {
+	var wf Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(`
+on: push
+jobs:
+  short:
+    runs-on: ubuntu-latest
+    container: node:20
+    steps:
+      - run: node --version
+  full:
+    runs-on: ubuntu-latest
+    container:
+      image: ghcr.io/acme/builder:1
+      env:
+        CI: "true"
+      ports:
+        - 8080:80
+      volumes:
+        - cache:/cache
+      options: --user 1001
+      credentials:
+        username: octocat
+        password: hunter2
+    steps:
+      - run: make
+`), &wf))
+
+	assert.Equal(t, &Container{Image: "node:20"}, wf.Jobs["short"].Container)
+	assert.Equal(t, &Container{
+		Image:       "ghcr.io/acme/builder:1",
+		Env:         map[string]string{"CI": "true"},
+		Ports:       []string{"8080:80"},
+		Volumes:     []string{"cache:/cache"},
+		Options:     "--user 1001",
+		Credentials: &Credentials{Username: "octocat", Password: "hunter2"},
+	}, wf.Jobs["full"].Container)
+}
+
+func TestJobSecrets_UnmarshalYAML(t *testing.T) {
+	var wf Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(`
+on: push
+jobs:
+  named:
+    uses: ./.github/workflows/deploy.yaml
+    secrets:
+      token: ${{ secrets.DEPLOY_TOKEN }}
+  inherited:
+    uses: ./.github/workflows/deploy.yaml
+    secrets: inherit
+`), &wf))
+
+	assert.Equal(t, "./.github/workflows/deploy.yaml", wf.Jobs["named"].Uses)
+	assert.Equal(t, &JobSecrets{Values: map[string]string{"token": "${{ secrets.DEPLOY_TOKEN }}"}}, wf.Jobs["named"].Secrets)
+	assert.Equal(t, &JobSecrets{Inherit: true}, wf.Jobs["inherited"].Secrets)
+
+	err := yaml.Unmarshal([]byte(`
+jobs:
+  call:
+    uses: ./.github/workflows/deploy.yaml
+    secrets: everything
+`), &wf)
+	assert.ErrorContains(t, err, `invalid secrets "everything"`)
+}
+
+func TestAnalyzer_Analyze_MissingSecrets(t *testing.T) {
+	var wf Workflow
+	require.NoError(t, yaml.Unmarshal([]byte(`
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - name: Build
+        env:
+          TOKEN: ${{ secrets.NPM_TOKEN }}
+        run: make
+  deploy:
+    needs: build
+    if: secrets.DEPLOY_KEY != ''
+    runs-on: ubuntu-latest
+    steps:
+      - name: Deploy
+        run: ./deploy --key "${{ secrets.DEPLOY_KEY }}"
+  release:
+    uses: ./.github/workflows/release.yaml
+    secrets:
+      token: ${{ secrets.RELEASE_TOKEN }}
+`), &wf))
+
+	ctx := &Context{
+		Secrets: map[string]string{"NPM_TOKEN": "npm"},
+		Jobs:    make(map[string]JobContext),
+		Matrix:  make(map[string]any),
+	}
+	result := NewAnalyzer(&wf, ctx).Analyze()
+
+	assert.Equal(t, []MissingSecret{
+		{Name: "DEPLOY_KEY", Refs: []SecretRef{
+			{Name: "DEPLOY_KEY", Job: "deploy"},
+			{Name: "DEPLOY_KEY", Job: "deploy", Step: "Deploy"},
+		}},
+		{Name: "RELEASE_TOKEN", Refs: []SecretRef{{Name: "RELEASE_TOKEN", Job: "release"}}},
+	}, result.MissingSecrets)
+}
+
+// TestParse_Fixtures analyzes each example workflow in testdata, other than
+// those demonstrating errors, and evaluates every expression in it.
+func TestParse_Fixtures(t *testing.T) {
+	paths, err := filepath.Glob("../testdata/*.yaml")
+	require.NoError(t, err)
+	features, err := filepath.Glob("../testdata/[bf]*/*.yaml")
+	require.NoError(t, err)
+	paths = append(paths, features...)
+	require.NotEmpty(t, features)
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			wf, err := Parse(path)
+			require.NoError(t, err)
+			require.NoError(t, wf.Validate())
+
+			ctx := &Context{
+				GitHub: GitHubContext{
+					EventName: "push",
+					Ref:       "refs/heads/main",
+					Workspace: "..",
+					Event:     defaultEventPayload("push"),
+				},
+				Env:    make(map[string]string),
+				Jobs:   make(map[string]JobContext),
+				Needs:  make(map[string]JobContext),
+				Steps:  make(map[string]StepContext),
+				Matrix: make(map[string]any),
+			}
+
+			result := NewAnalyzer(wf, ctx).Analyze()
+			for _, job := range result.Jobs {
+				assert.NotContains(t, job.SkipReason, "invalid", job.Name)
+				if job.Condition != nil {
+					assert.NotContains(t, job.Condition.Trace, "error:", job.Name)
+				}
+				for _, step := range job.Steps {
+					if step.Condition != nil {
+						assert.NotContains(t, step.Condition.Trace, "error:", step.Name)
+					}
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var raw any
+			require.NoError(t, yaml.Unmarshal(data, &raw))
+
+			evaluator := NewEvaluator(ctx)
+			var visit func(v any)
+			visit = func(v any) {
+				switch val := v.(type) {
+				case map[string]any:
+					for _, nested := range val {
+						visit(nested)
+					}
+				case []any:
+					for _, nested := range val {
+						visit(nested)
+					}
+				case string:
+					// The outputs of needed jobs are only known once they run.
+					if strings.Contains(val, "needs.") {
+						return
+					}
+					_, err := evaluator.Interpolate(val)
+					assert.NoError(t, err)
+				}
+			}
+			visit(raw)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package workflow
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Platforms maps runs-on labels to the Docker images that stand in for the
+// runners those labels select.
+type Platforms map[string]string
+
+// DefaultPlatforms returns the images used for GitHub's Ubuntu runner labels
+// when no other mapping is configured.
+func DefaultPlatforms() Platforms {
+	return Platforms{
+		"ubuntu-latest": "ubuntu:latest",
+		"ubuntu-24.04":  "ubuntu:24.04",
+		"ubuntu-22.04":  "ubuntu:22.04",
+		"ubuntu-20.04":  "ubuntu:20.04",
+	}
+}
+
+// ParsePlatform parses a mapping given as label=image.
+func ParsePlatform(spec string) (label, image string, err error) {
+	label, image, ok := strings.Cut(spec, "=")
+	label = strings.TrimSpace(label)
+	image = strings.TrimSpace(image)
+	if !ok || label == "" || image == "" {
+		return "", "", fmt.Errorf("invalid platform %q: expected label=image", spec)
+	}
+
+	return label, image, nil
+}
+
+// LoadPlatformFile adds the mappings in the file at path to p. The file has
+// one label=image mapping per line; blank lines and lines starting with #
+// are ignored.
+func (p Platforms) LoadPlatformFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		label, image, err := ParsePlatform(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		p[label] = image
+	}
+
+	return scanner.Err()
+}
+
+// Image returns the image of the first label that has one.
+func (p Platforms) Image(labels []string) (string, bool) {
+	for _, label := range labels {
+		if image, ok := p[label]; ok {
+			return image, true
+		}
+	}
+	return "", false
+}
+
+// runnerImage returns the image that stands in for the runner a job's
+// runs-on selects. Labels without an image are an error rather than running
+// the job on a system it was not written for.
+func (e *Executor) runnerImage(job *Job, triggerContext *Context) (string, error) {
+	runsOn, err := job.RunsOn.evaluate(NewEvaluator(triggerContext))
+	if err != nil {
+		return "", fmt.Errorf("evaluating runs-on: %w", err)
+	}
+
+	if len(runsOn.Labels) == 0 {
+		return "", fmt.Errorf("job has no runs-on")
+	}
+
+	image, ok := e.platforms.Image(runsOn.Labels)
+	if !ok {
+		return "", fmt.Errorf("no image for runs-on %s; map a label to one with -P %s=<image>", runsOn, runsOn.Labels[0])
+	}
+
+	return image, nil
+}
+
+// evaluate returns the labels with expressions such as ${{ matrix.os }}
+// replaced by their values.
+func (r RunsOn) evaluate(evaluator *Evaluator) (RunsOn, error) {
+	labels := make([]string, 0, len(r.Labels))
+	for _, label := range r.Labels {
+		value, err := evaluator.Interpolate(label)
+		if err != nil {
+			return RunsOn{}, err
+		}
+		labels = append(labels, value)
+	}
+
+	return RunsOn{Labels: labels}, nil
+}
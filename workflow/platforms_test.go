@@ -0,0 +1,169 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		spec    string
+		label   string
+		image   string
+		wantErr bool
+	}{
+		{spec: "ubuntu-22.04=catthehacker/ubuntu:act-22.04", label: "ubuntu-22.04", image: "catthehacker/ubuntu:act-22.04"},
+		{spec: " self-hosted = debian:12 ", label: "self-hosted", image: "debian:12"},
+		{spec: "ubuntu-latest", wantErr: true},
+		{spec: "=ubuntu:latest", wantErr: true},
+		{spec: "ubuntu-latest=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			label, image, err := ParsePlatform(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.label, label)
+			assert.Equal(t, tt.image, image)
+		})
+	}
+}
+
+func TestPlatforms_LoadPlatformFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "platforms")
+	require.NoError(t, os.WriteFile(path, []byte(`# Runner images
+ubuntu-latest=catthehacker/ubuntu:act-latest
+
+self-hosted=debian:12
+`), 0o644))
+
+	platforms := DefaultPlatforms()
+	require.NoError(t, platforms.LoadPlatformFile(path))
+
+	assert.Equal(t, "catthehacker/ubuntu:act-latest", platforms["ubuntu-latest"])
+	assert.Equal(t, "debian:12", platforms["self-hosted"])
+	assert.Equal(t, "ubuntu:22.04", platforms["ubuntu-22.04"])
+
+	require.NoError(t, os.WriteFile(path, []byte("ubuntu-latest\n"), 0o644))
+	assert.ErrorContains(t, platforms.LoadPlatformFile(path), "platforms:1: invalid platform")
+}
+
+func TestPlatforms_Image(t *testing.T) {
+	platforms := Platforms{"linux": "debian:12", "ubuntu-latest": "ubuntu:latest"}
+
+	image, ok := platforms.Image([]string{"self-hosted", "linux", "ubuntu-latest"})
+	assert.True(t, ok)
+	assert.Equal(t, "debian:12", image)
+
+	_, ok = platforms.Image([]string{"macos-latest"})
+	assert.False(t, ok)
+}
+
+func TestExecutor_runnerImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		runsOn   []string
+		matrix   map[string]any
+		expected string
+		errMsg   string
+	}{
+		{name: "default label", runsOn: []string{"ubuntu-latest"}, expected: "ubuntu:latest"},
+		{name: "mapped label", runsOn: []string{"ubuntu-22.04"}, expected: "catthehacker/ubuntu:act-22.04"},
+		{name: "matrix label", runsOn: []string{"${{ matrix.os }}"}, matrix: map[string]any{"os": "ubuntu-22.04"}, expected: "catthehacker/ubuntu:act-22.04"},
+		{name: "unmapped label", runsOn: []string{"macos-latest"}, errMsg: "no image for runs-on macos-latest; map a label to one with -P macos-latest=<image>"},
+		{name: "unmapped matrix label", runsOn: []string{"${{ matrix.os }}"}, matrix: map[string]any{"os": "windows-latest"}, errMsg: "no image for runs-on windows-latest"},
+		{name: "no runs-on", errMsg: "job has no runs-on"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewExecutor(&Analyzer{}, NewMockDockerClient(), NewMockGitRepo())
+			platforms := DefaultPlatforms()
+			platforms["ubuntu-22.04"] = "catthehacker/ubuntu:act-22.04"
+			executor.SetPlatforms(platforms)
+
+			job := &Job{RunsOn: RunsOn{Labels: tt.runsOn}}
+			image, err := executor.runnerImage(job, (&Context{}).withMatrix(tt.matrix))
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, image)
+		})
+	}
+}
+
+func TestExecutor_executeJob_RunnerImage(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+	executor.SetPlatforms(Platforms{"ubuntu-22.04": "catthehacker/ubuntu:act-22.04"})
+
+	job := &Job{
+		Name:   "test",
+		RunsOn: RunsOn{Labels: []string{"${{ matrix.os }}"}},
+		Steps:  []Step{{ID: "step", Name: "Step", Run: "true"}},
+	}
+
+	mockDocker.On("PullImage", mock.Anything, "catthehacker/ubuntu:act-22.04").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.Image == "catthehacker/ubuntu:act-22.04"
+	})).Return("container-1", nil)
+	mockDocker.On("StartContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "container-1", mock.Anything).Return(&ExecResult{}, nil)
+	mockDocker.On("StopContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "container-1").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, (&Context{}).withMatrix(map[string]any{"os": "ubuntu-22.04"}))
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+}
+
+func TestExecutor_executeJob_UnmappedRunsOn(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	job := &Job{
+		Name:   "mac",
+		RunsOn: RunsOn{Labels: []string{"macos-latest"}},
+		Steps:  []Step{{ID: "step", Name: "Step", Run: "brew --version"}},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	assert.ErrorContains(t, err, "no image for runs-on macos-latest")
+	assert.Equal(t, "failure", executor.runtime.JobContext.Status)
+	mockDocker.AssertNotCalled(t, "PullImage", mock.Anything, mock.Anything)
+}
+
+func TestExecutor_executeJob_ContainerIgnoresRunsOn(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	job := &Job{
+		Name:      "mac",
+		RunsOn:    RunsOn{Labels: []string{"macos-latest"}},
+		Container: &Container{Image: "node:20"},
+		Steps:     []Step{{ID: "step", Name: "Step", Run: "node --version"}},
+	}
+
+	mockDocker.On("PullImage", mock.Anything, "node:20").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("container-1", nil)
+	mockDocker.On("StartContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "container-1", mock.Anything).Return(&ExecResult{}, nil)
+	mockDocker.On("StopContainer", mock.Anything, "container-1").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "container-1").Return(nil)
+
+	require.NoError(t, executor.executeJob(t.Context(), job, &Context{}))
+	mockDocker.AssertExpectations(t)
+}
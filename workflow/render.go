@@ -15,6 +15,7 @@ var (
 	pink   = lipgloss.Color("212")
 	purple = lipgloss.Color("99")
 	cyan   = lipgloss.Color("14")
+	yellow = lipgloss.Color("11")
 
 	// Styles
 	headerStyle  = lipgloss.NewStyle().Bold(true).Foreground(purple)
@@ -23,6 +24,7 @@ var (
 	passStyle    = lipgloss.NewStyle().Foreground(green)
 	failStyle    = lipgloss.NewStyle().Foreground(red)
 	skipStyle    = lipgloss.NewStyle().Foreground(gray)
+	warnStyle    = lipgloss.NewStyle().Foreground(yellow)
 	exprStyle    = lipgloss.NewStyle().Foreground(pink)
 	boldStyle    = lipgloss.NewStyle().Bold(true)
 	jobBoxStyle  = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("63")).Padding(0, 1)
@@ -30,24 +32,24 @@ var (
 )
 
 func Render(result *AnalysisResult) {
-	fmt.Println(headerStyle.Render("Workflow: " + result.WorkflowName))
-	fmt.Println(labelStyle.Render("Trigger: ") + valueStyle.Render(result.Trigger))
-	fmt.Println()
-
-	fmt.Println(headerStyle.Render("Context:"))
-	fmt.Printf("  %s = %s\n", labelStyle.Render("github.ref       "), valueStyle.Render(result.Context.GitHub.Ref))
-	fmt.Printf("  %s = %s\n", labelStyle.Render("github.event_name"), valueStyle.Render(result.Context.GitHub.EventName))
-	fmt.Printf("  %s = %s\n", labelStyle.Render("github.sha       "), valueStyle.Render(truncateSHA(result.Context.GitHub.SHA)))
-	fmt.Printf("  %s = %s\n", labelStyle.Render("github.actor     "), valueStyle.Render(result.Context.GitHub.Actor))
-	fmt.Printf("  %s = %s\n", labelStyle.Render("github.repository"), valueStyle.Render(result.Context.GitHub.Repository))
-	fmt.Println()
+	fmt.Fprintln(stdout, headerStyle.Render("Workflow: "+result.WorkflowName))
+	fmt.Fprintln(stdout, labelStyle.Render("Trigger: ")+valueStyle.Render(result.Trigger))
+	fmt.Fprintln(stdout)
+
+	fmt.Fprintln(stdout, headerStyle.Render("Context:"))
+	fmt.Fprintf(stdout, "  %s = %s\n", labelStyle.Render("github.ref       "), valueStyle.Render(result.Context.GitHub.Ref))
+	fmt.Fprintf(stdout, "  %s = %s\n", labelStyle.Render("github.event_name"), valueStyle.Render(result.Context.GitHub.EventName))
+	fmt.Fprintf(stdout, "  %s = %s\n", labelStyle.Render("github.sha       "), valueStyle.Render(truncateSHA(result.Context.GitHub.SHA)))
+	fmt.Fprintf(stdout, "  %s = %s\n", labelStyle.Render("github.actor     "), valueStyle.Render(result.Context.GitHub.Actor))
+	fmt.Fprintf(stdout, "  %s = %s\n", labelStyle.Render("github.repository"), valueStyle.Render(result.Context.GitHub.Repository))
+	fmt.Fprintln(stdout)
 
 	willRun := 0
 	skipped := 0
 
 	for _, job := range result.Jobs {
-		fmt.Println(renderJob(job))
-		fmt.Println()
+		fmt.Fprintln(stdout, renderJob(job))
+		fmt.Fprintln(stdout)
 
 		if job.WouldRun {
 			willRun++
@@ -56,11 +58,19 @@ func Render(result *AnalysisResult) {
 		}
 	}
 
+	if len(result.MissingSecrets) > 0 {
+		fmt.Fprintln(stdout, renderMissingSecrets(result.MissingSecrets))
+		fmt.Fprintln(stdout)
+	}
+
 	summary := fmt.Sprintf("Summary: %d job(s) will run", willRun)
 	if skipped > 0 {
 		summary += fmt.Sprintf(", %d skipped", skipped)
 	}
-	fmt.Println(summaryStyle.Render(summary))
+	if len(result.MissingSecrets) > 0 {
+		summary += fmt.Sprintf(", %d secret(s) missing", len(result.MissingSecrets))
+	}
+	fmt.Fprintln(stdout, summaryStyle.Render(summary))
 }
 
 func renderJob(job JobResult) string {
@@ -85,6 +95,10 @@ func renderJob(job JobResult) string {
 		b.WriteString(labelStyle.Render("needs: ") + "[" + strings.Join(job.Needs, ", ") + "]\n")
 	}
 
+	if job.PendingMatrix {
+		b.WriteString(labelStyle.Render("matrix: ") + "evaluated when the job runs\n")
+	}
+
 	if job.Condition != nil {
 		resultStr := passStyle.Render("TRUE")
 		if !job.Condition.Value {
@@ -136,6 +150,27 @@ func renderStep(step StepResult, jobWillRun bool) string {
 	return line
 }
 
+// renderMissingSecrets lists the secrets that were not provided, each with
+// the places that reference it.
+func renderMissingSecrets(missing []MissingSecret) string {
+	var b strings.Builder
+
+	b.WriteString(warnStyle.Bold(true).Render("[WARN] Missing secrets (they evaluate to null):") + "\n")
+	for _, secret := range missing {
+		b.WriteString("  " + boldStyle.Render(secret.Name))
+		if secret.ProvidedByGitHub {
+			b.WriteString(labelStyle.Render(" (provided by GitHub; supply with --secret to use a real token)"))
+		}
+		b.WriteString("\n")
+		for _, ref := range secret.Refs {
+			b.WriteString("    " + labelStyle.Render("used by ") + ref.String() + "\n")
+		}
+	}
+	b.WriteString(labelStyle.Render("Provide them with --secret, --secret-file or --secret-from-env."))
+
+	return b.String()
+}
+
 func truncateSHA(sha string) string {
 	if len(sha) > 7 {
 		return sha[:7]
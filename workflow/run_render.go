@@ -1,75 +1,105 @@
 package workflow
 
 import (
+	"bytes"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/telton/rehearse/internal/logger"
+	"github.com/telton/rehearse/internal/mask"
 	"github.com/telton/rehearse/ui"
 )
 
+// stdout is where renderers print, through the masker so that secrets and
+// values registered with ::add-mask:: never reach the terminal
+var stdout = mask.Writer(os.Stdout)
+
 // RunRenderer handles styled output for workflow execution
-type RunRenderer struct{}
+type RunRenderer struct {
+	prefix string
+}
 
 // NewRunRenderer creates a new run renderer
 func NewRunRenderer() *RunRenderer {
 	return &RunRenderer{}
 }
 
+// WithPrefix returns a renderer that prefixes every line it prints, so the
+// output of jobs running concurrently can be told apart
+func (r *RunRenderer) WithPrefix(prefix string) *RunRenderer {
+	return &RunRenderer{prefix: ui.Code.Render("["+prefix+"]") + " "}
+}
+
+// println prints s, prefixing each of its lines
+func (r *RunRenderer) println(s string) {
+	if r.prefix == "" {
+		fmt.Fprintln(stdout, s)
+		return
+	}
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = r.prefix + line
+	}
+	fmt.Fprintln(stdout, strings.Join(lines, "\n"))
+}
+
 // RenderWorkflowStart renders the initial workflow information
 func (r *RunRenderer) RenderWorkflowStart(workflowName, workingDir, event, ref string) {
 	logger.Debug("Rendering workflow start", "workflow", workflowName, "working_dir", workingDir, "event", event, "ref", ref)
 
 	title := ui.NewHeader(workflowName).WithEmoji("*").WithMargin()
-	fmt.Println(title.Render())
+	r.println(title.Render())
 
 	workDir := ui.NewLabelValue("[DIR] Working directory:", workingDir)
-	fmt.Println(workDir.Render())
+	r.println(workDir.Render())
 
 	eventInfo := ui.NewLabelValue("[EVENT] Event:", event)
-	fmt.Println(eventInfo.Render())
+	r.println(eventInfo.Render())
 
 	if ref != "" {
 		refInfo := ui.NewLabelValue("[REF] Ref:", ref)
-		fmt.Println(refInfo.Render())
+		r.println(refInfo.Render())
 	}
-	fmt.Println()
+	r.println("")
 }
 
 // RenderDockerCheck renders Docker availability check
 func (r *RunRenderer) RenderDockerCheck() {
 	status := ui.NewStatus("info", "Checking Docker availability...").WithIcon("[CHECK]")
-	fmt.Println(status.Render())
+	r.println(status.Render())
 }
 
 // RenderDockerSuccess renders successful Docker connection
 func (r *RunRenderer) RenderDockerSuccess() {
 	status := ui.NewStatus("success", "Docker is available").WithIcon("[OK]")
-	fmt.Println(status.Render())
+	r.println(status.Render())
 }
 
 // RenderDockerError renders Docker connection error
 func (r *RunRenderer) RenderDockerError(err error) {
 	warning := ui.NewStatus("warning", "Warning: "+err.Error()).WithIcon("[WARN]")
-	fmt.Println(warning.Render())
+	r.println(warning.Render())
 
 	suggestion := ui.NewStatus("warning", "To run workflows locally, please install and start Docker").WithIcon("[TIP]")
-	fmt.Println(suggestion.Render())
+	r.println(suggestion.Render())
 
 	link := ui.NewStatus("info", "Visit: https://docs.docker.com/get-docker/").WithIcon("   ")
-	fmt.Println(link.Render())
+	r.println(link.Render())
 }
 
 // RenderDockerInit renders Docker client initialization
 func (r *RunRenderer) RenderDockerInit() {
 	status := ui.NewStatus("info", "Initializing Docker client...").WithIcon("[DOCKER]")
-	fmt.Println(status.Render())
+	r.println(status.Render())
 }
 
 // RenderExecutionStart renders the start of workflow execution
 func (r *RunRenderer) RenderExecutionStart() {
 	status := ui.NewStatus("info", "Starting workflow execution...").WithIcon("[START]")
-	fmt.Println(status.Render())
+	r.println(status.Render())
 }
 
 // RenderJobStart renders the start of a job
@@ -78,23 +108,39 @@ func (r *RunRenderer) RenderJobStart(jobName string) {
 
 	renderer := ui.NewWorkflowRenderer()
 	header := renderer.RenderJobHeader("", jobName)
-	fmt.Println("[RUN] " + header)
+	r.println("[RUN] " + header)
 }
 
 // RenderJobSuccess renders successful job completion
 func (r *RunRenderer) RenderJobSuccess(jobName string, duration int64) {
 	message := fmt.Sprintf("Job %s completed successfully in %ds", jobName, duration)
 	status := ui.NewStatus("success", message).WithIcon("[OK]")
-	fmt.Println(status.Render())
-	fmt.Println()
+	r.println(status.Render())
+	r.println("")
 }
 
 // RenderJobError renders job failure
 func (r *RunRenderer) RenderJobError(jobName string, duration int64) {
 	message := fmt.Sprintf("Job %s failed after %ds", jobName, duration)
 	status := ui.NewStatus("error", message).WithIcon("[FAIL]")
-	fmt.Println(status.Render())
-	fmt.Println()
+	r.println(status.Render())
+	r.println("")
+}
+
+// RenderJobCancelled renders a job that was cancelled before it could finish
+func (r *RunRenderer) RenderJobCancelled(jobName, reason string) {
+	message := fmt.Sprintf("Job %s cancelled: %s", jobName, reason)
+	status := ui.NewStatus("warning", message).WithIcon("[CANCEL]")
+	r.println(status.Render())
+	r.println("")
+}
+
+// RenderJobSkipped renders a job that did not run
+func (r *RunRenderer) RenderJobSkipped(jobName, reason string) {
+	message := fmt.Sprintf("Job %s skipped: %s", jobName, reason)
+	status := ui.NewStatus("info", message).WithIcon("[SKIP]")
+	r.println(status.Render())
+	r.println("")
 }
 
 // RenderStepStart renders the start of a step
@@ -104,14 +150,14 @@ func (r *RunRenderer) RenderStepStart(stepNum, totalSteps int, stepName string)
 	message := fmt.Sprintf("Step %d/%d: %s", stepNum, totalSteps, stepName)
 	status := ui.NewStatus("info", message).WithIcon("[STEP]")
 	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
-	fmt.Println(formatted)
+	r.println(formatted)
 }
 
 // RenderStepSuccess renders successful step completion
 func (r *RunRenderer) RenderStepSuccess(stepName string) {
 	status := ui.NewStatus("success", stepName).WithIcon("[OK]")
 	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
-	fmt.Println(formatted)
+	r.println(formatted)
 }
 
 // RenderStepError renders step failure
@@ -119,7 +165,31 @@ func (r *RunRenderer) RenderStepError(stepName string, err error) {
 	message := fmt.Sprintf("%s - %v", stepName, err)
 	status := ui.NewStatus("error", message).WithIcon("[FAIL]")
 	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
-	fmt.Println(formatted)
+	r.println(formatted)
+}
+
+// RenderStepCancelled renders a step that was cancelled before it finished
+func (r *RunRenderer) RenderStepCancelled(stepName, reason string) {
+	message := fmt.Sprintf("%s cancelled: %s", stepName, reason)
+	status := ui.NewStatus("warning", message).WithIcon("[CANCEL]")
+	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
+	r.println(formatted)
+}
+
+// RenderStepFailureIgnored renders a failed step whose failure is ignored
+// because of continue-on-error
+func (r *RunRenderer) RenderStepFailureIgnored(stepName string, err error) {
+	message := fmt.Sprintf("%s - %v (continue-on-error)", stepName, err)
+	status := ui.NewStatus("warning", message).WithIcon("[WARN]")
+	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
+	r.println(formatted)
+}
+
+// RenderStepSkipped renders a step whose condition evaluated to false
+func (r *RunRenderer) RenderStepSkipped(stepName string) {
+	status := ui.NewStatus("info", stepName+" (skipped)").WithIcon("[SKIP]")
+	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
+	r.println(formatted)
 }
 
 // RenderDockerPull renders Docker image pulling
@@ -127,7 +197,40 @@ func (r *RunRenderer) RenderDockerPull(image string) {
 	renderer := ui.NewWorkflowRenderer()
 	message := renderer.RenderDockerOperation("Pulling image", image)
 	formatted := ui.WithMargin(ui.Muted, 4).Render(message)
-	fmt.Println(formatted)
+	r.println(formatted)
+}
+
+// RenderContainerKept renders a container left running because cleanup is
+// disabled
+func (r *RunRenderer) RenderContainerKept(description, containerID string) {
+	message := fmt.Sprintf("Keeping %s running as %s (cleanup disabled)", description, containerID)
+	status := ui.NewStatus("info", message).WithIcon("[KEEP]")
+	formatted := ui.WithMargin(ui.Muted, 4).Render(status.Render())
+	r.println(formatted)
+}
+
+// RenderServiceStart renders the start of a service container
+func (r *RunRenderer) RenderServiceStart(serviceID, image string) {
+	message := fmt.Sprintf("Starting service %s (%s)", serviceID, image)
+	status := ui.NewStatus("info", message).WithIcon("[SVC]")
+	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
+	r.println(formatted)
+}
+
+// RenderHostPortWait renders a job waiting for another to stop publishing a
+// host port its services need
+func (r *RunRenderer) RenderHostPortWait(port string) {
+	message := fmt.Sprintf("Waiting for host port %s, published by the services of another job", port)
+	status := ui.NewStatus("info", message).WithIcon("[SVC]")
+	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
+	r.println(formatted)
+}
+
+// RenderServiceHealthy renders a service whose healthcheck passed
+func (r *RunRenderer) RenderServiceHealthy(serviceID string) {
+	status := ui.NewStatus("success", "Service "+serviceID+" is healthy").WithIcon("[OK]")
+	formatted := ui.WithMargin(ui.Muted, 2).Render(status.Render())
+	r.println(formatted)
 }
 
 // RenderEnvironmentSet renders environment variable setting
@@ -136,7 +239,7 @@ func (r *RunRenderer) RenderEnvironmentSet(key, value string) {
 	message := renderer.RenderEnvironmentVar(key, value)
 	status := ui.NewStatus("info", message).WithIcon("[ENV]")
 	formatted := ui.WithMargin(ui.Muted, 4).Render(status.Render())
-	fmt.Println(formatted)
+	r.println(formatted)
 }
 
 // RenderOutputSet renders step output setting
@@ -144,7 +247,7 @@ func (r *RunRenderer) RenderOutputSet(stepID, key, value string) {
 	message := fmt.Sprintf("Set output: %s.%s=%s", stepID, key, value)
 	status := ui.NewStatus("info", message).WithIcon("[OUT]")
 	formatted := ui.WithMargin(ui.Muted, 4).Render(status.Render())
-	fmt.Println(formatted)
+	r.println(formatted)
 }
 
 // RenderContainerOutput renders container output/logs
@@ -155,20 +258,56 @@ func (r *RunRenderer) RenderContainerOutput(logs string) {
 
 	outputHeader := ui.NewStatus("info", "Output:").WithIcon("[LOG]")
 	formatted := ui.WithMargin(ui.Muted, 4).Render(outputHeader.Render())
-	fmt.Println(formatted)
+	r.println(formatted)
 
-	// Clean up Docker log formatting and print with proper indentation
 	cleanLogs := strings.TrimSpace(logs)
 	for _, line := range strings.Split(cleanLogs, "\n") {
-		// Skip Docker log stream headers (they start with special bytes)
-		if len(line) > 8 {
-			line = line[8:] // Remove Docker log header
-		}
 		if line != "" {
 			renderer := ui.NewWorkflowRenderer()
 			output := renderer.RenderOutput("  "+line, 6, false)
-			fmt.Println(output)
+			r.println(output)
+		}
+	}
+}
+
+// RenderLogLine renders a line of step output, timestamped and tagged with its
+// job and step. The tag names the job, so the line is not prefixed
+func (r *RunRenderer) RenderLogLine(jobName, stepName, line string, isError bool) {
+	renderer := ui.NewWorkflowRenderer()
+	fmt.Fprintln(stdout, renderer.RenderOutput(formatLogLine(time.Now(), jobName, stepName, line), 4, isError))
+}
+
+// formatLogLine formats a line of step output received at t
+func formatLogLine(t time.Time, jobName, stepName, line string) string {
+	return fmt.Sprintf("%s [%s/%s] %s", t.Format("15:04:05.000"), jobName, stepName, line)
+}
+
+// LogLineWriter is an io.Writer that hands each complete line written to it
+// to a render function, buffering partial lines until they are completed
+type LogLineWriter struct {
+	render  func(line string)
+	partial []byte
+}
+
+// Write renders every line completed by p
+func (w *LogLineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
 		}
+		w.render(strings.TrimSuffix(string(w.partial[:i]), "\r"))
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush renders the buffered partial line, if any
+func (w *LogLineWriter) Flush() {
+	if len(w.partial) > 0 {
+		w.render(string(w.partial))
+		w.partial = nil
 	}
 }
 
@@ -176,7 +315,7 @@ func (r *RunRenderer) RenderContainerOutput(logs string) {
 func (r *RunRenderer) RenderJobOutputsStart() {
 	status := ui.NewStatus("info", "Processing job outputs:").WithIcon("[STEP]")
 	formatted := ui.WithMargin(ui.Muted, 4).Render(status.Render())
-	fmt.Println(formatted)
+	r.println(formatted)
 }
 
 // RenderJobOutput renders a single job output
@@ -184,53 +323,90 @@ func (r *RunRenderer) RenderJobOutput(name, value string) {
 	message := fmt.Sprintf("%s = %s", name, value)
 	renderer := ui.NewWorkflowRenderer()
 	output := renderer.RenderOutput("  "+message, 6, false)
-	fmt.Println(output)
+	r.println(output)
+}
+
+// RenderJobSummary renders the markdown a job's steps wrote to
+// GITHUB_STEP_SUMMARY
+func (r *RunRenderer) RenderJobSummary(jobName, markdown string) {
+	status := ui.NewStatus("info", fmt.Sprintf("Summary of %s:", jobName)).WithIcon("[SUMMARY]")
+	r.println(ui.WithMargin(ui.Muted, 4).Render(status.Render()))
+
+	renderer := ui.NewWorkflowRenderer()
+	for line := range strings.Lines(strings.TrimRight(markdown, "\n")) {
+		r.println(renderer.RenderOutput(strings.TrimRight(line, "\r\n"), 6, false))
+	}
+}
+
+// RenderAnnotations renders the errors, warnings and notices steps reported,
+// each led by the file:line:column it points at so that editors can jump to it
+func (r *RunRenderer) RenderAnnotations(annotations []Annotation) {
+	if len(annotations) == 0 {
+		return
+	}
+
+	r.println("")
+	header := ui.NewStatus("info", fmt.Sprintf("Annotations (%d):", len(annotations))).WithIcon("[NOTE]")
+	r.println(header.Render())
+
+	for _, a := range annotations {
+		level, icon := "info", "[NOTICE]"
+		switch a.Level {
+		case "error":
+			level, icon = "error", "[ERROR]"
+		case "warning":
+			level, icon = "warning", "[WARN]"
+		}
+
+		status := ui.NewStatus(level, fmt.Sprintf("%s (%s/%s)", a, a.Job, a.Step)).WithIcon(icon)
+		r.println(ui.WithMargin(ui.Muted, 2).Render(status.Render()))
+	}
 }
 
 // RenderWorkflowSuccess renders successful workflow completion
 func (r *RunRenderer) RenderWorkflowSuccess() {
 	status := ui.NewStatus("success", "Workflow execution completed successfully!").WithIcon("[OK]")
-	fmt.Println(status.Render())
+	r.println(status.Render())
 }
 
 // RenderWorkflowError renders workflow execution error
 func (r *RunRenderer) RenderWorkflowError(err error) {
 	status := ui.NewStatus("error", "Workflow execution failed:").WithIcon("[FAIL]")
-	fmt.Println(status.Render())
+	r.println(status.Render())
 
 	errorDetails := ui.NewStatus("error", "   "+err.Error())
-	fmt.Println(errorDetails.Render())
+	r.println(errorDetails.Render())
 }
 
 // RenderExecutionSummary renders a summary of the workflow execution
 func (r *RunRenderer) RenderExecutionSummary(jobsRun, jobsFailed, stepsRun, stepsFailed int, totalDuration int64) {
-	fmt.Println()
+	r.println("")
 
 	renderer := ui.NewWorkflowRenderer()
 	summary := renderer.RenderSummary(jobsRun, jobsRun-jobsFailed, jobsFailed, 0)
-	fmt.Println(summary)
+	r.println(summary)
 
 	if stepsFailed == 0 {
 		stepStatus := ui.NewStatus("success", fmt.Sprintf("%d step(s) executed successfully", stepsRun)).WithIcon("[OK]")
-		fmt.Println(ui.WithMargin(ui.Muted, 2).Render(stepStatus.Render()))
+		r.println(ui.WithMargin(ui.Muted, 2).Render(stepStatus.Render()))
 	} else {
 		stepStatus := ui.NewStatus("error", fmt.Sprintf("%d step(s) executed, %d failed", stepsRun-stepsFailed, stepsFailed)).WithIcon("[FAIL]")
-		fmt.Println(ui.WithMargin(ui.Muted, 2).Render(stepStatus.Render()))
+		r.println(ui.WithMargin(ui.Muted, 2).Render(stepStatus.Render()))
 	}
 
 	timeInfo := ui.NewLabelValue("[TIME] Total time:", fmt.Sprintf("%ds", totalDuration)).WithIndent(2)
-	fmt.Println(timeInfo.Render())
+	r.println(timeInfo.Render())
 }
 
 // RenderSeparator renders a visual separator
 func (r *RunRenderer) RenderSeparator() {
 	separator := ui.NewSeparator()
-	fmt.Println(separator.Render())
+	r.println(separator.Render())
 }
 
 // RenderWarning renders a general warning message
 func (r *RunRenderer) RenderWarning(message string) {
 	warning := ui.NewStatus("warning", "Warning: "+message).WithIcon("[WARN]")
 	formatted := ui.WithMargin(ui.Muted, 4).Render(warning.Render())
-	fmt.Println(formatted)
+	r.println(formatted)
 }
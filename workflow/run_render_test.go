@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLineWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected []string
+		flushed  []string
+	}{
+		{
+			name:     "whole lines",
+			writes:   []string{"one\ntwo\n"},
+			expected: []string{"one", "two"},
+		},
+		{
+			name:     "line split across writes",
+			writes:   []string{"Running te", "sts...", "\nok\n"},
+			expected: []string{"Running tests...", "ok"},
+		},
+		{
+			name:     "carriage return line endings",
+			writes:   []string{"windows\r\n"},
+			expected: []string{"windows"},
+		},
+		{
+			name:     "empty lines are kept",
+			writes:   []string{"a\n\nb\n"},
+			expected: []string{"a", "", "b"},
+		},
+		{
+			name:     "trailing partial line waits for flush",
+			writes:   []string{"done\nno newline"},
+			expected: []string{"done"},
+			flushed:  []string{"done", "no newline"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			w := &LogLineWriter{render: func(line string) { lines = append(lines, line) }}
+
+			for _, write := range tt.writes {
+				n, err := w.Write([]byte(write))
+				assert.NoError(t, err)
+				assert.Equal(t, len(write), n)
+			}
+			assert.Equal(t, tt.expected, lines)
+
+			w.Flush()
+			if tt.flushed == nil {
+				tt.flushed = tt.expected
+			}
+			assert.Equal(t, tt.flushed, lines)
+		})
+	}
+}
+
+func TestFormatLogLine(t *testing.T) {
+	at := time.Date(2025, 1, 2, 15, 4, 5, 123_000_000, time.UTC)
+
+	assert.Equal(t, "15:04:05.123 [test (ubuntu)/Run tests] ok  ./...", formatLogLine(at, "test (ubuntu)", "Run tests", "ok  ./..."))
+}
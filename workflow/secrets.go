@@ -0,0 +1,261 @@
+package workflow
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// GitHubToken is the secret GitHub provides to every workflow run. Locally it
+// is only set when given like any other secret.
+const GitHubToken = "GITHUB_TOKEN"
+
+// namePattern matches the names GitHub allows for secrets and variables.
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseVariable parses a secret or variable given as NAME=VALUE; kind names
+// which in errors. Errors never include the value, which may be a secret.
+func ParseVariable(kind, spec string) (name, value string, err error) {
+	name, value, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid %s: expected NAME=VALUE", kind)
+	}
+	if !namePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid %s name %q: use letters, digits and underscores, not starting with a digit", kind, name)
+	}
+
+	return name, value, nil
+}
+
+// LoadDotenv reads the NAME=VALUE pairs in the dotenv file at path. Blank
+// lines and lines starting with # are ignored, and a line may start with
+// export. Values may be quoted: single-quoted values are taken as they are,
+// while double-quoted ones may use the escapes \n, \r, \t, \" and \\. Either
+// may span several lines. Unquoted values end at a # preceded by a space.
+func LoadDotenv(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	values := make(map[string]string)
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		name = strings.TrimSpace(name)
+		if !ok || !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s:%d: expected NAME=VALUE", path, lineNum)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'") || strings.HasPrefix(value, `"`):
+			quote := value[0]
+			raw := value[1:]
+			end := closingQuote(raw, quote)
+			for end < 0 {
+				if i++; i >= len(lines) {
+					return nil, fmt.Errorf("%s:%d: unterminated quoted value for %s", path, lineNum, name)
+				}
+				raw += "\n" + lines[i]
+				end = closingQuote(raw, quote)
+			}
+
+			value = raw[:end]
+			if quote == '"' {
+				value = dotenvUnescaper.Replace(value)
+			}
+		default:
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+
+		values[name] = value
+	}
+
+	return values, nil
+}
+
+var dotenvUnescaper = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+
+// closingQuote returns the index of the quote that ends s, or -1 if there is
+// none. Double quotes can be escaped with a backslash; single quotes cannot.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// SecretRef is a place a workflow references a secret.
+type SecretRef struct {
+	Name string
+	Job  string // Job ID, or "" for the workflow's env
+	Step string // Step name, or "" outside the job's steps
+}
+
+// String describes where the secret is referenced.
+func (r SecretRef) String() string {
+	switch {
+	case r.Job == "":
+		return "workflow env"
+	case r.Step == "":
+		return "job " + r.Job
+	}
+	return "job " + r.Job + ", step " + r.Step
+}
+
+// secretRefPattern matches the secrets an expression references, as either
+// secrets.NAME or secrets['NAME'].
+var secretRefPattern = regexp.MustCompile(`\bsecrets(?:\.([A-Za-z_][A-Za-z0-9_]*)|\[\s*'([A-Za-z_][A-Za-z0-9_]*)'\s*\])`)
+
+// SecretRefs returns the secrets the workflow references, each once per place
+// that uses it, in the order the places are declared. Only the ${{ }}
+// expressions of the fields that take them are searched, along with if:
+// conditions, which may leave out the ${{ }}, and the secrets a job passes to
+// the reusable workflow it calls. Secrets passed on with inherit are not known
+// without reading the called workflow, so they are left out.
+func (w *Workflow) SecretRefs() []SecretRef {
+	var refs []SecretRef
+	seen := make(map[SecretRef]bool)
+	add := func(job, step, expr string) {
+		for _, match := range secretRefPattern.FindAllStringSubmatch(expr, -1) {
+			ref := SecretRef{Name: cmp.Or(match[1], match[2]), Job: job, Step: step}
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	collect := func(job, step string, fields ...string) {
+		for _, field := range fields {
+			for _, expr := range embeddedExpressions(field) {
+				add(job, step, expr)
+			}
+		}
+	}
+
+	collect("", "", mapValues(w.Env)...)
+
+	for _, jobID := range w.JobIDs() {
+		job := w.Jobs[jobID]
+
+		add(jobID, "", job.If)
+		collect(jobID, "", job.Name, job.ContinueOnError, job.TimeoutMinutes)
+		collect(jobID, "", mapValues(job.Env)...)
+		collect(jobID, "", mapValues(job.Outputs)...)
+		for _, container := range containerFields(job) {
+			collect(jobID, "", container...)
+		}
+		if job.Secrets != nil {
+			collect(jobID, "", mapValues(job.Secrets.Values)...)
+		}
+
+		for i, step := range job.Steps {
+			name := cmp.Or(stepDisplayName(step), fmt.Sprintf("step %d", i+1))
+			collect(jobID, name, step.Name)
+			add(jobID, name, step.If)
+			collect(jobID, name, step.Run, step.WorkingDirectory, step.ContinueOnError, step.TimeoutMinutes)
+			collect(jobID, name, mapValues(step.With)...)
+			collect(jobID, name, mapValues(step.Env)...)
+		}
+	}
+
+	return refs
+}
+
+// embeddedExpressions returns the contents of the ${{ }} expressions embedded
+// in s. An unterminated one is left out.
+func embeddedExpressions(s string) []string {
+	var exprs []string
+	for {
+		start := strings.Index(s, "${{")
+		if start == -1 {
+			return exprs
+		}
+		end := strings.Index(s[start:], "}}")
+		if end == -1 {
+			return exprs
+		}
+		exprs = append(exprs, s[start+3:start+end])
+		s = s[start+end+2:]
+	}
+}
+
+// containerFields returns the fields of a job's container and services that
+// take expressions, one slice per container.
+func containerFields(job Job) [][]string {
+	containers := []*Container{job.Container}
+	for _, id := range slices.Sorted(maps.Keys(job.Services)) {
+		containers = append(containers, job.Services[id])
+	}
+
+	var fields [][]string
+	for _, c := range containers {
+		if c == nil {
+			continue
+		}
+		f := append([]string{c.Image, c.Options}, mapValues(c.Env)...)
+		if c.Credentials != nil {
+			f = append(f, c.Credentials.Username, c.Credentials.Password)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// mapValues returns the values of m ordered by key.
+func mapValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		values = append(values, m[key])
+	}
+	return values
+}
+
+// MissingSecret is a secret a workflow references that was not provided.
+type MissingSecret struct {
+	Name             string
+	Refs             []SecretRef // Where the workflow references it
+	ProvidedByGitHub bool        // Set on GitHub whatever the repository's secrets, as GITHUB_TOKEN is
+}
+
+// MissingSecrets returns the secrets refs reference that are not in secrets,
+// in the order they are first referenced. Such secrets evaluate to null, as
+// they would on GitHub if the repository lacked them, except for GITHUB_TOKEN,
+// which GitHub always provides.
+func MissingSecrets(refs []SecretRef, secrets map[string]string) []MissingSecret {
+	var missing []MissingSecret
+	index := make(map[string]int)
+	for _, ref := range refs {
+		if _, ok := secrets[ref.Name]; ok {
+			continue
+		}
+
+		i, seen := index[ref.Name]
+		if !seen {
+			i = len(missing)
+			index[ref.Name] = i
+			missing = append(missing, MissingSecret{Name: ref.Name, ProvidedByGitHub: ref.Name == GitHubToken})
+		}
+		missing[i].Refs = append(missing[i].Refs, ref)
+	}
+	return missing
+}
@@ -0,0 +1,185 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVariable(t *testing.T) {
+	tests := []struct {
+		spec   string
+		name   string
+		value  string
+		errMsg string
+	}{
+		{spec: "TOKEN=abc123", name: "TOKEN", value: "abc123"},
+		{spec: " API_KEY =a=b ", name: "API_KEY", value: "a=b "},
+		{spec: "EMPTY=", name: "EMPTY", value: ""},
+		{spec: "TOKEN", errMsg: "invalid secret: expected NAME=VALUE"},
+		{spec: "=abc123", errMsg: "invalid secret: expected NAME=VALUE"},
+		{spec: "1TOKEN=abc123", errMsg: `invalid secret name "1TOKEN"`},
+		{spec: "MY-TOKEN=abc123", errMsg: `invalid secret name "MY-TOKEN"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			name, value, err := ParseVariable("secret", tt.spec)
+			if tt.errMsg != "" {
+				require.ErrorContains(t, err, tt.errMsg)
+				assert.NotContains(t, err.Error(), "abc123")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.value, value)
+		})
+	}
+}
+
+func TestLoadDotenv(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string]string
+		errMsg   string
+	}{
+		{
+			name: "plain values",
+			content: `# Deploy credentials
+TOKEN=abc123
+
+export REGION = eu-west-1
+URL=https://example.com/#anchor # the endpoint
+`,
+			expected: map[string]string{"TOKEN": "abc123", "REGION": "eu-west-1", "URL": "https://example.com/#anchor"},
+		},
+		{
+			name:     "single-quoted values are literal",
+			content:  `PASSWORD='p@ss # not a comment \n'`,
+			expected: map[string]string{"PASSWORD": `p@ss # not a comment \n`},
+		},
+		{
+			name:     "double-quoted values are unescaped",
+			content:  `MESSAGE="say \"hi\"\tthen\nleave \\ now" # greeting`,
+			expected: map[string]string{"MESSAGE": "say \"hi\"\tthen\nleave \\ now"},
+		},
+		{
+			name:     "multiline value",
+			content:  "KEY=\"-----BEGIN KEY-----\r\nabc\r\n-----END KEY-----\"\r\nNEXT=1\r\n",
+			expected: map[string]string{"KEY": "-----BEGIN KEY-----\nabc\n-----END KEY-----", "NEXT": "1"},
+		},
+		{
+			name:    "unterminated quote",
+			content: "A=1\nKEY='abc\n",
+			errMsg:  ":2: unterminated quoted value for KEY",
+		},
+		{
+			name:    "not an assignment",
+			content: "A=1\n\nTOKEN\n",
+			errMsg:  ":3: expected NAME=VALUE",
+		},
+		{
+			name:    "invalid name",
+			content: "MY-TOKEN=abc\n",
+			errMsg:  ":1: expected NAME=VALUE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			values, err := LoadDotenv(path)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, values)
+		})
+	}
+}
+
+func TestWorkflow_SecretRefs(t *testing.T) {
+	wf := &Workflow{
+		Env: map[string]string{"SLACK": "${{ secrets.SLACK_WEBHOOK }}"},
+		Jobs: map[string]Job{
+			"build": {
+				Container: &Container{
+					Image:       "ghcr.io/org/builder",
+					Credentials: &Credentials{Username: "bot", Password: "${{ secrets.REGISTRY_TOKEN }}"},
+				},
+				Steps: []Step{
+					{Name: "Checkout", Uses: "actions/checkout@v4", With: map[string]string{"token": "${{ secrets.GITHUB_TOKEN }}"}},
+					{Run: "make", Env: map[string]string{"NPM": "${{ secrets['NPM_TOKEN'] }}", "ALSO": "${{ secrets.NPM_TOKEN }}"}},
+					// Outside expressions, secrets.NAME is plain text.
+					{Name: "Lint", Run: "grep -rn secrets.API_KEY src", With: map[string]string{"pattern": "secrets.API_KEY"}},
+				},
+			},
+			"deploy": {
+				If: "${{ secrets.DEPLOY_KEY != '' }}",
+				Steps: []Step{
+					{ID: "deploy", Name: "Deploy", Run: "deploy --key ${{ secrets.DEPLOY_KEY }} --notify ${{ mysecrets.OTHER }}"},
+					{If: "secrets.DEPLOY_KEY", Uses: "./notify"},
+					{Env: map[string]string{"KEY": "${{ secrets.DEPLOY_KEY }}"}},
+				},
+			},
+			"release": {
+				Uses:    "./.github/workflows/release.yaml",
+				Secrets: &JobSecrets{Values: map[string]string{"token": "${{ secrets.RELEASE_TOKEN }}"}},
+			},
+			"inherit": {
+				Uses:    "./.github/workflows/release.yaml",
+				Secrets: &JobSecrets{Inherit: true},
+			},
+		},
+		jobOrder: []string{"build", "deploy", "release", "inherit"},
+	}
+
+	assert.Equal(t, []SecretRef{
+		{Name: "SLACK_WEBHOOK"},
+		{Name: "REGISTRY_TOKEN", Job: "build"},
+		{Name: "GITHUB_TOKEN", Job: "build", Step: "Checkout"},
+		{Name: "NPM_TOKEN", Job: "build", Step: "make"},
+		{Name: "DEPLOY_KEY", Job: "deploy"},
+		{Name: "DEPLOY_KEY", Job: "deploy", Step: "Deploy"},
+		{Name: "DEPLOY_KEY", Job: "deploy", Step: "./notify"},
+		{Name: "DEPLOY_KEY", Job: "deploy", Step: "step 3"},
+		{Name: "RELEASE_TOKEN", Job: "release"},
+	}, wf.SecretRefs())
+}
+
+func TestEmbeddedExpressions(t *testing.T) {
+	assert.Nil(t, embeddedExpressions("echo secrets.TOKEN"))
+	assert.Equal(t, []string{" secrets.A ", "secrets.B"}, embeddedExpressions("a=${{ secrets.A }} b=${{secrets.B}} c=${{ secrets.C"))
+}
+
+func TestSecretRef_String(t *testing.T) {
+	assert.Equal(t, "workflow env", SecretRef{Name: "TOKEN"}.String())
+	assert.Equal(t, "job deploy", SecretRef{Name: "TOKEN", Job: "deploy"}.String())
+	assert.Equal(t, "job deploy, step Deploy", SecretRef{Name: "TOKEN", Job: "deploy", Step: "Deploy"}.String())
+}
+
+func TestMissingSecrets(t *testing.T) {
+	refs := []SecretRef{
+		{Name: "DEPLOY_KEY", Job: "deploy"},
+		{Name: "TOKEN", Job: "build", Step: "Build"},
+		{Name: "DEPLOY_KEY", Job: "deploy", Step: "Deploy"},
+		{Name: "GITHUB_TOKEN", Job: "build", Step: "Checkout"},
+	}
+
+	assert.Equal(t, []MissingSecret{
+		{Name: "DEPLOY_KEY", Refs: []SecretRef{refs[0], refs[2]}},
+		{Name: "TOKEN", Refs: []SecretRef{refs[1]}},
+		{Name: "GITHUB_TOKEN", Refs: []SecretRef{refs[3]}, ProvidedByGitHub: true},
+	}, MissingSecrets(refs, nil))
+	assert.Equal(t, []MissingSecret{
+		{Name: "DEPLOY_KEY", Refs: []SecretRef{refs[0], refs[2]}},
+	}, MissingSecrets(refs, map[string]string{"TOKEN": "", "GITHUB_TOKEN": "ghp_local"}))
+	assert.Empty(t, MissingSecrets(refs, map[string]string{"TOKEN": "a", "DEPLOY_KEY": "b", "GITHUB_TOKEN": "c"}))
+}
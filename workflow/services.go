@@ -0,0 +1,230 @@
+package workflow
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/telton/rehearse/internal/logger"
+)
+
+// healthPollInterval is how often a service's health is checked while
+// waiting for it to become healthy.
+var healthPollInterval = time.Second
+
+// startServices creates a bridge network for the job and starts its service
+// containers on it, each reachable by its service ID as hostname. It returns
+// once every service that has a healthcheck reports healthy.
+func (e *Executor) startServices(ctx context.Context, job *Job) error {
+	if len(job.Services) == 0 {
+		return nil
+	}
+
+	// As on GitHub, a service whose image is empty is not started.
+	configs := make(map[string]*ContainerConfig)
+	for id, service := range job.Services {
+		if service == nil || service.Image == "" {
+			continue
+		}
+		config, err := containerConfigFor(service, e.runtime.WorkingDir)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", id, err)
+		}
+		configs[id] = config
+	}
+
+	ports, err := fixedHostPorts(configs)
+	if err != nil {
+		return err
+	}
+	if len(ports) > 0 && e.hostPorts != nil {
+		release, err := e.hostPorts.reserve(ctx, ports, e.renderer.RenderHostPortWait)
+		if err != nil {
+			return fmt.Errorf("waiting for host ports: %w", err)
+		}
+		e.releasePorts = release
+	}
+
+	name := "rehearse-" + strings.ToLower(rand.Text()[:12])
+	networkID, err := e.docker.CreateNetwork(ctx, name)
+	if err != nil {
+		return fmt.Errorf("creating network: %w", err)
+	}
+	e.runtime.Networks[name] = &NetworkInfo{ID: networkID, Name: name}
+
+	ids := slices.Sorted(maps.Keys(configs))
+	for _, id := range ids {
+		if err := e.startService(ctx, id, job.Services[id], configs[id], name); err != nil {
+			return fmt.Errorf("service %s: %w", id, err)
+		}
+	}
+
+	for _, id := range ids {
+		if container, ok := e.runtime.Containers[id]; ok {
+			if err := e.waitForService(ctx, id, container.ID); err != nil {
+				return fmt.Errorf("service %s: %w", id, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// startService starts the container of a single service on the job network.
+func (e *Executor) startService(ctx context.Context, id string, service *Container, config *ContainerConfig, network string) error {
+	config.Networks = []string{network}
+	config.NetworkAliases = append([]string{id}, config.NetworkAliases...)
+
+	e.renderer.RenderServiceStart(id, service.Image)
+	if err := pullContainerImage(ctx, e.docker, service.Image, service.Credentials); err != nil {
+		return err
+	}
+
+	return startContainer(ctx, e.docker, config, func(container *ContainerInfo) {
+		e.runtime.Containers[id] = container
+	})
+}
+
+// fixedHostPorts returns the host ports, such as 5432/tcp, that services with
+// the given configs publish, leaving out those docker picks at random. A port
+// published twice is an error, as docker could only bind it once.
+func fixedHostPorts(configs map[string]*ContainerConfig) ([]string, error) {
+	publishers := make(map[string]string) // Host port -> ID of the service publishing it
+	for _, id := range slices.Sorted(maps.Keys(configs)) {
+		_, bindings, err := portBindings(configs[id].Ports)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", id, err)
+		}
+
+		for port, portBindings := range bindings {
+			for _, binding := range portBindings {
+				if binding.HostPort == "" {
+					continue
+				}
+				hostPort := binding.HostPort + "/" + string(port.Proto())
+				if other, ok := publishers[hostPort]; ok {
+					return nil, fmt.Errorf("host port %s is published by both service %s and service %s", hostPort, other, id)
+				}
+				publishers[hostPort] = id
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(publishers)), nil
+}
+
+// hostPorts tracks the fixed host ports the services of running jobs
+// publish. Jobs publishing the same port, such as the instances of a matrix
+// whose service maps 5432:5432, take turns rather than failing to start.
+type hostPorts struct {
+	mu    sync.Mutex
+	inUse map[string]chan struct{} // Host port -> closed once the job publishing it is done
+}
+
+// reserve waits until no other job publishes any of ports, calling waiting
+// with the port it waits for, then reserves them all at once, so that a job
+// never holds some of the ports it needs while waiting for the others. The
+// returned function releases them.
+func (h *hostPorts) reserve(ctx context.Context, ports []string, waiting func(port string)) (func(), error) {
+	for {
+		h.mu.Lock()
+		busyPort := slices.IndexFunc(ports, func(port string) bool { return h.inUse[port] != nil })
+		if busyPort < 0 {
+			done := make(chan struct{})
+			if h.inUse == nil {
+				h.inUse = make(map[string]chan struct{})
+			}
+			for _, port := range ports {
+				h.inUse[port] = done
+			}
+			h.mu.Unlock()
+
+			return func() {
+				h.mu.Lock()
+				defer h.mu.Unlock()
+				for _, port := range ports {
+					delete(h.inUse, port)
+				}
+				close(done)
+			}, nil
+		}
+		busy := h.inUse[ports[busyPort]]
+		h.mu.Unlock()
+
+		waiting(ports[busyPort])
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-busy:
+		}
+	}
+}
+
+// waitForService waits until a service's healthcheck passes. Services
+// without a healthcheck are ready as soon as they run.
+func (e *Executor) waitForService(ctx context.Context, id, containerID string) error {
+	for {
+		status, err := e.docker.ContainerHealth(ctx, containerID)
+		if err != nil {
+			return fmt.Errorf("checking health: %w", err)
+		}
+
+		switch status {
+		case "healthy":
+			e.renderer.RenderServiceHealthy(id)
+			return nil
+		case "unhealthy":
+			return fmt.Errorf("container is unhealthy")
+		case "", "none":
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for healthcheck: %w", context.Cause(ctx))
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
+
+// stopServices removes the job's service containers and network, and releases
+// the host ports they publish. When cleanup is disabled they are left running
+// so they can be inspected.
+func (e *Executor) stopServices() {
+	if e.releasePorts != nil {
+		defer e.releasePorts()
+		e.releasePorts = nil
+	}
+
+	containers := maps.Clone(e.runtime.Containers)
+	networks := maps.Clone(e.runtime.Networks)
+	clear(e.runtime.Containers)
+	clear(e.runtime.Networks)
+
+	ids := slices.Sorted(maps.Keys(containers))
+	if !e.cleanup {
+		for _, id := range ids {
+			e.renderer.RenderContainerKept("service "+id, containers[id].ID)
+		}
+		return
+	}
+
+	for _, id := range ids {
+		removeContainer(e.docker, containers[id].ID, "service", id)
+	}
+
+	for _, network := range networks {
+		if err := e.docker.RemoveNetwork(context.Background(), network.ID); err != nil {
+			logger.Warn("Failed to remove network", "network", network.Name, "error", err)
+		}
+	}
+}
+
+// networkNames returns the names of the networks the job's containers join.
+func (r *Runtime) networkNames() []string {
+	return slices.Sorted(maps.Keys(r.Networks))
+}
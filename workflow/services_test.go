@@ -0,0 +1,313 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newServicesJob returns a job with postgres and redis services, as used by
+// integration tests.
+func newServicesJob() *Job {
+	return &Job{
+		Name:   "integration",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Services: map[string]*Container{
+			"postgres": {
+				Image:   "postgres:16",
+				Env:     map[string]string{"POSTGRES_PASSWORD": "postgres"},
+				Ports:   []string{"5432:5432"},
+				Options: "--health-cmd pg_isready --health-interval 1s",
+			},
+			"redis": {Image: "redis:7"},
+		},
+		Steps: []Step{{ID: "test", Name: "Test", Run: "psql -h localhost -c 'select 1' && redis-cli -h localhost ping"}},
+	}
+}
+
+func isNetworkName(name string) bool {
+	return strings.HasPrefix(name, "rehearse-")
+}
+
+func TestExecutor_executeJob_Services(t *testing.T) {
+	healthPollInterval = time.Millisecond
+	t.Cleanup(func() { healthPollInterval = time.Second })
+
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	var network string
+	mockDocker.On("CreateNetwork", mock.Anything, mock.MatchedBy(isNetworkName)).
+		Run(func(args mock.Arguments) { network = args.String(1) }).
+		Return("network-1", nil).Once()
+
+	mockDocker.On("PullImage", mock.Anything, "postgres:16").Return(nil).Once()
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.Image == "postgres:16" &&
+			assert.ObjectsAreEqual([]string{network}, config.Networks) &&
+			assert.ObjectsAreEqual([]string{"postgres"}, config.NetworkAliases) &&
+			assert.ObjectsAreEqual([]string{"POSTGRES_PASSWORD=postgres"}, config.Env) &&
+			assert.ObjectsAreEqual([]string{"5432:5432"}, config.Ports) &&
+			config.Healthcheck != nil
+	})).Return("postgres-container", nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, "postgres-container").Return(nil).Once()
+
+	mockDocker.On("PullImage", mock.Anything, "redis:7").Return(nil).Once()
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.Image == "redis:7" &&
+			assert.ObjectsAreEqual([]string{"redis"}, config.NetworkAliases)
+	})).Return("redis-container", nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, "redis-container").Return(nil).Once()
+
+	// Postgres takes a couple of checks to become healthy.
+	mockDocker.On("ContainerHealth", mock.Anything, "postgres-container").Return("starting", nil).Twice()
+	mockDocker.On("ContainerHealth", mock.Anything, "postgres-container").Return("healthy", nil).Once()
+	mockDocker.On("ContainerHealth", mock.Anything, "redis-container").Return("none", nil).Once()
+
+	// The job has no container, so as on a runner its steps reach the
+	// services through the ports they publish on the host.
+	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil).Once()
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.Image == "ubuntu:latest" && config.NetworkMode == "host" && len(config.Networks) == 0
+	})).Return("job-container", nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, "job-container").Return(nil).Once()
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.AnythingOfType("*workflow.ExecConfig")).Return(&ExecResult{}, nil).Once()
+
+	var removed []string
+	for _, id := range []string{"job-container", "postgres-container", "redis-container"} {
+		mockDocker.On("StopContainer", mock.Anything, id).Return(nil).Once()
+		mockDocker.On("RemoveContainer", mock.Anything, id).
+			Run(func(args mock.Arguments) { removed = append(removed, args.String(1)) }).
+			Return(nil).Once()
+	}
+	mockDocker.On("RemoveNetwork", mock.Anything, "network-1").
+		Run(func(args mock.Arguments) { removed = append(removed, args.String(1)) }).
+		Return(nil).Once()
+
+	err := executor.executeJob(t.Context(), newServicesJob(), &Context{})
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+	assert.Equal(t, []string{"job-container", "postgres-container", "redis-container", "network-1"}, removed)
+	assert.Empty(t, executor.runtime.Containers)
+	assert.Empty(t, executor.runtime.Networks)
+}
+
+func TestExecutor_executeJob_ServicesWithJobContainer(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	job := &Job{
+		Name:      "integration",
+		Container: &Container{Image: "node:22"},
+		Services:  map[string]*Container{"redis": {Image: "redis:7"}},
+		Steps:     []Step{{ID: "test", Name: "Test", Run: "redis-cli -h redis ping"}},
+	}
+
+	var network string
+	mockDocker.On("CreateNetwork", mock.Anything, mock.MatchedBy(isNetworkName)).
+		Run(func(args mock.Arguments) { network = args.String(1) }).
+		Return("network-1", nil)
+	mockDocker.On("PullImage", mock.Anything, mock.Anything).Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.Image == "redis:7"
+	})).Return("redis-container", nil).Once()
+	mockDocker.On("ContainerHealth", mock.Anything, "redis-container").Return("none", nil)
+
+	// The job container joins the job network, where services are reached
+	// by hostname.
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return config.Image == "node:22" && config.NetworkMode == "" &&
+			assert.ObjectsAreEqual([]string{network}, config.Networks)
+	})).Return("job-container", nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, mock.Anything).Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.AnythingOfType("*workflow.ExecConfig")).Return(&ExecResult{}, nil).Once()
+	mockDocker.On("StopContainer", mock.Anything, mock.Anything).Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, mock.Anything).Return(nil)
+	mockDocker.On("RemoveNetwork", mock.Anything, "network-1").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+}
+
+func TestExecutor_executeJob_ServicesHostPortTwice(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	job := &Job{
+		Name:   "integration",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Services: map[string]*Container{
+			"primary": {Image: "postgres:16", Ports: []string{"5432:5432"}},
+			"replica": {Image: "postgres:16", Ports: []string{"5432:5432"}},
+		},
+		Steps: []Step{{ID: "test", Name: "Test", Run: "true"}},
+	}
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	assert.ErrorContains(t, err, "host port 5432/tcp is published by both service primary and service replica")
+	mockDocker.AssertNotCalled(t, "CreateContainer", mock.Anything, mock.Anything)
+}
+
+func TestHostPorts_reserve(t *testing.T) {
+	var ports hostPorts
+
+	releaseFirst, err := ports.reserve(t.Context(), []string{"5432/tcp", "6379/tcp"}, func(string) {
+		t.Error("the first job should not wait")
+	})
+	require.NoError(t, err)
+
+	// A job publishing other ports does not wait.
+	releaseOther, err := ports.reserve(t.Context(), []string{"8080/tcp", "5432/udp"}, func(string) {
+		t.Error("a job publishing other ports should not wait")
+	})
+	require.NoError(t, err)
+	releaseOther()
+
+	// A job publishing one of the same ports waits for the first to end.
+	waited := make(chan string, 1)
+	reserved := make(chan func(), 1)
+	go func() {
+		release, err := ports.reserve(t.Context(), []string{"6379/tcp"}, func(port string) { waited <- port })
+		assert.NoError(t, err)
+		reserved <- release
+	}()
+
+	assert.Equal(t, "6379/tcp", <-waited)
+	select {
+	case <-reserved:
+		t.Fatal("port reserved while still in use")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	releaseFirst()
+	(<-reserved)()
+
+	// A job cancelled while waiting gives up.
+	releaseFirst, err = ports.reserve(t.Context(), []string{"5432/tcp"}, nil)
+	require.NoError(t, err)
+	defer releaseFirst()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	_, err = ports.reserve(ctx, []string{"5432/tcp"}, func(string) { cancel() })
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestExecutor_executeJob_UnhealthyService(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+
+	job := &Job{
+		Name:     "integration",
+		RunsOn:   RunsOn{Labels: []string{"ubuntu-latest"}},
+		Services: map[string]*Container{"postgres": {Image: "postgres:16", Options: "--health-cmd pg_isready"}},
+		Steps:    []Step{{ID: "test", Name: "Test", Run: "true"}},
+	}
+
+	mockDocker.On("CreateNetwork", mock.Anything, mock.MatchedBy(isNetworkName)).Return("network-1", nil)
+	mockDocker.On("PullImage", mock.Anything, "postgres:16").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("postgres-container", nil)
+	mockDocker.On("StartContainer", mock.Anything, "postgres-container").Return(nil)
+	mockDocker.On("ContainerHealth", mock.Anything, "postgres-container").Return("unhealthy", nil)
+	mockDocker.On("StopContainer", mock.Anything, "postgres-container").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "postgres-container").Return(nil)
+	mockDocker.On("RemoveNetwork", mock.Anything, "network-1").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	assert.ErrorContains(t, err, "service postgres: container is unhealthy")
+	assert.Equal(t, "failure", executor.runtime.JobContext.Status)
+	mockDocker.AssertExpectations(t)
+	mockDocker.AssertNotCalled(t, "ExecInContainer", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExecutor_executeJob_ServicesNoCleanup(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+	executor.SetCleanup(false)
+
+	job := &Job{
+		Name:     "integration",
+		RunsOn:   RunsOn{Labels: []string{"ubuntu-latest"}},
+		Services: map[string]*Container{"redis": {Image: "redis:7"}},
+		Steps:    []Step{{ID: "action", Name: "Action", Uses: "docker://redis:7"}},
+	}
+
+	mockDocker.On("CreateNetwork", mock.Anything, mock.MatchedBy(isNetworkName)).Return("network-1", nil)
+	mockDocker.On("PullImage", mock.Anything, "redis:7").Return(nil)
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return len(config.NetworkAliases) == 1
+	})).Return("redis-container", nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, "redis-container").Return(nil)
+	mockDocker.On("ContainerHealth", mock.Anything, "redis-container").Return("none", nil)
+
+	// Docker actions join the job network too, and are always removed.
+	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
+		return len(config.NetworkAliases) == 0 && len(config.Networks) == 1
+	})).Return("action-container", nil).Once()
+	mockDocker.On("StartContainer", mock.Anything, "action-container").Return(nil)
+	mockDocker.On("GetContainerLogs", mock.Anything, "action-container").Return(&ExecResult{}, nil)
+	mockDocker.On("WaitForContainer", mock.Anything, "action-container").Return(0, nil)
+	mockDocker.On("StopContainer", mock.Anything, "action-container").Return(nil)
+	mockDocker.On("RemoveContainer", mock.Anything, "action-container").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+	mockDocker.AssertNotCalled(t, "RemoveContainer", mock.Anything, "redis-container")
+	mockDocker.AssertNotCalled(t, "RemoveNetwork", mock.Anything, mock.Anything)
+}
+
+func TestExecutor_executeJob_EmptyServiceImage(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := newTestExecutor(t, mockDocker, NewMockGitRepo())
+	executor.executors = nil
+
+	job := &Job{
+		Name:     "optional-service",
+		RunsOn:   RunsOn{Labels: []string{"ubuntu-latest"}},
+		Services: map[string]*Container{"db": {Image: ""}},
+	}
+
+	mockDocker.On("CreateNetwork", mock.Anything, mock.MatchedBy(isNetworkName)).Return("network-1", nil)
+	mockDocker.On("RemoveNetwork", mock.Anything, "network-1").Return(nil)
+
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	require.NoError(t, err)
+	mockDocker.AssertExpectations(t)
+	mockDocker.AssertNotCalled(t, "CreateContainer", mock.Anything, mock.Anything)
+}
+
+func TestEvaluateContainers(t *testing.T) {
+	ctx := &Context{
+		Secrets: map[string]string{"REGISTRY_TOKEN": "hunter2"},
+		Matrix:  map[string]any{"postgres": "16"},
+	}
+	job := &Job{
+		Container: &Container{
+			Image:       "ghcr.io/acme/builder",
+			Credentials: &Credentials{Username: "octocat", Password: "${{ secrets.REGISTRY_TOKEN }}"},
+		},
+		Services: map[string]*Container{
+			"db": {Image: "postgres:${{ matrix.postgres }}", Ports: []string{"5432"}, Options: "--health-cmd pg_isready"},
+		},
+	}
+
+	require.NoError(t, evaluateContainers(job, ctx))
+
+	assert.Equal(t, &Credentials{Username: "octocat", Password: "hunter2"}, job.Container.Credentials)
+	assert.Equal(t, &Container{Image: "postgres:16", Ports: []string{"5432"}, Options: "--health-cmd pg_isready"}, job.Services["db"])
+
+	job.Services["db"] = &Container{Image: "postgres:${{ matrix.postgres"}
+	assert.ErrorContains(t, evaluateContainers(job, ctx), "evaluating service db: image")
+}
@@ -0,0 +1,111 @@
+package workflow
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// shellSpec describes how a named shell runs a step's script.
+type shellSpec struct {
+	template string // Command, with {0} standing for the script's path
+	ext      string // Extension of the script file, which some shells require
+	prologue string // Prepended to the script
+	epilogue string // Appended to the script
+}
+
+// shells holds the shells a run step can name, with GitHub's semantics on
+// Linux runners: bash fails on the first failing command of a pipeline, sh
+// on the first failing command, and pwsh on the first error.
+var shells = map[string]shellSpec{
+	"bash":   {template: "bash --noprofile --norc -eo pipefail {0}", ext: ".sh"},
+	"sh":     {template: "sh -e {0}", ext: ".sh"},
+	"python": {template: "python {0}", ext: ".py"},
+	"pwsh": {
+		template: `pwsh -command ". '{0}'"`,
+		ext:      ".ps1",
+		prologue: "$ErrorActionPreference = 'stop'\n",
+		epilogue: "\nif ((Test-Path -LiteralPath variable:\\LASTEXITCODE)) { exit $LASTEXITCODE }\n",
+	},
+}
+
+// defaultShellCmd runs a script as GitHub does when a step names no shell:
+// with bash -e if the container has bash, and sh -e otherwise.
+const defaultShellCmd = `if command -v bash >/dev/null 2>&1; then exec bash -e "$0"; fi; exec sh -e "$0"`
+
+// shellFor returns how shell runs a script. A shell that is not one of the
+// named ones is a command template, which must contain {0}.
+func shellFor(shell string) (shellSpec, error) {
+	if spec, ok := shells[shell]; ok {
+		return spec, nil
+	}
+
+	switch {
+	case shell == "powershell" || shell == "cmd":
+		return shellSpec{}, fmt.Errorf("shell %s is only available on Windows runners", shell)
+	case !strings.Contains(shell, "{0}"):
+		return shellSpec{}, fmt.Errorf("unsupported shell %q: use bash, sh, pwsh, python, or a command containing {0}", shell)
+	}
+
+	return shellSpec{template: shell}, nil
+}
+
+// writeStepScript writes a run step's script to a file in tempDir, which the
+// job container mounts at /github/env, and returns the command that runs it
+// with shell along with the path of the file on the host.
+func writeStepScript(tempDir, shell, script string) (cmd []string, file string, err error) {
+	if tempDir == "" {
+		return nil, "", fmt.Errorf("no temp directory to write the step script to")
+	}
+
+	var spec shellSpec
+	if shell != "" {
+		if spec, err = shellFor(shell); err != nil {
+			return nil, "", err
+		}
+	}
+
+	f, err := os.CreateTemp(tempDir, "step-*"+cmp.Or(spec.ext, ".sh"))
+	if err != nil {
+		return nil, "", fmt.Errorf("creating step script: %w", err)
+	}
+	defer f.Close()
+
+	// The container may run as a user other than the one that owns the file.
+	if err := f.Chmod(0o644); err != nil {
+		return nil, "", fmt.Errorf("creating step script: %w", err)
+	}
+	if _, err := f.WriteString(spec.prologue + script + spec.epilogue); err != nil {
+		return nil, "", fmt.Errorf("writing step script: %w", err)
+	}
+
+	containerPath := path.Join("/github/env", filepath.Base(f.Name()))
+	if shell == "" {
+		return []string{"sh", "-c", defaultShellCmd, containerPath}, f.Name(), nil
+	}
+
+	words, err := splitShellWords(spec.template)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid shell %q: %w", shell, err)
+	}
+	for i, word := range words {
+		words[i] = strings.ReplaceAll(word, "{0}", containerPath)
+	}
+
+	return words, f.Name(), nil
+}
+
+// stepWorkingDir returns the directory a run step runs in inside the job
+// container. Relative directories are relative to the workspace.
+func stepWorkingDir(dir string) string {
+	if dir == "" {
+		return "/github/workspace"
+	}
+	if path.IsAbs(dir) {
+		return path.Clean(dir)
+	}
+	return path.Join("/github/workspace", dir)
+}
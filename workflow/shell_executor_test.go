@@ -1,10 +1,14 @@
 package workflow
 
 import (
+	"path"
+	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShellStepExecutor_CanExecute(t *testing.T) {
@@ -67,33 +71,25 @@ func TestShellStepExecutor_Execute_BasicCommand(t *testing.T) {
 	executor := CreateTestShellExecutor(mockDocker)
 
 	step := CreateTestStep("echo-step", "Echo Step", "echo 'Hello World'")
-	runtime := CreateTestRuntime("/tmp/workspace")
-
-	expectedConfig := &ContainerConfig{
-		Image:      "ubuntu:latest",
-		Cmd:        []string{"sh", "-c", "echo 'Hello World'"},
-		WorkingDir: "/github/workspace",
-		Volumes: []VolumeMount{
-			{Source: "/tmp/workspace", Target: "/github/workspace", Type: "bind"},
-		},
-	}
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil)
 	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
-		return config.Image == expectedConfig.Image &&
-			len(config.Cmd) == 3 &&
-			config.Cmd[0] == "sh" &&
-			config.Cmd[1] == "-c" &&
-			config.Cmd[2] == "echo 'Hello World'" &&
-			config.WorkingDir == expectedConfig.WorkingDir &&
-			len(config.Volumes) == 1 &&
+		return config.Image == "ubuntu:latest" &&
+			assert.ObjectsAreEqual(jobContainerEntrypoint, config.Entrypoint) &&
+			assert.ObjectsAreEqual(jobContainerCmd, config.Cmd) &&
+			config.WorkingDir == "/github/workspace" &&
+			len(config.Volumes) == 2 &&
 			config.Volumes[0].Source == "/tmp/workspace" &&
-			config.Volumes[0].Target == "/github/workspace"
+			config.Volumes[0].Target == "/github/workspace" &&
+			config.Volumes[1].Source == runtime.TempDir &&
+			config.Volumes[1].Target == "/github/env"
 	})).Return("container-123", nil)
-
 	mockDocker.On("StartContainer", mock.Anything, "container-123").Return(nil)
-	mockDocker.On("StopContainer", mock.Anything, "container-123").Return(nil)
-	mockDocker.On("RemoveContainer", mock.Anything, "container-123").Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "container-123", mock.MatchedBy(func(config *ExecConfig) bool {
+		return stepScript(runtime.TempDir, config) == "echo 'Hello World'" &&
+			config.WorkingDir == "/github/workspace"
+	})).Return(&ExecResult{Stdout: "Hello World\n"}, nil)
 
 	ctx := t.Context()
 	result, err := executor.Execute(ctx, step, runtime)
@@ -102,11 +98,67 @@ func TestShellStepExecutor_Execute_BasicCommand(t *testing.T) {
 	assert.NotNil(t, result)
 	assert.True(t, result.Success)
 	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, "Hello World\n", result.Stdout)
 	assert.NotNil(t, result.Outputs)
 
 	mockDocker.AssertExpectations(t)
 
-	assert.Empty(t, runtime.Containers)
+	// The container outlives the step; the job removes it.
+	assert.Equal(t, &ContainerInfo{ID: "container-123", Image: "ubuntu:latest", Status: "running"}, runtime.JobContainer)
+	mockDocker.AssertNotCalled(t, "RemoveContainer", mock.Anything, mock.Anything)
+}
+
+func TestShellStepExecutor_Execute_ReusesJobContainer(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Image: "ubuntu:latest", Status: "running"}
+
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.AnythingOfType("*workflow.ExecConfig")).
+		Return(&ExecResult{ExitCode: 0}, nil).Once()
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.AnythingOfType("*workflow.ExecConfig")).
+		Return(&ExecResult{ExitCode: 2, Stderr: "boom\n"}, nil).Once()
+
+	result, err := executor.Execute(t.Context(), CreateTestStep("install", "Install", "apt-get install -y jq"), runtime)
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	result, err = executor.Execute(t.Context(), CreateTestStep("use", "Use", "jq --version"), runtime)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, 2, result.ExitCode)
+	assert.Equal(t, "boom\n", result.Stderr)
+
+	mockDocker.AssertExpectations(t)
+	mockDocker.AssertNotCalled(t, "CreateContainer", mock.Anything, mock.Anything)
+}
+
+func TestShellStepExecutor_Execute_StreamsOutput(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Status: "running"}
+
+	var streamed bool
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.AnythingOfType("*workflow.ExecConfig")).
+		Run(func(args mock.Arguments) {
+			config := args.Get(2).(*ExecConfig)
+			require.NotNil(t, config.Stdout)
+			require.NotNil(t, config.Stderr)
+
+			_, err := config.Stdout.Write([]byte("running tests\n"))
+			require.NoError(t, err)
+			streamed = true
+		}).
+		Return(&ExecResult{Stdout: "running tests\n"}, nil)
+
+	result, err := executor.Execute(t.Context(), CreateTestStep("test", "Test", "go test ./..."), runtime)
+
+	require.NoError(t, err)
+	assert.True(t, streamed)
+	assert.Equal(t, "running tests\n", result.Stdout)
 }
 
 func TestShellStepExecutor_Execute_CustomContainer(t *testing.T) {
@@ -114,22 +166,40 @@ func TestShellStepExecutor_Execute_CustomContainer(t *testing.T) {
 	executor := CreateTestShellExecutor(mockDocker)
 
 	step := CreateTestStep("node-step", "Node Step", "npm test")
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
+	credentials := &Credentials{Username: "octocat", Password: "hunter2"}
 	runtime.JobContext.Job.Container = &Container{
-		Image: "node:18",
-		Env:   map[string]string{"NODE_ENV": "test"},
+		Image:       "ghcr.io/acme/node:18",
+		Env:         map[string]string{"NODE_ENV": "test"},
+		Ports:       []string{"3000:3000"},
+		Volumes:     []string{"npm-cache:/root/.npm"},
+		Options:     "--user 1001 --entrypoint node",
+		Credentials: credentials,
 	}
 
-	mockDocker.On("PullImage", mock.Anything, "node:18").Return(nil)
+	mockDocker.On("RegistryLogin", mock.Anything, "ghcr.io/acme/node:18", credentials).Return(nil)
+	mockDocker.On("PullImage", mock.Anything, "ghcr.io/acme/node:18").Return(nil)
 	mockDocker.On("CreateContainer", mock.Anything, mock.MatchedBy(func(config *ContainerConfig) bool {
-		return config.Image == "node:18" &&
-			config.Cmd[2] == "npm test"
+		return config.Image == "ghcr.io/acme/node:18" &&
+			assert.ObjectsAreEqual([]string{"NODE_ENV=test"}, config.Env) &&
+			assert.ObjectsAreEqual([]string{"3000:3000"}, config.Ports) &&
+			assert.ObjectsAreEqual([]VolumeMount{
+				{Source: "/tmp/workspace", Target: "/github/workspace", Type: "bind"},
+				{Source: "npm-cache", Target: "/root/.npm", Type: "volume"},
+				{Source: runtime.TempDir, Target: "/github/env", Type: "bind"},
+			}, config.Volumes) &&
+			config.User == "1001" &&
+			// The image's entrypoint must not replace the keep-alive command.
+			assert.ObjectsAreEqual(jobContainerEntrypoint, config.Entrypoint) &&
+			assert.ObjectsAreEqual(jobContainerCmd, config.Cmd)
 	})).Return("node-container", nil)
 
 	mockDocker.On("StartContainer", mock.Anything, "node-container").Return(nil)
-	mockDocker.On("StopContainer", mock.Anything, "node-container").Return(nil)
-	mockDocker.On("RemoveContainer", mock.Anything, "node-container").Return(nil)
+	mockDocker.On("ExecInContainer", mock.Anything, "node-container", mock.MatchedBy(func(config *ExecConfig) bool {
+		return stepScript(runtime.TempDir, config) == "npm test" &&
+			slices.Contains(config.Env, "NODE_ENV=test")
+	})).Return(&ExecResult{}, nil)
 
 	ctx := t.Context()
 	result, err := executor.Execute(ctx, step, runtime)
@@ -139,12 +209,59 @@ func TestShellStepExecutor_Execute_CustomContainer(t *testing.T) {
 	mockDocker.AssertExpectations(t)
 }
 
+func TestShellStepExecutor_Execute_RegistryLoginFailure(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContext.Job.Container = &Container{
+		Image:       "ghcr.io/acme/private:1",
+		Credentials: &Credentials{Username: "octocat", Password: "wrong"},
+	}
+
+	mockDocker.On("RegistryLogin", mock.Anything, "ghcr.io/acme/private:1", mock.Anything).Return(assert.AnError)
+
+	result, err := executor.Execute(t.Context(), CreateTestStep("build", "Build", "make"), runtime)
+
+	assert.Nil(t, result)
+	assert.ErrorContains(t, err, "failed to log in to registry for ghcr.io/acme/private:1")
+	mockDocker.AssertNotCalled(t, "PullImage", mock.Anything, mock.Anything)
+}
+
+func TestShellStepExecutor_Execute_InvalidContainerOptions(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContext.Job.Container = &Container{Image: "node:18", Options: "--cpus all"}
+
+	result, err := executor.Execute(t.Context(), CreateTestStep("build", "Build", "make"), runtime)
+
+	assert.Nil(t, result)
+	assert.ErrorContains(t, err, "invalid job container: parsing options: --cpus")
+	mockDocker.AssertNotCalled(t, "PullImage", mock.Anything, mock.Anything)
+}
+
+func TestShellStepExecutor_Execute_NoRunnerImage(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.RunnerImage = ""
+
+	result, err := executor.Execute(t.Context(), CreateTestStep("build", "Build", "make"), runtime)
+
+	assert.Nil(t, result)
+	assert.ErrorContains(t, err, "no image to run the job in")
+	mockDocker.AssertNotCalled(t, "PullImage", mock.Anything, mock.Anything)
+}
+
 func TestShellStepExecutor_Execute_ContainerCreationFailure(t *testing.T) {
 	mockDocker := NewMockDockerClient()
 	executor := CreateTestShellExecutor(mockDocker)
 
 	step := CreateTestStep("failing-step", "Failing Step", "echo 'fail'")
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil)
 	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("", assert.AnError)
@@ -155,6 +272,7 @@ func TestShellStepExecutor_Execute_ContainerCreationFailure(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, result)
 	assert.Contains(t, err.Error(), "failed to create container")
+	assert.Nil(t, runtime.JobContainer)
 	mockDocker.AssertExpectations(t)
 }
 
@@ -163,13 +281,11 @@ func TestShellStepExecutor_Execute_ContainerStartFailure(t *testing.T) {
 	executor := CreateTestShellExecutor(mockDocker)
 
 	step := CreateTestStep("start-fail-step", "Start Fail Step", "echo 'test'")
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 
 	mockDocker.On("PullImage", mock.Anything, "ubuntu:latest").Return(nil)
 	mockDocker.On("CreateContainer", mock.Anything, mock.AnythingOfType("*workflow.ContainerConfig")).Return("container-456", nil)
 	mockDocker.On("StartContainer", mock.Anything, "container-456").Return(assert.AnError)
-	mockDocker.On("StopContainer", mock.Anything, "container-456").Return(nil)
-	mockDocker.On("RemoveContainer", mock.Anything, "container-456").Return(nil)
 
 	ctx := t.Context()
 	result, err := executor.Execute(ctx, step, runtime)
@@ -179,7 +295,26 @@ func TestShellStepExecutor_Execute_ContainerStartFailure(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to start container")
 
 	mockDocker.AssertExpectations(t)
-	assert.Empty(t, runtime.Containers)
+
+	// Kept so that the job removes it.
+	require.NotNil(t, runtime.JobContainer)
+	assert.Equal(t, "created", runtime.JobContainer.Status)
+}
+
+func TestShellStepExecutor_Execute_ExecFailure(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Status: "running"}
+
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.AnythingOfType("*workflow.ExecConfig")).Return(nil, assert.AnError)
+
+	result, err := executor.Execute(t.Context(), CreateTestStep("step", "Step", "true"), runtime)
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "failed to run command in container")
 }
 
 func TestShellStepExecutor_buildEnvironment(t *testing.T) {
@@ -195,36 +330,45 @@ func TestShellStepExecutor_buildEnvironment(t *testing.T) {
 		},
 	}
 
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 	runtime.JobContext.Job.Env = map[string]string{
 		"JOB_VAR":    "job_value",
 		"COMMON_VAR": "job_value",
 	}
+	runtime.Context = &Context{
+		GitHub: GitHubContext{
+			EventName:  "push",
+			Ref:        "refs/heads/main",
+			SHA:        "abc123",
+			Actor:      "octocat",
+			Repository: "octo-org/hello-world",
+		},
+	}
 
-	env := executor.buildEnvironment(step, runtime)
-
-	AssertEnvironmentContains(t, env, "JOB_VAR=job_value")
-	AssertEnvironmentContains(t, env, "STEP_VAR=step_value")
-	AssertEnvironmentContains(t, env, "COMMON_VAR=step_override") // step should override job
-	AssertEnvironmentContains(t, env, "GITHUB_WORKSPACE=/github/workspace")
-	AssertEnvironmentContains(t, env, "GITHUB_ACTOR=rehearse")
-	AssertEnvironmentContains(t, env, "RUNNER_OS=Linux")
-	AssertEnvironmentContains(t, env, "RUNNER_ARCH=X64")
-
-	// Verify we have the expected number of environment variables
-	// Job vars (2) + Step vars (2, with override) + GitHub defaults (4) = 7
-	expectedVars := []string{
-		"JOB_VAR=job_value",
-		"STEP_VAR=step_value",
-		"COMMON_VAR=step_override",
+	env, err := executor.buildEnvironment(step, runtime)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"CI=true",
+		"COMMON_VAR=step_override", // step should override job
+		"GITHUB_ACTIONS=true",
+		"GITHUB_ACTOR=octocat",
+		"GITHUB_API_URL=https://api.github.com",
+		"GITHUB_EVENT_NAME=push",
+		"GITHUB_GRAPHQL_URL=https://api.github.com/graphql",
+		"GITHUB_REF=refs/heads/main",
+		"GITHUB_REF_NAME=main",
+		"GITHUB_REF_TYPE=branch",
+		"GITHUB_REPOSITORY=octo-org/hello-world",
+		"GITHUB_REPOSITORY_OWNER=octo-org",
+		"GITHUB_SERVER_URL=https://github.com",
+		"GITHUB_SHA=abc123",
 		"GITHUB_WORKSPACE=/github/workspace",
-		"GITHUB_ACTOR=rehearse",
-		"GITHUB_REPOSITORY=local/repo",
-		"RUNNER_OS=Linux",
+		"JOB_VAR=job_value",
 		"RUNNER_ARCH=X64",
-	}
-
-	assert.GreaterOrEqual(t, len(env), len(expectedVars))
+		"RUNNER_OS=Linux",
+		"STEP_VAR=step_value",
+	}, env)
 }
 
 func TestShellStepExecutor_buildEnvironment_NoJobContext(t *testing.T) {
@@ -243,7 +387,8 @@ func TestShellStepExecutor_buildEnvironment_NoJobContext(t *testing.T) {
 		WorkingDir: "/tmp",
 	}
 
-	env := executor.buildEnvironment(step, runtime)
+	env, err := executor.buildEnvironment(step, runtime)
+	require.NoError(t, err)
 
 	AssertEnvironmentContains(t, env, "STEP_ONLY=value")
 	AssertEnvironmentContains(t, env, "GITHUB_WORKSPACE=/github/workspace")
@@ -259,14 +404,186 @@ func TestShellStepExecutor_buildEnvironment_EmptyEnvironments(t *testing.T) {
 		Run:  "echo 'test'",
 	}
 
-	runtime := CreateTestRuntime("/tmp/workspace")
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
 	runtime.JobContext.Job.Env = nil
+	runtime.Context = &Context{GitHub: GitHubContext{Actor: "octocat"}}
 
-	env := executor.buildEnvironment(step, runtime)
+	env, err := executor.buildEnvironment(step, runtime)
+	require.NoError(t, err)
 
 	AssertEnvironmentContains(t, env, "GITHUB_WORKSPACE=/github/workspace")
-	AssertEnvironmentContains(t, env, "GITHUB_ACTOR=rehearse")
+	AssertEnvironmentContains(t, env, "GITHUB_ACTOR=octocat")
 	AssertEnvironmentContains(t, env, "RUNNER_OS=Linux")
 
 	assert.GreaterOrEqual(t, len(env), 5)
 }
+
+func TestShellStepExecutor_buildEnvironment_WorkflowEnvAndExpressions(t *testing.T) {
+	executor := &ShellStepExecutor{}
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.WorkflowEnv = map[string]string{
+		"REGISTRY": "ghcr.io",
+		"SHARED":   "workflow",
+	}
+	runtime.JobContext.Job.Env = map[string]string{
+		"IMAGE":  "${{ env.REGISTRY }}/${{ github.repository }}",
+		"SHARED": "job",
+	}
+	runtime.DynamicEnv = map[string]string{"SHARED": "github_env", "LITERAL": "${{ not evaluated }}"}
+	runtime.Context = &Context{
+		GitHub: GitHubContext{Repository: "octo-org/app"},
+		Matrix: map[string]any{"version": "1.2"},
+	}
+
+	step := &Step{Env: map[string]string{"MESSAGE": "pushing ${{ env.IMAGE }}:${{ matrix.version }} over ${{ env.SHARED }}"}}
+
+	env, err := executor.buildEnvironment(step, runtime)
+	require.NoError(t, err)
+
+	AssertEnvironmentContains(t, env, "IMAGE=ghcr.io/octo-org/app")
+	AssertEnvironmentContains(t, env, "SHARED=github_env")
+	AssertEnvironmentContains(t, env, "LITERAL=${{ not evaluated }}")
+	AssertEnvironmentContains(t, env, "MESSAGE=pushing ghcr.io/octo-org/app:1.2 over github_env")
+
+	runtime.JobContext.Job.Env["BROKEN"] = "${{ env.IMAGE == }}"
+	_, err = executor.buildEnvironment(step, runtime)
+	assert.ErrorContains(t, err, "evaluating env: job env BROKEN")
+}
+
+func TestShellStepExecutor_buildEnvironment_DefaultsNotOverridable(t *testing.T) {
+	executor := &ShellStepExecutor{}
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.Context = &Context{GitHub: GitHubContext{Actor: "octocat"}}
+
+	env, err := executor.buildEnvironment(&Step{Env: map[string]string{"GITHUB_ACTOR": "someone-else"}}, runtime)
+	require.NoError(t, err)
+
+	AssertEnvironmentContains(t, env, "GITHUB_ACTOR=octocat")
+	assert.NotContains(t, env, "GITHUB_ACTOR=someone-else")
+}
+
+func TestShellStepExecutor_Execute_ShellAndWorkingDirectory(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Status: "running"}
+	runtime.JobContext.Job.Defaults.Run = RunDefaults{Shell: "bash", WorkingDirectory: "frontend"}
+
+	var scripts []string
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.MatchedBy(func(config *ExecConfig) bool {
+		return config.Cmd[0] == "bash" && config.WorkingDir == "/github/workspace/frontend"
+	})).Run(func(args mock.Arguments) {
+		scripts = append(scripts, args.Get(2).(*ExecConfig).Cmd[5])
+	}).Return(&ExecResult{}, nil).Once()
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.MatchedBy(func(config *ExecConfig) bool {
+		return config.Cmd[0] == "python" && config.WorkingDir == "/srv"
+	})).Return(&ExecResult{}, nil).Once()
+
+	_, err := executor.Execute(t.Context(), CreateTestStep("build", "Build", "npm run build"), runtime)
+	require.NoError(t, err)
+
+	step := CreateTestStep("check", "Check", "print('ok')")
+	step.Shell = "python"
+	step.WorkingDirectory = "/srv"
+	_, err = executor.Execute(t.Context(), step, runtime)
+	require.NoError(t, err)
+
+	mockDocker.AssertExpectations(t)
+
+	// Scripts are removed once their step ends.
+	require.Len(t, scripts, 1)
+	assert.NoFileExists(t, filepath.Join(runtime.TempDir, path.Base(scripts[0])))
+}
+
+func TestShellStepExecutor_Execute_UnsupportedShell(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Status: "running"}
+
+	step := CreateTestStep("build", "Build", "dir")
+	step.Shell = "cmd"
+	result, err := executor.Execute(t.Context(), step, runtime)
+
+	assert.Nil(t, result)
+	assert.ErrorContains(t, err, "only available on Windows runners")
+	mockDocker.AssertNotCalled(t, "ExecInContainer", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGithubEnv_Refs(t *testing.T) {
+	tests := []struct {
+		ref          string
+		expectedName string
+		expectedType string
+	}{
+		{ref: "refs/heads/feature/login", expectedName: "feature/login", expectedType: "branch"},
+		{ref: "refs/tags/v1.0.0", expectedName: "v1.0.0", expectedType: "tag"},
+		{ref: "refs/pull/42/merge", expectedName: "refs/pull/42/merge", expectedType: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			env := githubEnv(&Context{GitHub: GitHubContext{Ref: tt.ref}})
+
+			assert.Equal(t, tt.expectedName, env["GITHUB_REF_NAME"])
+			assert.Equal(t, tt.expectedType, env["GITHUB_REF_TYPE"])
+		})
+	}
+}
+
+func TestShellStepExecutor_Execute_Expressions(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Status: "running"}
+	runtime.Context = &Context{
+		Matrix: map[string]any{"node": 20},
+		Steps: map[string]StepContext{
+			"version": {Outcome: "success", Conclusion: "success", Outputs: map[string]string{"tag": "v1.2.3"}},
+		},
+	}
+
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.MatchedBy(func(config *ExecConfig) bool {
+		return stepScript(runtime.TempDir, config) == "echo node 20 building v1.2.3" &&
+			config.WorkingDir == "/github/workspace/packages/app"
+	})).Return(&ExecResult{}, nil).Once()
+
+	step := CreateTestStep("build", "Build", "echo node ${{ matrix.node }} building ${{ steps.version.outputs.tag }}")
+	step.Env = map[string]string{"APP_DIR": "packages/app"}
+	step.WorkingDirectory = "${{ env.APP_DIR }}"
+
+	_, err := executor.Execute(t.Context(), step, runtime)
+	require.NoError(t, err)
+
+	_, err = executor.Execute(t.Context(), CreateTestStep("broken", "Broken", "echo ${{ toYAML('[]') }}"), runtime)
+	assert.ErrorContains(t, err, "evaluating run")
+	assert.ErrorContains(t, err, "unknown function: toYAML")
+
+	mockDocker.AssertExpectations(t)
+}
+
+func TestShellStepExecutor_Execute_GithubPath(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Status: "running"}
+	runtime.Path = []string{"/opt/tool/bin", "/opt/node/bin"}
+
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.MatchedBy(func(config *ExecConfig) bool {
+		return len(config.Cmd) == 8 &&
+			assert.ObjectsAreEqual([]string{"sh", "-c", `PATH="$0:$PATH"; exec "$@"`, "/opt/tool/bin:/opt/node/bin", "sh", "-c", defaultShellCmd}, config.Cmd[:7]) &&
+			slices.Contains(config.Env, "GITHUB_PATH=/github/env/GITHUB_PATH") &&
+			slices.Contains(config.Env, "GITHUB_STEP_SUMMARY=/github/env/GITHUB_STEP_SUMMARY")
+	})).Return(&ExecResult{}, nil).Once()
+
+	_, err := executor.Execute(t.Context(), CreateTestStep("tool", "Tool", "tool --version"), runtime)
+	require.NoError(t, err)
+
+	mockDocker.AssertExpectations(t)
+}
@@ -0,0 +1,142 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStepScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		shell    string
+		expected []string // Command, with {0} standing for the script's path in the container
+		ext      string
+		content  string
+		errMsg   string
+	}{
+		{
+			name:     "default",
+			expected: []string{"sh", "-c", defaultShellCmd, "{0}"},
+			ext:      ".sh",
+			content:  "echo hi",
+		},
+		{
+			name:     "bash",
+			shell:    "bash",
+			expected: []string{"bash", "--noprofile", "--norc", "-eo", "pipefail", "{0}"},
+			ext:      ".sh",
+			content:  "echo hi",
+		},
+		{
+			name:     "sh",
+			shell:    "sh",
+			expected: []string{"sh", "-e", "{0}"},
+			ext:      ".sh",
+			content:  "echo hi",
+		},
+		{
+			name:     "python",
+			shell:    "python",
+			expected: []string{"python", "{0}"},
+			ext:      ".py",
+			content:  "echo hi",
+		},
+		{
+			name:     "pwsh",
+			shell:    "pwsh",
+			expected: []string{"pwsh", "-command", ". '{0}'"},
+			ext:      ".ps1",
+			content:  "$ErrorActionPreference = 'stop'\necho hi\nif ((Test-Path -LiteralPath variable:\\LASTEXITCODE)) { exit $LASTEXITCODE }\n",
+		},
+		{
+			name:     "custom",
+			shell:    "perl -w {0}",
+			expected: []string{"perl", "-w", "{0}"},
+			ext:      ".sh",
+			content:  "echo hi",
+		},
+		{name: "windows only", shell: "cmd", errMsg: "shell cmd is only available on Windows runners"},
+		{name: "unknown", shell: "fish", errMsg: `unsupported shell "fish"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+
+			cmd, file, err := writeStepScript(tempDir, tt.shell, "echo hi")
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tempDir, filepath.Dir(file))
+			assert.Equal(t, tt.ext, filepath.Ext(file))
+			containerPath := path.Join("/github/env", filepath.Base(file))
+
+			var expected []string
+			for _, word := range tt.expected {
+				expected = append(expected, strings.ReplaceAll(word, "{0}", containerPath))
+			}
+			assert.Equal(t, expected, cmd)
+
+			content, err := os.ReadFile(file)
+			require.NoError(t, err)
+			assert.Equal(t, tt.content, string(content))
+		})
+	}
+}
+
+func TestWriteStepScript_NoTempDir(t *testing.T) {
+	_, _, err := writeStepScript("", "bash", "echo hi")
+	assert.ErrorContains(t, err, "no temp directory")
+}
+
+func TestStepWorkingDir(t *testing.T) {
+	assert.Equal(t, "/github/workspace", stepWorkingDir(""))
+	assert.Equal(t, "/github/workspace/frontend", stepWorkingDir("frontend"))
+	assert.Equal(t, "/github/workspace/frontend", stepWorkingDir("./frontend/"))
+	assert.Equal(t, "/srv/app", stepWorkingDir("/srv/app"))
+}
+
+func TestExecutor_Execute_RunDefaults(t *testing.T) {
+	wf := &Workflow{
+		Name:     "Defaults",
+		Defaults: Defaults{Run: RunDefaults{Shell: "bash", WorkingDirectory: "app"}},
+		Jobs: map[string]Job{
+			"inherit": {
+				RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+				Steps:  []Step{{ID: "run", Run: "true"}},
+			},
+			"override": {
+				RunsOn:   RunsOn{Labels: []string{"ubuntu-latest"}},
+				Defaults: Defaults{Run: RunDefaults{Shell: "sh"}},
+				Steps:    []Step{{ID: "run", Run: "true"}},
+			},
+		},
+	}
+
+	var (
+		mu       sync.Mutex
+		defaults = make(map[string]RunDefaults) // Job name -> defaults its steps see
+	)
+	executor := newStrategyExecutor(wf, stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		defaults[runtime.JobContext.Job.Name] = runtime.runDefaults()
+		return &ExecutionStepResult{Success: true}, nil
+	}))
+
+	require.NoError(t, executor.Execute(t.Context(), wf, executor.analyzer.ctx))
+
+	assert.Equal(t, RunDefaults{Shell: "bash", WorkingDirectory: "app"}, defaults["inherit"])
+	assert.Equal(t, RunDefaults{Shell: "sh", WorkingDirectory: "app"}, defaults["override"])
+}
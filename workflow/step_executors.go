@@ -1,10 +1,13 @@
 package workflow
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/telton/rehearse/internal/logger"
@@ -21,217 +24,113 @@ func (e *ShellStepExecutor) CanExecute(step *Step) bool {
 	return step.Run != ""
 }
 
-// Execute runs a shell command in a container.
+// Execute runs a shell command in the job container, starting it first if the
+// job has none yet, as for run steps of a composite action. Output is rendered
+// line by line while the command runs, with the workflow commands in it acted
+// on rather than printed.
 func (e *ShellStepExecutor) Execute(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
-	// Default to ubuntu-latest if no container specified
-	image := "ubuntu:latest"
-	if runtime.JobContext != nil && runtime.JobContext.Job.Container != nil {
-		image = runtime.JobContext.Job.Container.Image
+	if runtime.JobContainer == nil {
+		if err := e.startJobContainer(ctx, runtime); err != nil {
+			return nil, err
+		}
 	}
 
-	if e.renderer != nil {
-		e.renderer.RenderDockerPull(image)
-	}
-	if err := e.Docker.PullImage(ctx, image); err != nil {
-		return nil, fmt.Errorf("failed to pull image %s: %w", image, err)
+	defaults := runtime.runDefaults()
+	evaluator, err := runtime.evaluator(step)
+	if err != nil {
+		return nil, err
 	}
 
-	evaluatedCommand := e.evaluateExpressions(step.Run, runtime)
+	command, err := evaluator.Interpolate(step.Run)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating run: %w", err)
+	}
 
-	env := e.buildEnvironment(step, runtime)
+	workingDir, err := evaluator.Interpolate(cmp.Or(step.WorkingDirectory, defaults.WorkingDirectory))
+	if err != nil {
+		return nil, fmt.Errorf("evaluating working-directory: %w", err)
+	}
 
-	volumes := []VolumeMount{
-		{
-			Source: runtime.WorkingDir,
-			Target: "/github/workspace",
-			Type:   "bind",
-		},
+	env, err := e.buildEnvironment(step, runtime)
+	if err != nil {
+		return nil, err
 	}
 
 	if runtime.TempDir != "" {
-		envFile := runtime.TempDir + "/GITHUB_ENV"
-		outputFile := runtime.TempDir + "/GITHUB_OUTPUT"
-
-		if _, err := os.Stat(envFile); os.IsNotExist(err) {
-			if err := os.WriteFile(envFile, []byte{}, 0600); err != nil {
-				return nil, fmt.Errorf("failed to create GITHUB_ENV file: %w", err)
-			}
+		files, err := fileCommandEnv(runtime.TempDir)
+		if err != nil {
+			return nil, err
 		}
-		if _, err := os.Stat(outputFile); os.IsNotExist(err) {
-			if err := os.WriteFile(outputFile, []byte{}, 0600); err != nil {
-				return nil, fmt.Errorf("failed to create GITHUB_OUTPUT file: %w", err)
-			}
-		}
-
-		volumes = append(volumes,
-			VolumeMount{
-				Source: envFile,
-				Target: "/github/env/GITHUB_ENV",
-				Type:   "bind",
-			},
-			VolumeMount{
-				Source: outputFile,
-				Target: "/github/env/GITHUB_OUTPUT",
-				Type:   "bind",
-			},
-		)
-
-		env = append(env,
-			"GITHUB_ENV=/github/env/GITHUB_ENV",
-			"GITHUB_OUTPUT=/github/env/GITHUB_OUTPUT",
-		)
+		env = append(env, files...)
 	}
 
-	config := &ContainerConfig{
-		Image:      image,
-		Cmd:        []string{"sh", "-c", evaluatedCommand},
-		Env:        env,
-		WorkingDir: "/github/workspace",
-		Volumes:    volumes,
-	}
-
-	containerID, err := e.Docker.CreateContainer(ctx, config)
+	cmd, script, err := writeStepScript(runtime.TempDir, cmp.Or(step.Shell, defaults.Shell), command)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create container: %w", err)
+		return nil, err
 	}
+	defer os.Remove(script)
 
-	runtime.Containers[step.ID] = &ContainerInfo{
-		ID:     containerID,
-		Image:  image,
-		Status: "created",
-	}
-
-	defer func() {
-		if err := e.Docker.StopContainer(ctx, containerID); err != nil {
-			logger.Warn("Failed to stop container", "container_id", containerID, "error", err)
-		}
-		if err := e.Docker.RemoveContainer(ctx, containerID); err != nil {
-			logger.Warn("Failed to remove container", "container_id", containerID, "error", err)
-		}
-		delete(runtime.Containers, step.ID)
-	}()
-
-	if err := e.Docker.StartContainer(ctx, containerID); err != nil {
-		return nil, fmt.Errorf("failed to start container: %w", err)
+	execConfig := &ExecConfig{
+		Cmd:        prependPath(cmd, runtime.Path),
+		Env:        env,
+		WorkingDir: stepWorkingDir(workingDir),
 	}
 
-	runtime.Containers[step.ID].Status = "running"
+	commands := newCommandProcessor(runtime, step, e.renderer)
+	stdout, stderr := commands.writers()
+	execConfig.Stdout = stdout
+	execConfig.Stderr = stderr
 
-	var exitCode int
-	var containerError error
-
-	if dockerClient, ok := e.Docker.(*RealDockerClient); ok {
-		exitCode, containerError = dockerClient.WaitForContainer(ctx, containerID)
-
-		if logs, err := dockerClient.GetContainerLogs(ctx, containerID); err == nil && logs != "" {
-			cleanLogs := strings.TrimSpace(logs)
-			if cleanLogs != "" && e.renderer != nil {
-				e.renderer.RenderContainerOutput(logs)
-			}
-		}
-	} else {
-		exitCode = 0
+	execResult, err := e.Docker.ExecInContainer(ctx, runtime.JobContainer.ID, execConfig)
+	stdout.Flush()
+	stderr.Flush()
+	commands.endGroup(err != nil || execResult.ExitCode != 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run command in container: %w", err)
 	}
-
-	if containerError != nil {
-		return nil, fmt.Errorf("container execution failed: %w", containerError)
+	if commands.err != nil {
+		return nil, fmt.Errorf("processing workflow commands: %w", commands.err)
 	}
 
 	return &ExecutionStepResult{
-		Success:  exitCode == 0,
-		ExitCode: exitCode,
+		Success:  execResult.ExitCode == 0,
+		ExitCode: execResult.ExitCode,
+		Stdout:   execResult.Stdout,
+		Stderr:   execResult.Stderr,
 		Outputs:  make(map[string]string),
 	}, nil
 }
 
-// buildEnvironment creates environment variables for the step.
-func (e *ShellStepExecutor) buildEnvironment(step *Step, runtime *Runtime) []string {
-	var env []string
-
-	if runtime.JobContext != nil && runtime.JobContext.Job.Env != nil {
-		for k, v := range runtime.JobContext.Job.Env {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
-		}
-	}
-
-	for k, v := range runtime.DynamicEnv {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
-	}
-
-	if step.Env != nil {
-		for k, v := range step.Env {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
-		}
-	}
-
-	env = append(env,
-		"GITHUB_WORKSPACE=/github/workspace",
-		"GITHUB_ACTOR=rehearse",
-		"GITHUB_REPOSITORY=local/repo",
-		"RUNNER_OS=Linux",
-		"RUNNER_ARCH=X64",
-	)
-
-	return env
-}
-
-// evaluateExpressions evaluates GitHub Actions expressions in a string.
-func (e *ShellStepExecutor) evaluateExpressions(input string, runtime *Runtime) string {
-	result := input
-
-	for {
-		start := strings.Index(result, "${{")
-		if start == -1 {
-			break
-		}
-
-		end := strings.Index(result[start:], "}}")
-		if end == -1 {
-			break
-		}
-		end += start + 2
-
-		expression := result[start+3 : end-2]
-		expression = strings.TrimSpace(expression)
-
-		value := e.evaluateExpression(expression, runtime)
-
-		result = result[:start] + value + result[end:]
+// runDefaults returns the defaults.run settings of the runtime's job, which
+// already include those of its workflow.
+func (r *Runtime) runDefaults() RunDefaults {
+	if r.JobContext == nil || r.JobContext.Job == nil {
+		return RunDefaults{}
 	}
-
-	return result
+	return r.JobContext.Job.Defaults.Run
 }
 
-// evaluateExpression evaluates a single GitHub Actions expression.
-func (e *ShellStepExecutor) evaluateExpression(expression string, runtime *Runtime) string {
-	if strings.HasPrefix(expression, "steps.") && strings.Contains(expression, ".outputs.") {
-		parts := strings.Split(expression, ".")
-		if len(parts) >= 4 && parts[0] == "steps" && parts[2] == "outputs" {
-			stepID := parts[1]
-			outputName := parts[3]
-
-			if stepOutputs, exists := runtime.StepOutputs[stepID]; exists {
-				if value, exists := stepOutputs[outputName]; exists {
-					return value
-				}
-			}
-		}
+// buildEnvironment creates environment variables for the step, layered over
+// the env of the job's container.
+func (e *ShellStepExecutor) buildEnvironment(step *Step, runtime *Runtime) ([]string, error) {
+	var containerEnv map[string]string
+	if runtime.JobContext != nil && runtime.JobContext.Job != nil && runtime.JobContext.Job.Container != nil {
+		containerEnv = runtime.JobContext.Job.Container.Env
 	}
 
-	if strings.HasPrefix(expression, "env.") {
-		envVar := expression[4:]
-		if value, exists := runtime.DynamicEnv[envVar]; exists {
-			return value
-		}
+	env, err := runtime.stepEnv(step, containerEnv)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating env: %w", err)
 	}
 
-	return ""
+	return envList(env), nil
 }
 
 // ActionStepExecutor handles steps with 'uses' actions.
 type ActionStepExecutor struct {
-	Docker DockerClient
-	Git    ExecutorGitRepo
+	Docker   DockerClient
+	Git      ExecutorGitRepo
+	renderer *RunRenderer
 }
 
 // CanExecute returns true if this step uses an action.
@@ -275,31 +174,44 @@ func (e *ActionStepExecutor) executeDockerAction(ctx context.Context, step *Step
 		return nil, fmt.Errorf("failed to pull image %s: %w", image, err)
 	}
 
-	env := e.buildActionEnvironment(step, runtime)
+	env, err := e.buildActionEnvironment(step, runtime)
+	if err != nil {
+		return nil, err
+	}
 
 	config := &ContainerConfig{
 		Image:      image,
 		Env:        env,
 		WorkingDir: "/github/workspace",
-		Volumes: []VolumeMount{
+		Volumes: append([]VolumeMount{
 			{
 				Source: runtime.WorkingDir,
 				Target: "/github/workspace",
 				Type:   "bind",
 			},
-		},
+		}, runtime.fileCommandMounts()...),
+		Networks: runtime.networkNames(),
 	}
 
+	return e.runActionContainer(ctx, step, runtime, config)
+}
+
+// runActionContainer runs an action in a container of its own for step, waits
+// for it to exit and returns its result, with the output it logged. That
+// output is streamed, acting on the workflow commands in it, as a run step's.
+func (e *ActionStepExecutor) runActionContainer(ctx context.Context, step *Step, runtime *Runtime, config *ContainerConfig) (*ExecutionStepResult, error) {
 	containerID, err := e.Docker.CreateContainer(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create container: %w", err)
 	}
 
 	defer func() {
-		if err := e.Docker.StopContainer(ctx, containerID); err != nil {
+		// Clean up even when the step was cancelled.
+		cleanupCtx := context.WithoutCancel(ctx)
+		if err := e.Docker.StopContainer(cleanupCtx, containerID); err != nil {
 			logger.Warn("Failed to stop container", "container_id", containerID, "error", err)
 		}
-		if err := e.Docker.RemoveContainer(ctx, containerID); err != nil {
+		if err := e.Docker.RemoveContainer(cleanupCtx, containerID); err != nil {
 			logger.Warn("Failed to remove container", "container_id", containerID, "error", err)
 		}
 	}()
@@ -308,9 +220,30 @@ func (e *ActionStepExecutor) executeDockerAction(ctx context.Context, step *Step
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
 
+	commands := newCommandProcessor(runtime, step, e.renderer)
+	stdout, stderr := commands.writers()
+	logs, err := e.Docker.GetContainerLogs(ctx, containerID, stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil {
+		commands.endGroup(true)
+		return nil, fmt.Errorf("reading container logs: %w", err)
+	}
+
+	exitCode, err := e.Docker.WaitForContainer(ctx, containerID)
+	commands.endGroup(err != nil || exitCode != 0)
+	if err != nil {
+		return nil, err
+	}
+	if commands.err != nil {
+		return nil, fmt.Errorf("processing workflow commands: %w", commands.err)
+	}
+
 	return &ExecutionStepResult{
-		Success:  true,
-		ExitCode: 0,
+		Success:  exitCode == 0,
+		ExitCode: exitCode,
+		Stdout:   logs.Stdout,
+		Stderr:   logs.Stderr,
 		Outputs:  make(map[string]string),
 	}, nil
 }
@@ -354,7 +287,8 @@ func (e *ActionStepExecutor) executeActionWithMetadata(ctx context.Context, step
 	}
 }
 
-// executeDockerActionFromMetadata runs a Docker action using metadata.
+// executeDockerActionFromMetadata runs a Docker action using metadata, with
+// its pre-entrypoint and post-entrypoint hooks.
 func (e *ActionStepExecutor) executeDockerActionFromMetadata(ctx context.Context, step *Step, runtime *Runtime, metadata *ActionMetadata, actionPath string) (*ExecutionStepResult, error) {
 	image := metadata.Runs.Image
 
@@ -366,80 +300,184 @@ func (e *ActionStepExecutor) executeDockerActionFromMetadata(ctx context.Context
 		return nil, fmt.Errorf("failed to pull image %s: %w", image, err)
 	}
 
-	env := e.buildActionEnvironment(step, runtime)
+	// run runs the image with entrypoint in place of its own, if set.
+	run := func(ctx context.Context, runtime *Runtime, entrypoint string, state map[string]string) (*ExecutionStepResult, error) {
+		env, err := e.buildActionEnvironment(step, runtime)
+		if err != nil {
+			return nil, err
+		}
 
-	if step.With != nil {
-		for k, v := range step.With {
-			envName := fmt.Sprintf("INPUT_%s", strings.ToUpper(strings.ReplaceAll(k, "-", "_")))
-			env = append(env, fmt.Sprintf("%s=%s", envName, v))
+		inputs, err := inputEnv(step, runtime)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, inputs...)
+		env = append(env, stateEnv(state)...)
+
+		config := &ContainerConfig{
+			Image:      image,
+			Env:        env,
+			WorkingDir: "/github/workspace",
+			Volumes: append([]VolumeMount{
+				{
+					Source: runtime.WorkingDir,
+					Target: "/github/workspace",
+					Type:   "bind",
+				},
+			}, runtime.fileCommandMounts()...),
+			Networks: runtime.networkNames(),
+		}
+		if entrypoint != "" {
+			config.Entrypoint = []string{entrypoint}
 		}
-	}
 
-	config := &ContainerConfig{
-		Image:      image,
-		Env:        env,
-		WorkingDir: "/github/workspace",
-		Volumes: []VolumeMount{
-			{
-				Source: runtime.WorkingDir,
-				Target: "/github/workspace",
-				Type:   "bind",
-			},
-		},
+		return e.runActionContainer(ctx, step, runtime, config)
 	}
 
-	containerID, err := e.Docker.CreateContainer(ctx, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create container: %w", err)
+	if metadata.Runs.PostEntrypoint != "" {
+		registerPostHook(step, runtime, metadata.Runs.PostIf, func(ctx context.Context, runtime *Runtime, state map[string]string) (*ExecutionStepResult, error) {
+			return run(ctx, runtime, metadata.Runs.PostEntrypoint, state)
+		})
 	}
 
-	defer func() {
-		if err := e.Docker.StopContainer(ctx, containerID); err != nil {
-			logger.Warn("Failed to stop container", "container_id", containerID, "error", err)
+	var state map[string]string
+	if metadata.Runs.PreEntrypoint != "" {
+		var err error
+		state, err = e.runPreHook(step, runtime, metadata.Runs.PreIf, func() (*ExecutionStepResult, error) {
+			return run(ctx, runtime, metadata.Runs.PreEntrypoint, nil)
+		})
+		if err != nil {
+			return nil, err
 		}
-		if err := e.Docker.RemoveContainer(ctx, containerID); err != nil {
-			logger.Warn("Failed to remove container", "container_id", containerID, "error", err)
+	}
+
+	return run(ctx, runtime, "", state)
+}
+
+// executeNodeAction runs a Node.js-based action, with its pre and post hooks.
+func (e *ActionStepExecutor) executeNodeAction(ctx context.Context, step *Step, runtime *Runtime, metadata *ActionMetadata, actionPath string) (*ExecutionStepResult, error) {
+	mainFile := metadata.Runs.Main
+	if mainFile == "" {
+		mainFile = "index.js"
+	}
+
+	if metadata.Runs.Post != "" {
+		registerPostHook(step, runtime, metadata.Runs.PostIf, func(ctx context.Context, runtime *Runtime, state map[string]string) (*ExecutionStepResult, error) {
+			return e.runNodeScript(ctx, step, runtime, metadata, actionPath, metadata.Runs.Post, stateEnv(state))
+		})
+	}
+
+	var state map[string]string
+	if metadata.Runs.Pre != "" {
+		var err error
+		state, err = e.runPreHook(step, runtime, metadata.Runs.PreIf, func() (*ExecutionStepResult, error) {
+			return e.runNodeScript(ctx, step, runtime, metadata, actionPath, metadata.Runs.Pre, nil)
+		})
+		if err != nil {
+			return nil, err
 		}
-	}()
+	}
 
-	if err := e.Docker.StartContainer(ctx, containerID); err != nil {
-		return nil, fmt.Errorf("failed to start container: %w", err)
+	return e.runNodeScript(ctx, step, runtime, metadata, actionPath, mainFile, stateEnv(state))
+}
+
+// registerPostHook registers an action's post hook to run once the job's
+// steps are done, if condition, always() by default, holds then. It is
+// registered before the action's other entry points run so that the state
+// they save reaches it.
+func registerPostHook(step *Step, runtime *Runtime, condition string, run func(ctx context.Context, runtime *Runtime, state map[string]string) (*ExecutionStepResult, error)) {
+	runtime.PostSteps = append(runtime.PostSteps, PostStep{
+		Step: step,
+		If:   cmp.Or(condition, "always()"),
+		Run:  run,
+	})
+}
+
+// runPreHook runs an action's pre hook if condition, always() by default,
+// holds, and returns the state it saved through GITHUB_STATE, which its main
+// entry point and post hook receive. Unlike on GitHub, where pre hooks run
+// as the job starts, it runs just before the main entry point. A failing
+// hook fails the step.
+func (e *ActionStepExecutor) runPreHook(step *Step, runtime *Runtime, condition string, run func() (*ExecutionStepResult, error)) (map[string]string, error) {
+	evaluator, err := runtime.evaluator(step)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := evaluator.EvaluateCondition(cmp.Or(condition, "always()"))
+	if err != nil {
+		return nil, fmt.Errorf("evaluating pre-if: %w", err)
+	}
+	if !ok.Value.(bool) {
+		return nil, nil
 	}
 
-	return &ExecutionStepResult{
-		Success:  true,
-		ExitCode: 0,
-		Outputs:  make(map[string]string),
-	}, nil
+	result, err := run()
+	if err != nil {
+		return nil, fmt.Errorf("pre hook: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("pre hook failed with exit code %d", result.ExitCode)
+	}
+
+	if runtime.TempDir == "" {
+		return nil, nil
+	}
+	vars, err := readFileCommand(filepath.Join(runtime.TempDir, "GITHUB_STATE"))
+	if err != nil {
+		return nil, fmt.Errorf("GITHUB_STATE: %w", err)
+	}
+	runtime.saveState(step, vars)
+
+	state := make(map[string]string, len(vars))
+	for _, v := range vars {
+		state[v.Name] = v.Value
+	}
+	return state, nil
 }
 
-// executeNodeAction runs a Node.js-based action.
-func (e *ActionStepExecutor) executeNodeAction(ctx context.Context, step *Step, runtime *Runtime, metadata *ActionMetadata, actionPath string) (*ExecutionStepResult, error) {
+// saveState records the state an entry point of step's action saved through
+// GITHUB_STATE for the action's post hook.
+func (r *Runtime) saveState(step *Step, state []fileCommandVar) {
+	for i := range r.PostSteps {
+		post := &r.PostSteps[i]
+		if post.Step != step {
+			continue
+		}
+		for _, v := range state {
+			if post.State == nil {
+				post.State = make(map[string]string)
+			}
+			post.State[v.Name] = v.Value
+		}
+	}
+}
+
+// runNodeScript runs one of a Node.js action's entry points in a container of
+// its own, with extraEnv added to the step's environment.
+func (e *ActionStepExecutor) runNodeScript(ctx context.Context, step *Step, runtime *Runtime, metadata *ActionMetadata, actionPath, script string, extraEnv []string) (*ExecutionStepResult, error) {
 	nodeImage := "node:16"
 	if metadata.Runs.Using == "node20" {
 		nodeImage = "node:20"
 	}
 
-	env := e.buildActionEnvironment(step, runtime)
-
-	if step.With != nil {
-		for k, v := range step.With {
-			envName := fmt.Sprintf("INPUT_%s", strings.ToUpper(strings.ReplaceAll(k, "-", "_")))
-			env = append(env, fmt.Sprintf("%s=%s", envName, v))
-		}
+	env, err := e.buildActionEnvironment(step, runtime)
+	if err != nil {
+		return nil, err
 	}
 
-	mainFile := metadata.Runs.Main
-	if mainFile == "" {
-		mainFile = "index.js"
+	inputs, err := inputEnv(step, runtime)
+	if err != nil {
+		return nil, err
 	}
+	env = append(env, inputs...)
+	env = append(env, extraEnv...)
 
 	config := &ContainerConfig{
 		Image:      nodeImage,
-		Cmd:        []string{"node", mainFile},
+		Cmd:        []string{"node", script},
 		Env:        env,
 		WorkingDir: "/action",
-		Volumes: []VolumeMount{
+		Volumes: append([]VolumeMount{
 			{
 				Source: runtime.WorkingDir,
 				Target: "/github/workspace",
@@ -450,42 +488,33 @@ func (e *ActionStepExecutor) executeNodeAction(ctx context.Context, step *Step,
 				Target: "/action",
 				Type:   "bind",
 			},
-		},
-	}
-
-	containerID, err := e.Docker.CreateContainer(ctx, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create container: %w", err)
-	}
-
-	defer func() {
-		if err := e.Docker.StopContainer(ctx, containerID); err != nil {
-			logger.Warn("Failed to stop container", "container_id", containerID, "error", err)
-		}
-		if err := e.Docker.RemoveContainer(ctx, containerID); err != nil {
-			logger.Warn("Failed to remove container", "container_id", containerID, "error", err)
-		}
-	}()
-
-	if err := e.Docker.StartContainer(ctx, containerID); err != nil {
-		return nil, fmt.Errorf("failed to start container: %w", err)
+		}, runtime.fileCommandMounts()...),
+		Networks: runtime.networkNames(),
 	}
 
-	return &ExecutionStepResult{
-		Success:  true,
-		ExitCode: 0,
-		Outputs:  make(map[string]string),
-	}, nil
+	return e.runActionContainer(ctx, step, runtime, config)
 }
 
 // executeCompositeAction runs a composite action (action with multiple steps).
 func (e *ActionStepExecutor) executeCompositeAction(ctx context.Context, step *Step, runtime *Runtime, metadata *ActionMetadata, actionPath string) (*ExecutionStepResult, error) {
 	for _, compositeStep := range metadata.Runs.Steps {
 		if compositeStep.Run != "" {
-			shellExecutor := &ShellStepExecutor{Docker: e.Docker}
-			if _, err := shellExecutor.Execute(ctx, &compositeStep, runtime); err != nil {
+			shellExecutor := &ShellStepExecutor{Docker: e.Docker, renderer: e.renderer}
+			result, err := shellExecutor.Execute(ctx, &compositeStep, runtime)
+			if err != nil {
 				return nil, fmt.Errorf("composite step failed: %w", err)
 			}
+
+			// A failed step fails the action, and the steps after it do not run.
+			if !result.Success {
+				return &ExecutionStepResult{
+					Success:  false,
+					ExitCode: result.ExitCode,
+					Stdout:   result.Stdout,
+					Stderr:   result.Stderr,
+					Outputs:  make(map[string]string),
+				}, nil
+			}
 		}
 	}
 
@@ -496,29 +525,48 @@ func (e *ActionStepExecutor) executeCompositeAction(ctx context.Context, step *S
 	}, nil
 }
 
-// buildActionEnvironment creates environment variables for actions.
-func (e *ActionStepExecutor) buildActionEnvironment(step *Step, runtime *Runtime) []string {
-	var env []string
+// buildActionEnvironment creates environment variables for actions, including
+// those of the file commands the action's container mounts.
+func (e *ActionStepExecutor) buildActionEnvironment(step *Step, runtime *Runtime) ([]string, error) {
+	env, err := runtime.stepEnv(step, nil)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating env: %w", err)
+	}
 
-	if runtime.JobContext != nil && runtime.JobContext.Job.Env != nil {
-		for k, v := range runtime.JobContext.Job.Env {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
+	list := envList(env)
+	if runtime.TempDir != "" {
+		files, err := fileCommandEnv(runtime.TempDir)
+		if err != nil {
+			return nil, err
 		}
+		list = append(list, files...)
 	}
 
-	if step.Env != nil {
-		for k, v := range step.Env {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
-		}
+	return list, nil
+}
+
+// inputEnv returns the INPUT_ variables that pass the step's with: inputs to
+// an action, with their expressions evaluated.
+func inputEnv(step *Step, runtime *Runtime) ([]string, error) {
+	if len(step.With) == 0 {
+		return nil, nil
 	}
 
-	env = append(env,
-		"GITHUB_WORKSPACE=/github/workspace",
-		"GITHUB_ACTOR=rehearse",
-		"GITHUB_REPOSITORY=local/repo",
-		"RUNNER_OS=Linux",
-		"RUNNER_ARCH=X64",
-	)
+	evaluator, err := runtime.evaluator(step)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0, len(step.With))
+	for _, k := range slices.Sorted(maps.Keys(step.With)) {
+		value, err := evaluator.Interpolate(step.With[k])
+		if err != nil {
+			return nil, fmt.Errorf("evaluating with.%s: %w", k, err)
+		}
+
+		envName := fmt.Sprintf("INPUT_%s", strings.ToUpper(strings.ReplaceAll(k, "-", "_")))
+		env = append(env, fmt.Sprintf("%s=%s", envName, value))
+	}
 
-	return env
+	return env, nil
 }
@@ -0,0 +1,165 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingStepExecutor runs every step in-process, recording how many steps
+// run concurrently and failing the steps of matrix instances listed in failOn.
+type recordingStepExecutor struct {
+	delay  time.Duration
+	failOn map[string]bool
+
+	mu      sync.Mutex
+	ran     []string
+	running int32
+	peak    int32
+}
+
+func (r *recordingStepExecutor) CanExecute(step *Step) bool {
+	return true
+}
+
+func (r *recordingStepExecutor) Execute(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+	current := atomic.AddInt32(&r.running, 1)
+	defer atomic.AddInt32(&r.running, -1)
+
+	for {
+		peak := atomic.LoadInt32(&r.peak)
+		if current <= peak || atomic.CompareAndSwapInt32(&r.peak, peak, current) {
+			break
+		}
+	}
+
+	leg := toString(runtime.JobContext.Matrix["leg"])
+
+	r.mu.Lock()
+	r.ran = append(r.ran, leg)
+	r.mu.Unlock()
+
+	select {
+	case <-time.After(r.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if r.failOn[leg] {
+		return &ExecutionStepResult{Success: false, ExitCode: 1}, nil
+	}
+
+	return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+}
+
+func newMatrixWorkflow(legs int, strategy Strategy) *Workflow {
+	values := make([]any, 0, legs)
+	for i := range legs {
+		values = append(values, fmt.Sprintf("leg-%d", i))
+	}
+
+	strategy.Matrix = &Matrix{
+		Dimensions: []MatrixDimension{{Key: "leg", Values: values}},
+	}
+
+	return &Workflow{
+		Name: "Strategy",
+		Jobs: map[string]Job{
+			"test": {
+				RunsOn:   RunsOn{Labels: []string{"ubuntu-latest"}},
+				Strategy: &strategy,
+				Steps:    []Step{{ID: "run", Name: "Run", Run: "true"}},
+			},
+		},
+	}
+}
+
+func newStrategyExecutor(wf *Workflow, stepExecutor StepExecutor) *Executor {
+	ctx := &Context{
+		Jobs:   make(map[string]JobContext),
+		Matrix: make(map[string]any),
+	}
+
+	executor := NewExecutor(NewAnalyzer(wf, ctx), NewMockDockerClient(), NewMockGitRepo())
+	executor.executors = []StepExecutor{stepExecutor}
+
+	return executor
+}
+
+func TestExecutor_Execute_MaxParallel(t *testing.T) {
+	wf := newMatrixWorkflow(6, Strategy{MaxParallel: 2})
+	recorder := &recordingStepExecutor{delay: 20 * time.Millisecond}
+
+	executor := newStrategyExecutor(wf, recorder)
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	require.NoError(t, err)
+	assert.Len(t, recorder.ran, 6)
+	assert.Equal(t, int32(2), recorder.peak)
+}
+
+func TestExecutor_Execute_FailFast(t *testing.T) {
+	failFast := true
+	wf := newMatrixWorkflow(4, Strategy{FailFast: &failFast, MaxParallel: 1})
+	recorder := &recordingStepExecutor{failOn: map[string]bool{"leg-0": true}}
+
+	executor := newStrategyExecutor(wf, recorder)
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	assert.ErrorContains(t, err, "job test (leg-0) failed")
+	assert.Equal(t, []string{"leg-0"}, recorder.ran)
+}
+
+func TestExecutor_Execute_FailFastCancelsRunningSiblings(t *testing.T) {
+	wf := newMatrixWorkflow(3, Strategy{})
+	recorder := &recordingStepExecutor{
+		delay:  time.Second,
+		failOn: map[string]bool{"leg-1": true},
+	}
+
+	// Fail one leg quickly while its siblings are still running.
+	fast := &recordingStepExecutor{failOn: recorder.failOn}
+	executor := newStrategyExecutor(wf, stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		if runtime.JobContext.Matrix["leg"] == "leg-1" {
+			return fast.Execute(ctx, step, runtime)
+		}
+		return recorder.Execute(ctx, step, runtime)
+	}))
+
+	start := time.Now()
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	assert.ErrorContains(t, err, "job test (leg-1) failed")
+	assert.NotContains(t, err.Error(), "leg-0")
+	assert.NotContains(t, err.Error(), "leg-2")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestExecutor_Execute_NoFailFast(t *testing.T) {
+	failFast := false
+	wf := newMatrixWorkflow(3, Strategy{FailFast: &failFast, MaxParallel: 1})
+	recorder := &recordingStepExecutor{failOn: map[string]bool{"leg-0": true}}
+
+	executor := newStrategyExecutor(wf, recorder)
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	assert.ErrorContains(t, err, "job test (leg-0) failed")
+	assert.Equal(t, []string{"leg-0", "leg-1", "leg-2"}, recorder.ran)
+}
+
+// stepExecutorFunc adapts a function to the StepExecutor interface.
+type stepExecutorFunc func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error)
+
+func (f stepExecutorFunc) CanExecute(step *Step) bool {
+	return true
+}
+
+func (f stepExecutorFunc) Execute(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+	return f(ctx, step, runtime)
+}
@@ -0,0 +1,75 @@
+package workflow
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/telton/rehearse/internal/mask"
+)
+
+// jobSummaries collects the summaries jobs write through GITHUB_STEP_SUMMARY,
+// in the order the jobs finish.
+type jobSummaries struct {
+	mu      sync.Mutex
+	entries []jobSummary
+}
+
+// jobSummary is the markdown the steps of one job wrote, joined in order.
+type jobSummary struct {
+	job      string
+	markdown string
+}
+
+// add records the summary of job.
+func (s *jobSummaries) add(job, markdown string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, jobSummary{job: job, markdown: markdown})
+}
+
+// markdown returns the summaries as one document, with a heading per job.
+func (s *jobSummaries) markdown() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sections := make([]string, 0, len(s.entries))
+	for _, entry := range s.entries {
+		sections = append(sections, "## "+entry.job+"\n\n"+strings.TrimRight(entry.markdown, "\n")+"\n")
+	}
+	return strings.Join(sections, "\n")
+}
+
+// SetSummaryFile sets the file the job summaries of a run are saved to once
+// it ends. Without one, summaries are only shown as each job finishes.
+func (e *Executor) SetSummaryFile(path string) {
+	e.summaryFile = path
+}
+
+// recordJobSummary shows the markdown the steps of job wrote to
+// GITHUB_STEP_SUMMARY, if any, and keeps it for the summary file.
+func (e *Executor) recordJobSummary(job string) {
+	if len(e.runtime.Summaries) == 0 {
+		return
+	}
+
+	markdown := strings.Join(e.runtime.Summaries, "\n")
+	e.renderer.RenderJobSummary(job, markdown)
+	e.summaries.add(job, markdown)
+}
+
+// writeSummaryFile saves the job summaries of the run to the summary file,
+// if one is set.
+func (e *Executor) writeSummaryFile() error {
+	if e.summaryFile == "" {
+		return nil
+	}
+
+	// The file is meant to be shared, like the terminal output.
+	markdown := mask.String(e.summaries.markdown())
+	if err := os.WriteFile(e.summaryFile, []byte(markdown), 0o644); err != nil {
+		return fmt.Errorf("writing summary file: %w", err)
+	}
+	return nil
+}
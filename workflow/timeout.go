@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// defaultJobTimeoutMinutes matches the timeout GitHub applies to jobs that
+// do not set timeout-minutes.
+const defaultJobTimeoutMinutes = 360
+
+// TimeoutError is the cancellation cause of a job or step that ran for longer
+// than its timeout-minutes.
+type TimeoutError struct {
+	Minutes float64
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s minutes", strconv.FormatFloat(e.Minutes, 'f', -1, 64))
+}
+
+// timeoutMinutes evaluates a timeout-minutes value, which may be a number or
+// an expression. It returns 0 when the value is empty.
+func timeoutMinutes(value string, ctx *Context) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	result, err := NewEvaluator(ctx).Evaluate(value)
+	if err != nil {
+		return 0, fmt.Errorf("evaluating timeout-minutes: %w", err)
+	}
+
+	minutes, err := strconv.ParseFloat(toString(result.Value), 64)
+	if err != nil || minutes <= 0 {
+		return 0, fmt.Errorf("timeout-minutes must be a positive number, got %s", formatValue(result.Value))
+	}
+
+	return minutes, nil
+}
+
+// withTimeout returns a context that is cancelled with a TimeoutError once the
+// given number of minutes has passed. Zero minutes means no timeout.
+func withTimeout(ctx context.Context, minutes float64) (context.Context, context.CancelFunc) {
+	if minutes == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	d := time.Duration(minutes * float64(time.Minute))
+	return context.WithTimeoutCause(ctx, d, &TimeoutError{Minutes: minutes})
+}
@@ -0,0 +1,117 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutMinutes(t *testing.T) {
+	ctx := &Context{Matrix: map[string]any{"timeout": 15}}
+
+	tests := []struct {
+		name     string
+		value    string
+		expected float64
+		err      string
+	}{
+		{name: "unset", value: "", expected: 0},
+		{name: "number", value: "10", expected: 10},
+		{name: "fraction", value: "0.5", expected: 0.5},
+		{name: "expression", value: "${{ matrix.timeout }}", expected: 15},
+		{name: "not a number", value: "soon", err: "timeout-minutes must be a positive number"},
+		{name: "negative", value: "-1", err: "timeout-minutes must be a positive number"},
+		{name: "zero", value: "0", err: "timeout-minutes must be a positive number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minutes, err := timeoutMinutes(tt.value, ctx)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, minutes)
+		})
+	}
+}
+
+func TestJob_TimeoutMinutes_Unmarshal(t *testing.T) {
+	var job Job
+	require.NoError(t, yaml.Unmarshal([]byte(`
+timeout-minutes: 30
+steps:
+  - run: sleep 100
+    timeout-minutes: ${{ matrix.timeout }}
+`), &job))
+
+	assert.Equal(t, "30", job.TimeoutMinutes)
+	assert.Equal(t, "${{ matrix.timeout }}", job.Steps[0].TimeoutMinutes)
+}
+
+// hangingStepExecutor blocks steps running "hang" until they are cancelled.
+var hangingStepExecutor = stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+	if step.Run == "hang" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &ExecutionStepResult{Success: true, Outputs: make(map[string]string)}, nil
+})
+
+func TestExecutor_executeJob_StepTimeout(t *testing.T) {
+	executor := newTestExecutor(t, NewMockDockerClient(), NewMockGitRepo())
+	executor.executors = []StepExecutor{hangingStepExecutor}
+
+	job := &Job{
+		Name:   "test",
+		RunsOn: RunsOn{Labels: []string{"ubuntu-latest"}},
+		Steps: []Step{
+			{ID: "hung", Name: "Hung", Run: "hang", TimeoutMinutes: "0.001"},
+			{ID: "cleanup", Name: "Cleanup", If: "failure() && steps.hung.outcome == 'cancelled'", Run: "true"},
+		},
+	}
+
+	start := time.Now()
+	err := executor.executeJob(t.Context(), job, &Context{})
+
+	var timeout *TimeoutError
+	assert.ErrorAs(t, err, &timeout)
+	assert.ErrorContains(t, err, "step Hung failed: timed out after 0.001 minutes")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, "failure", executor.runtime.JobContext.Status)
+	assert.Equal(t, "success", executor.runtime.StepContext.Outcome, "cleanup step should run")
+}
+
+func TestExecutor_Execute_JobTimeout(t *testing.T) {
+	wf := &Workflow{
+		Name: "Timeout",
+		Jobs: map[string]Job{
+			"test": {
+				RunsOn:         RunsOn{Labels: []string{"ubuntu-latest"}},
+				TimeoutMinutes: "0.001",
+				Steps: []Step{
+					{Name: "Hung", Run: "hang"},
+					{Name: "Skipped", Run: "true"},
+					{Name: "Always", If: "always()", Run: "true"},
+				},
+			},
+		},
+	}
+
+	var ran []string
+	executor := newStrategyExecutor(wf, stepExecutorFunc(func(ctx context.Context, step *Step, runtime *Runtime) (*ExecutionStepResult, error) {
+		ran = append(ran, step.Name)
+		return hangingStepExecutor(ctx, step, runtime)
+	}))
+
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	assert.ErrorContains(t, err, "job test failed: timed out after 0.001 minutes")
+	assert.Equal(t, []string{"Hung", "Always"}, ran)
+}
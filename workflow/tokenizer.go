@@ -18,6 +18,8 @@ const (
 	TokenRParen
 	TokenComma
 	TokenDot
+	TokenLBracket
+	TokenRBracket
 	TokenEq
 	TokenNeq
 	TokenAnd
@@ -101,6 +103,21 @@ func tokenize(expr string) ([]Token, error) {
 			i++
 			continue
 
+		case '.':
+			tokens = append(tokens, Token{TokenDot, "."})
+			i++
+			continue
+
+		case '[':
+			tokens = append(tokens, Token{TokenLBracket, "["})
+			i++
+			continue
+
+		case ']':
+			tokens = append(tokens, Token{TokenRBracket, "]"})
+			i++
+			continue
+
 		case '!':
 			tokens = append(tokens, Token{TokenNot, "!"})
 			i++
@@ -151,11 +168,13 @@ func tokenize(expr string) ([]Token, error) {
 			continue
 		}
 
-		// Handle identifiers (including dot-separated paths).
-		if unicode.IsLetter(r[i]) || r[i] == '_' {
+		// Handle identifiers (including dot-separated paths, hyphenated names
+		// such as steps.set-version and * filters such as labels.*.name).
+		afterDot := len(tokens) > 0 && tokens[len(tokens)-1].Type == TokenDot
+		if unicode.IsLetter(r[i]) || r[i] == '_' || (r[i] == '*' && afterDot) {
 			start := i
 
-			for i < len(r) && (unicode.IsLetter(r[i]) || unicode.IsDigit(r[i]) || r[i] == '_' || r[i] == '.') {
+			for i < len(r) && (unicode.IsLetter(r[i]) || unicode.IsDigit(r[i]) || r[i] == '_' || r[i] == '.' || (r[i] == '-' && i > start) || (r[i] == '*' && (i == start || r[i-1] == '.'))) {
 				i++
 			}
 
@@ -223,6 +242,24 @@ type FunctionCallNode struct {
 
 func (FunctionCallNode) node() {}
 
+// PropertyAccessNode looks up a path in the value of another expression, as
+// in fromJSON(needs.setup.outputs.matrix).os.
+type PropertyAccessNode struct {
+	Object Node
+	Path   string
+}
+
+func (PropertyAccessNode) node() {}
+
+// IndexNode looks up a property or array element of the value of another
+// expression, as in secrets['NPM_TOKEN'] or fromJSON(list)[0].
+type IndexNode struct {
+	Object Node
+	Index  Node
+}
+
+func (IndexNode) node() {}
+
 // Parser.
 type parser struct {
 	tokens []Token
@@ -357,10 +394,14 @@ func (p *parser) parsePrimary() (Node, error) {
 		p.advance()
 		// Check if it's a function call.
 		if p.current().Type == TokenLParen {
-			return p.parseFunctionCall(t.Value)
+			node, err := p.parseFunctionCall(t.Value)
+			if err != nil {
+				return nil, err
+			}
+			return p.parsePostfix(node)
 		}
 		// Otherwise, it's a context access.
-		return &ContextAccessNode{Path: t.Value}, nil
+		return p.parsePostfix(&ContextAccessNode{Path: t.Value})
 
 	case TokenLParen:
 		p.advance()
@@ -372,7 +413,7 @@ func (p *parser) parsePrimary() (Node, error) {
 			return nil, fmt.Errorf("expected closing paren")
 		}
 		p.advance()
-		return node, nil
+		return p.parsePostfix(node)
 	}
 
 	return nil, fmt.Errorf("unexpected token: %v", t)
@@ -398,3 +439,38 @@ func (p *parser) parseFunctionCall(name string) (Node, error) {
 
 	return &FunctionCallNode{Name: name, Args: args}, nil
 }
+
+// parsePostfix parses the property accesses and indexes, if any, applied to
+// node.
+func (p *parser) parsePostfix(node Node) (Node, error) {
+	for {
+		switch p.current().Type {
+		case TokenDot:
+			p.advance()
+
+			t := p.advance()
+			if t.Type != TokenIdent {
+				return nil, fmt.Errorf("expected property name after '.'")
+			}
+
+			node = &PropertyAccessNode{Object: node, Path: t.Value}
+
+		case TokenLBracket:
+			p.advance()
+
+			index, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			if p.current().Type != TokenRBracket {
+				return nil, fmt.Errorf("expected closing bracket")
+			}
+			p.advance()
+
+			node = &IndexNode{Object: node, Index: index}
+
+		default:
+			return node, nil
+		}
+	}
+}
@@ -1,26 +1,112 @@
 package workflow
 
-import "strings"
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+)
 
 // Workflow represents a GitHub Actions workflow file.
 type Workflow struct {
-	Name string            `yaml:"name"`
-	On   any               `yaml:"on"` // Can be []string or map
-	Env  map[string]string `yaml:"env"`
-	Jobs map[string]Job    `yaml:"jobs"`
+	Name     string            `yaml:"name"`
+	On       any               `yaml:"on"` // Can be []string or map
+	Env      map[string]string `yaml:"env"`
+	Defaults Defaults          `yaml:"defaults"`
+	Jobs     map[string]Job    `yaml:"jobs"`
+
+	source   *ast.File // Syntax tree of the parsed file, used for line numbers
+	jobOrder []string  // Job IDs in declaration order
+}
+
+func (w *Workflow) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain Workflow
+	if err := unmarshal((*plain)(w)); err != nil {
+		return err
+	}
+
+	// Decode the jobs again as a MapSlice to learn their declaration order.
+	var ordered struct {
+		Jobs yaml.MapSlice `yaml:"jobs"`
+	}
+	if err := unmarshal(&ordered); err != nil {
+		return err
+	}
+
+	w.jobOrder = make([]string, 0, len(ordered.Jobs))
+	for _, entry := range ordered.Jobs {
+		w.jobOrder = append(w.jobOrder, fmt.Sprint(entry.Key))
+	}
+
+	return nil
+}
+
+// JobIDs returns the IDs of the workflow's jobs in declaration order. Jobs
+// whose position is unknown, such as ones added after parsing, come last in
+// lexical order.
+func (w *Workflow) JobIDs() []string {
+	ids := make([]string, 0, len(w.Jobs))
+	seen := make(map[string]bool, len(w.Jobs))
+	for _, id := range w.jobOrder {
+		if _, exists := w.Jobs[id]; exists && !seen[id] {
+			ids = append(ids, id)
+			seen[id] = true
+		}
+	}
+
+	var rest []string
+	for id := range w.Jobs {
+		if !seen[id] {
+			rest = append(rest, id)
+		}
+	}
+	slices.Sort(rest)
+
+	return append(ids, rest...)
 }
 
 // Job represents a single job in a workflow.
 type Job struct {
-	Name      string            `yaml:"name"`
-	RunsOn    RunsOn            `yaml:"runs-on"`
-	Needs     Needs             `yaml:"needs"`
-	If        string            `yaml:"if"`
-	Env       map[string]string `yaml:"env"`
-	Steps     []Step            `yaml:"steps"`
-	Strategy  *Strategy         `yaml:"strategy"`
-	Outputs   map[string]string `yaml:"outputs"`
-	Container *Container        `yaml:"container"`
+	Name      string                `yaml:"name"`
+	RunsOn    RunsOn                `yaml:"runs-on"`
+	Needs     Needs                 `yaml:"needs"`
+	If        string                `yaml:"if"`
+	Env       map[string]string     `yaml:"env"`
+	Steps     []Step                `yaml:"steps"`
+	Strategy  *Strategy             `yaml:"strategy"`
+	Outputs   map[string]string     `yaml:"outputs"`
+	Container *Container            `yaml:"container"`
+	Services  map[string]*Container `yaml:"services"` // Service ID -> service container
+	Defaults  Defaults              `yaml:"defaults"`
+
+	Uses    string      `yaml:"uses"`    // Reusable workflow the job calls
+	Secrets *JobSecrets `yaml:"secrets"` // Secrets passed to the reusable workflow
+
+	ContinueOnError string `yaml:"continue-on-error"` // Boolean or expression
+	TimeoutMinutes  string `yaml:"timeout-minutes"`   // Number or expression, 360 when unset
+}
+
+// JobSecrets are the secrets a job passes to the reusable workflow it calls:
+// either named ones, or all of the caller's with inherit.
+type JobSecrets struct {
+	Inherit bool
+	Values  map[string]string // Secret name in the called workflow -> expression
+}
+
+func (s *JobSecrets) UnmarshalYAML(unmarshal func(any) error) error {
+	var keyword string
+	if err := unmarshal(&keyword); err == nil {
+		if keyword != "inherit" {
+			return fmt.Errorf("invalid secrets %q: expected inherit or a map of secrets", keyword)
+		}
+		s.Inherit = true
+		return nil
+	}
+
+	return unmarshal(&s.Values)
 }
 
 // Step represents a single step in a job.
@@ -32,19 +118,68 @@ type Step struct {
 	Uses string            `yaml:"uses"`
 	With map[string]string `yaml:"with"`
 	Env  map[string]string `yaml:"env"`
+
+	Shell            string `yaml:"shell"`
+	WorkingDirectory string `yaml:"working-directory"`
+
+	ContinueOnError string `yaml:"continue-on-error"` // Boolean or expression
+	TimeoutMinutes  string `yaml:"timeout-minutes"`   // Number or expression
+}
+
+// Defaults holds the defaults: of a workflow or job.
+type Defaults struct {
+	Run RunDefaults `yaml:"run"`
+}
+
+// RunDefaults holds the settings run steps use when they do not set their own.
+type RunDefaults struct {
+	Shell            string `yaml:"shell"`
+	WorkingDirectory string `yaml:"working-directory"`
+}
+
+// withFallback returns d with the settings it leaves unset taken from
+// fallback, as job defaults take precedence over workflow defaults.
+func (d RunDefaults) withFallback(fallback RunDefaults) RunDefaults {
+	return RunDefaults{
+		Shell:            cmp.Or(d.Shell, fallback.Shell),
+		WorkingDirectory: cmp.Or(d.WorkingDirectory, fallback.WorkingDirectory),
+	}
 }
 
 // Strategy represents a matrix strategy.
 type Strategy struct {
-	Matrix      map[string]any `yaml:"strategy"`
-	FailFast    *bool          `yaml:"fail-fast"`
-	MaxParallel int            `yaml:"max-parallel"`
+	Matrix      *Matrix `yaml:"matrix"`
+	FailFast    *bool   `yaml:"fail-fast"`
+	MaxParallel int     `yaml:"max-parallel"`
 }
 
-// Container represents a container configuration.
+// Container represents a container configuration, used both for a job's
+// container and for its service containers.
 type Container struct {
-	Image string            `yaml:"image"`
-	Env   map[string]string `yaml:"env"`
+	Image       string            `yaml:"image"`
+	Env         map[string]string `yaml:"env"`
+	Ports       []string          `yaml:"ports"`   // As in docker run --publish
+	Volumes     []string          `yaml:"volumes"` // As in docker run --volume
+	Options     string            `yaml:"options"` // Extra docker create options
+	Credentials *Credentials      `yaml:"credentials"`
+}
+
+// UnmarshalYAML accepts the shorthand of a container given as just its image.
+func (c *Container) UnmarshalYAML(unmarshal func(any) error) error {
+	var image string
+	if err := unmarshal(&image); err == nil {
+		c.Image = image
+		return nil
+	}
+
+	type plain Container
+	return unmarshal((*plain)(c))
+}
+
+// Credentials authenticate with the registry a container image is pulled from.
+type Credentials struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
 }
 
 // RunsOn handles both string and array formats.
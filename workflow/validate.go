@@ -0,0 +1,100 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MissingDependencyError reports a job that needs a job the workflow does not define.
+type MissingDependencyError struct {
+	Job        string
+	Dependency string
+	Line       int // Line of the needs entry, 0 when unknown
+}
+
+func (e *MissingDependencyError) Error() string {
+	return withLine(e.Line, fmt.Sprintf("job '%s' depends on unknown job '%s'", e.Job, e.Dependency))
+}
+
+// DependencyCycleError reports jobs that transitively need themselves.
+type DependencyCycleError struct {
+	Cycle []string // Job IDs along the cycle, starting and ending with the same job
+	Line  int      // Line of the needs entry that closes the cycle, 0 when unknown
+}
+
+func (e *DependencyCycleError) Error() string {
+	return withLine(e.Line, "job dependency cycle: "+strings.Join(e.Cycle, " -> "))
+}
+
+func withLine(line int, msg string) string {
+	if line == 0 {
+		return msg
+	}
+	return fmt.Sprintf("line %d: %s", line, msg)
+}
+
+// Validate checks that the job dependency graph can be scheduled: every need
+// names a job in the workflow and no job transitively needs itself. All
+// problems are returned joined together.
+func (w *Workflow) Validate() error {
+	names := w.JobIDs()
+
+	var errs []error
+	for _, name := range names {
+		for _, dep := range w.Jobs[name].Needs.Jobs {
+			if _, exists := w.Jobs[dep]; !exists {
+				errs = append(errs, &MissingDependencyError{
+					Job:        name,
+					Dependency: dep,
+					Line:       w.needsLine(name, dep),
+				})
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+
+		for _, dep := range w.Jobs[name].Needs.Jobs {
+			if _, exists := w.Jobs[dep]; !exists {
+				continue
+			}
+
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := slices.Index(path, dep)
+				cycle := append(slices.Clone(path[start:]), dep)
+				errs = append(errs, &DependencyCycleError{
+					Cycle: cycle,
+					Line:  w.needsLine(name, dep),
+				})
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
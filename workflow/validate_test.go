@@ -0,0 +1,98 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflow_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		needs    map[string][]string
+		expected []string
+	}{
+		{
+			name:  "valid graph",
+			needs: map[string][]string{"build": nil, "test": {"build"}, "deploy": {"build", "test"}},
+		},
+		{
+			name:     "missing dependency",
+			needs:    map[string][]string{"build": nil, "test": {"biuld"}},
+			expected: []string{"job 'test' depends on unknown job 'biuld'"},
+		},
+		{
+			name:     "self dependency",
+			needs:    map[string][]string{"build": {"build"}},
+			expected: []string{"job dependency cycle: build -> build"},
+		},
+		{
+			name:     "cycle",
+			needs:    map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			expected: []string{"job dependency cycle: a -> b -> c -> a"},
+		},
+		{
+			name:  "missing dependency and cycle",
+			needs: map[string][]string{"a": {"b", "x"}, "b": {"a"}},
+			expected: []string{
+				"job 'a' depends on unknown job 'x'",
+				"job dependency cycle: a -> b -> a",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := &Workflow{Jobs: make(map[string]Job)}
+			for name, needs := range tt.needs {
+				wf.Jobs[name] = Job{Needs: Needs{Jobs: needs}}
+			}
+
+			err := wf.Validate()
+			if len(tt.expected) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, expected := range tt.expected {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+}
+
+func TestWorkflow_Validate_LineNumbers(t *testing.T) {
+	wf, err := Parse("../testdata/errors/cyclic-dependency.yaml")
+	require.NoError(t, err)
+
+	var cycle *DependencyCycleError
+	require.True(t, errors.As(wf.Validate(), &cycle))
+	assert.Equal(t, []string{"build", "deploy", "test", "build"}, cycle.Cycle)
+	assert.Equal(t, 13, cycle.Line)
+	assert.EqualError(t, cycle, "line 13: job dependency cycle: build -> deploy -> test -> build")
+
+	wf, err = Parse("../testdata/errors/invalid-workflow.yaml")
+	require.NoError(t, err)
+
+	var missing *MissingDependencyError
+	require.True(t, errors.As(wf.Validate(), &missing))
+	assert.Equal(t, "missing-dependency", missing.Job)
+	assert.Equal(t, "nonexistent-job", missing.Dependency)
+	assert.Equal(t, 13, missing.Line)
+}
+
+func TestExecutor_Execute_InvalidWorkflow(t *testing.T) {
+	wf := newDAGWorkflow()
+	wf.Jobs["lint"] = Job{Needs: Needs{Jobs: []string{"deploy"}}, Steps: wf.Jobs["lint"].Steps}
+
+	recorder := &jobEvents{}
+	executor := newStrategyExecutor(wf, recorder.executor(0, ""))
+	err := executor.Execute(t.Context(), wf, executor.analyzer.ctx)
+
+	var cycle *DependencyCycleError
+	assert.ErrorAs(t, err, &cycle)
+	assert.Empty(t, recorder.events)
+}
@@ -0,0 +1,383 @@
+package workflow
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/telton/rehearse/internal/logger"
+	"github.com/telton/rehearse/internal/mask"
+)
+
+// workflowCommand is a command a step writes to its output, such as
+// ::warning file=app.go,line=3::Deprecated call.
+type workflowCommand struct {
+	name       string
+	properties map[string]string
+	value      string
+}
+
+// parseWorkflowCommand parses line as a workflow command, unescaping its
+// properties and value as the runner does.
+func parseWorkflowCommand(line string) (workflowCommand, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "::")
+	if !ok {
+		return workflowCommand{}, false
+	}
+
+	header, value, ok := strings.Cut(rest, "::")
+	if !ok {
+		return workflowCommand{}, false
+	}
+
+	name, props, _ := strings.Cut(header, " ")
+	if name == "" {
+		return workflowCommand{}, false
+	}
+
+	cmd := workflowCommand{name: name, properties: make(map[string]string), value: unescapeCommandData(value)}
+	for prop := range strings.SplitSeq(props, ",") {
+		key, val, ok := strings.Cut(prop, "=")
+		if key = strings.TrimSpace(key); ok && key != "" {
+			cmd.properties[key] = unescapeCommandProperty(val)
+		}
+	}
+
+	return cmd, true
+}
+
+var (
+	commandDataUnescaper     = strings.NewReplacer("%0D", "\r", "%0A", "\n", "%25", "%")
+	commandPropertyUnescaper = strings.NewReplacer("%0D", "\r", "%0A", "\n", "%3A", ":", "%2C", ",", "%25", "%")
+)
+
+func unescapeCommandData(s string) string     { return commandDataUnescaper.Replace(s) }
+func unescapeCommandProperty(s string) string { return commandPropertyUnescaper.Replace(s) }
+
+// Annotation is an error, warning or notice a step reported through a
+// workflow command, optionally pointing at a place in a file.
+type Annotation struct {
+	Level     string // error, warning or notice
+	Title     string
+	Message   string
+	File      string // Path on the host, so that editors can open it
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Job       string
+	Step      string
+}
+
+// Location returns where the annotation points as file:line:column, the
+// form editors and terminals recognize, or "" if it names no file.
+func (a Annotation) Location() string {
+	if a.File == "" {
+		return ""
+	}
+
+	location := a.File
+	if a.Line > 0 {
+		location += ":" + strconv.Itoa(a.Line)
+		if a.Column > 0 {
+			location += ":" + strconv.Itoa(a.Column)
+		}
+	}
+	return location
+}
+
+// String returns the annotation's message, led by its title and location.
+func (a Annotation) String() string {
+	message := a.Message
+	if a.Title != "" {
+		message = a.Title + ": " + message
+	}
+	if location := a.Location(); location != "" {
+		message = location + ": " + message
+	}
+	return message
+}
+
+// annotationLog collects the annotations of a run's jobs.
+type annotationLog struct {
+	mu          sync.Mutex
+	annotations []Annotation
+}
+
+// add records annotations.
+func (l *annotationLog) add(annotations ...Annotation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.annotations = append(l.annotations, annotations...)
+}
+
+// list returns the annotations recorded so far.
+func (l *annotationLog) list() []Annotation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.annotations)
+}
+
+// Annotations returns the errors, warnings and notices the steps of the last
+// run reported, in the order their jobs finished.
+func (e *Executor) Annotations() []Annotation {
+	return e.annotations.list()
+}
+
+// commandProcessor renders the output of a step, acting on the workflow
+// commands in it as the runner does instead of printing them.
+type commandProcessor struct {
+	runtime      *Runtime
+	step         *Step
+	renderer     *RunRenderer // nil to act on commands without rendering
+	jobName      string
+	stepName     string
+	debug        bool         // Render ::debug:: messages, as with ACTIONS_STEP_DEBUG
+	expandGroups bool         // Render groups in full as they are written
+	stopToken    string       // Commands are ignored until ::stopToken:: if set
+	group        *outputGroup // Group being collected to be folded, if any
+	inGroup      bool         // Lines are rendered as part of a group
+	err          error        // First command that fails the step, as disabled ones do
+}
+
+// outputGroup is the output of a ::group::, collected until it ends so that
+// it can be folded to its title, as the log viewer on GitHub does.
+type outputGroup struct {
+	title  string
+	lines  []groupLine
+	failed bool // The group reported an error
+}
+
+type groupLine struct {
+	text    string
+	isError bool
+}
+
+// newCommandProcessor returns a processor for the output of step.
+func newCommandProcessor(runtime *Runtime, step *Step, renderer *RunRenderer) *commandProcessor {
+	jobName := ""
+	if runtime.JobContext != nil && runtime.JobContext.Job != nil {
+		jobName = runtime.JobContext.Job.Name
+	}
+	debug := stepDebug(runtime.expressionContext())
+
+	return &commandProcessor{
+		runtime:  runtime,
+		step:     step,
+		renderer: renderer,
+		jobName:  jobName,
+		stepName: cmp.Or(step.Name, step.ID),
+		debug:    debug,
+		// There is no log viewer to unfold groups in, so they are not folded
+		// when debugging.
+		expandGroups: debug || logger.DebugEnabled(),
+	}
+}
+
+// stepDebug reports whether debug logging is turned on, which GitHub does
+// through either a secret or a variable named ACTIONS_STEP_DEBUG.
+func stepDebug(ctx *Context) bool {
+	return ctx.Secrets["ACTIONS_STEP_DEBUG"] == "true" || ctx.Vars["ACTIONS_STEP_DEBUG"] == "true"
+}
+
+// writers returns writers for the step's stdout and stderr that process each
+// line written to them. Flush them once the step ends.
+func (p *commandProcessor) writers() (stdout, stderr *LogLineWriter) {
+	stdout = &LogLineWriter{render: func(line string) { p.processLine(line, false) }}
+	stderr = &LogLineWriter{render: func(line string) { p.processLine(line, true) }}
+	return stdout, stderr
+}
+
+// processLine acts on line if it is a workflow command, and renders it
+// otherwise.
+func (p *commandProcessor) processLine(line string, isError bool) {
+	cmd, ok := parseWorkflowCommand(line)
+	if p.stopToken != "" {
+		if ok && cmd.name == p.stopToken {
+			p.stopToken = ""
+			return
+		}
+		p.print(line, isError)
+		return
+	}
+
+	if !ok || !p.run(cmd) {
+		p.print(line, isError)
+	}
+}
+
+// run acts on cmd, reporting whether it is a command the runner knows.
+func (p *commandProcessor) run(cmd workflowCommand) bool {
+	switch cmd.name {
+	case "group":
+		p.endGroup(false)
+		if p.expandGroups {
+			p.print("[GROUP] "+cmd.value, false)
+			p.inGroup = true
+		} else {
+			p.group = &outputGroup{title: cmd.value}
+		}
+	case "endgroup":
+		p.endGroup(false)
+	case "add-mask":
+		mask.Add(cmd.value)
+	case "error", "warning", "notice":
+		p.annotate(cmd)
+	case "debug":
+		if p.debug {
+			p.print("[DEBUG] "+cmd.value, false)
+		}
+	case "stop-commands":
+		p.stopToken = cmd.value
+	case "set-output":
+		p.annotate(workflowCommand{name: "warning", value: "The `set-output` command is deprecated and will be disabled soon. Please upgrade to using Environment Files."})
+		p.setOutput(cmd.properties["name"], cmd.value)
+	case "set-env":
+		if !p.unsecureCommandsAllowed() {
+			p.fail(fmt.Errorf("the set-env command is disabled; set ACTIONS_ALLOW_UNSECURE_COMMANDS to true in the step's env to enable it"))
+			return true
+		}
+		if name := cmd.properties["name"]; name != "" {
+			if p.runtime.DynamicEnv == nil {
+				p.runtime.DynamicEnv = make(map[string]string)
+			}
+			p.runtime.DynamicEnv[name] = cmd.value
+			if p.renderer != nil {
+				p.renderer.RenderEnvironmentSet(name, cmd.value)
+			}
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+// annotate records the annotation an error, warning or notice command
+// reports and renders it in the step's output.
+func (p *commandProcessor) annotate(cmd workflowCommand) {
+	props := cmd.properties
+	annotation := Annotation{
+		Level:     cmd.name,
+		Title:     props["title"],
+		Message:   cmd.value,
+		File:      p.hostPath(props["file"]),
+		Line:      atoiOrZero(props["line"]),
+		Column:    atoiOrZero(cmp.Or(props["col"], props["column"])),
+		EndLine:   atoiOrZero(props["endLine"]),
+		EndColumn: atoiOrZero(props["endColumn"]),
+		Job:       p.jobName,
+		Step:      p.stepName,
+	}
+	p.runtime.Annotations = append(p.runtime.Annotations, annotation)
+	if p.group != nil && annotation.Level == "error" {
+		p.group.failed = true
+	}
+
+	p.print("["+strings.ToUpper(annotation.Level)+"] "+annotation.String(), annotation.Level == "error")
+}
+
+// setOutput sets an output of the step, as ::set-output:: does.
+func (p *commandProcessor) setOutput(name, value string) {
+	if name == "" || p.step.ID == "" {
+		return
+	}
+
+	if p.runtime.StepOutputs == nil {
+		p.runtime.StepOutputs = make(map[string]map[string]string)
+	}
+	if p.runtime.StepOutputs[p.step.ID] == nil {
+		p.runtime.StepOutputs[p.step.ID] = make(map[string]string)
+	}
+	p.runtime.StepOutputs[p.step.ID][name] = value
+	if p.renderer != nil {
+		p.renderer.RenderOutputSet(p.step.ID, name, value)
+	}
+}
+
+// unsecureCommandsAllowed reports whether the step opted in to commands the
+// runner disables, such as ::set-env::.
+func (p *commandProcessor) unsecureCommandsAllowed() bool {
+	env, err := p.runtime.layeredEnv(p.step, nil)
+	return err == nil && strings.EqualFold(env["ACTIONS_ALLOW_UNSECURE_COMMANDS"], "true")
+}
+
+// fail records err as the reason the step fails, reporting it as an error
+// annotation.
+func (p *commandProcessor) fail(err error) {
+	p.err = cmp.Or(p.err, err)
+	p.annotate(workflowCommand{name: "error", value: err.Error()})
+}
+
+// endGroup ends the current group, rendering it folded to its title and line
+// count unless expand is set or it reported an error. Call it with whether the
+// step failed once the step ends, to render a group left open.
+func (p *commandProcessor) endGroup(expand bool) {
+	p.inGroup = false
+	group := p.group
+	if group == nil {
+		return
+	}
+	p.group = nil
+
+	if !expand && !group.failed {
+		count := fmt.Sprintf("%d lines", len(group.lines))
+		if len(group.lines) == 1 {
+			count = "1 line"
+		}
+		p.print(fmt.Sprintf("[GROUP] %s (%s)", group.title, count), false)
+		return
+	}
+
+	p.print("[GROUP] "+group.title, false)
+	p.inGroup = true
+	for _, line := range group.lines {
+		p.print(line.text, line.isError)
+	}
+	p.inGroup = false
+}
+
+// print renders a line of the step's output, indented when it belongs to a
+// group, or collects it for the group being folded.
+func (p *commandProcessor) print(line string, isError bool) {
+	if p.group != nil {
+		p.group.lines = append(p.group.lines, groupLine{text: line, isError: isError})
+		return
+	}
+	if p.renderer == nil {
+		return
+	}
+	if p.inGroup {
+		line = "  " + line
+	}
+	p.renderer.RenderLogLine(p.jobName, p.stepName, line, isError)
+}
+
+// hostPath maps a file an annotation names, which is relative to the
+// workspace or inside the container's, to its path on the host.
+func (p *commandProcessor) hostPath(file string) string {
+	switch {
+	case file == "":
+		return ""
+	case path.IsAbs(file):
+		rel, ok := strings.CutPrefix(path.Clean(file), "/github/workspace")
+		if !ok || (rel != "" && !strings.HasPrefix(rel, "/")) || p.runtime.WorkingDir == "" {
+			return file
+		}
+		return filepath.Join(p.runtime.WorkingDir, filepath.FromSlash(rel))
+	case p.runtime.WorkingDir == "":
+		return file
+	}
+	return filepath.Join(p.runtime.WorkingDir, filepath.FromSlash(file))
+}
+
+// atoiOrZero parses s as a number, returning 0 if it is not one.
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
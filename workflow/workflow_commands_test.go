@@ -0,0 +1,290 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telton/rehearse/internal/mask"
+)
+
+func TestParseWorkflowCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected workflowCommand
+		ok       bool
+	}{
+		{
+			name:     "no properties",
+			line:     "::group::Install dependencies",
+			expected: workflowCommand{name: "group", properties: map[string]string{}, value: "Install dependencies"},
+			ok:       true,
+		},
+		{
+			name: "properties",
+			line: "::error file=app.go,line=10,col=5,title=Build failed::undefined: foo",
+			expected: workflowCommand{
+				name:       "error",
+				properties: map[string]string{"file": "app.go", "line": "10", "col": "5", "title": "Build failed"},
+				value:      "undefined: foo",
+			},
+			ok: true,
+		},
+		{
+			name: "escaped",
+			line: "::warning title=a%3Ab%2Cc::first%0Asecond 100%25",
+			expected: workflowCommand{
+				name:       "warning",
+				properties: map[string]string{"title": "a:b,c"},
+				value:      "first\nsecond 100%",
+			},
+			ok: true,
+		},
+		{
+			name:     "empty value",
+			line:     "::endgroup::",
+			expected: workflowCommand{name: "endgroup", properties: map[string]string{}, value: ""},
+			ok:       true,
+		},
+		{
+			name:     "value containing ::",
+			line:     "  ::notice::std::vector",
+			expected: workflowCommand{name: "notice", properties: map[string]string{}, value: "std::vector"},
+			ok:       true,
+		},
+		{name: "plain output", line: "building app"},
+		{name: "unterminated", line: "::warning"},
+		{name: "no name", line: ":: ::value"},
+		{name: "not at start", line: "echo ::warning::x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := parseWorkflowCommand(tt.line)
+
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.expected, cmd)
+			}
+		})
+	}
+}
+
+func TestAnnotation_String(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation Annotation
+		expected   string
+	}{
+		{
+			name:       "message only",
+			annotation: Annotation{Message: "Tests failed"},
+			expected:   "Tests failed",
+		},
+		{
+			name:       "file",
+			annotation: Annotation{Message: "unused variable", File: "/src/app/main.go"},
+			expected:   "/src/app/main.go: unused variable",
+		},
+		{
+			name:       "file, line, column and title",
+			annotation: Annotation{Title: "vet", Message: "unused variable", File: "/src/app/main.go", Line: 12, Column: 3},
+			expected:   "/src/app/main.go:12:3: vet: unused variable",
+		},
+		{
+			name:       "column without line",
+			annotation: Annotation{Message: "odd", File: "main.go", Column: 3},
+			expected:   "main.go: odd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.annotation.String())
+		})
+	}
+}
+
+func TestCommandProcessor(t *testing.T) {
+	runtime := CreateTestRuntime(t, "/src/app")
+	step := &Step{ID: "build", Name: "Build", Run: "make"}
+	processor := newCommandProcessor(runtime, step, nil)
+
+	for _, line := range []string{
+		"::group::Compile",
+		"::add-mask::add-mask-test-value",
+		"::add-mask::   ",
+		"::error file=cmd/main.go,line=7,col=2,title=compile::undefined: foo",
+		"::warning file=/github/workspace/go.mod,line=3::old go version",
+		"::notice::done",
+		"::debug::hidden without ACTIONS_STEP_DEBUG",
+		"::endgroup::",
+		"::stop-commands::pause-token",
+		"::warning::printed, not run",
+		"::pause-token::",
+		"::set-output name=version::1.2.3",
+		"::unknown-command::printed as is",
+	} {
+		processor.processLine(line, false)
+	}
+
+	require.NoError(t, processor.err)
+	assert.Equal(t, "token=***", mask.String("token=add-mask-test-value"))
+	assert.Equal(t, map[string]string{"version": "1.2.3"}, runtime.StepOutputs["build"])
+	assert.Equal(t, []Annotation{
+		{Level: "error", Title: "compile", Message: "undefined: foo", File: "/src/app/cmd/main.go", Line: 7, Column: 2, Job: "test-job", Step: "Build"},
+		{Level: "warning", Message: "old go version", File: "/src/app/go.mod", Line: 3, Job: "test-job", Step: "Build"},
+		{Level: "notice", Message: "done", Job: "test-job", Step: "Build"},
+		{Level: "warning", Message: "The `set-output` command is deprecated and will be disabled soon. Please upgrade to using Environment Files.", Job: "test-job", Step: "Build"},
+	}, runtime.Annotations)
+	assert.False(t, processor.inGroup)
+	assert.Empty(t, processor.stopToken)
+}
+
+func TestCommandProcessor_SetEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected map[string]string
+		errMsg   string
+	}{
+		{
+			name:     "disabled",
+			expected: map[string]string{},
+			errMsg:   "the set-env command is disabled",
+		},
+		{
+			name:     "allowed by the step's env",
+			env:      map[string]string{"ACTIONS_ALLOW_UNSECURE_COMMANDS": "true"},
+			expected: map[string]string{"GREETING": "hello"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := CreateTestRuntime(t, "/src/app")
+			runtime.DynamicEnv = make(map[string]string)
+			processor := newCommandProcessor(runtime, &Step{ID: "env", Env: tt.env}, nil)
+
+			processor.processLine("::set-env name=GREETING::hello", false)
+
+			assert.Equal(t, tt.expected, runtime.DynamicEnv)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, processor.err, tt.errMsg)
+				require.Len(t, runtime.Annotations, 1)
+				assert.Equal(t, "error", runtime.Annotations[0].Level)
+			} else {
+				assert.NoError(t, processor.err)
+			}
+		})
+	}
+}
+
+func TestCommandProcessor_Debug(t *testing.T) {
+	runtime := CreateTestRuntime(t, "/src/app")
+	runtime.Context = &Context{Secrets: map[string]string{"ACTIONS_STEP_DEBUG": "true"}}
+
+	assert.True(t, newCommandProcessor(runtime, &Step{}, nil).debug)
+	runtime.Context = &Context{Vars: map[string]string{"ACTIONS_STEP_DEBUG": "true"}}
+	assert.True(t, newCommandProcessor(runtime, &Step{}, nil).debug)
+	assert.False(t, newCommandProcessor(CreateTestRuntime(t, "/src/app"), &Step{}, nil).debug)
+}
+
+func TestCommandProcessor_Groups(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		stepFailed bool
+		debug      bool
+		contains   []string
+		omits      []string
+	}{
+		{
+			name:     "folded to its title and line count",
+			lines:    []string{"::group::Compile", "go build", "go vet", "::endgroup::", "done"},
+			contains: []string{"[GROUP] Compile (2 lines)", "done"},
+			omits:    []string{"go build", "go vet"},
+		},
+		{
+			name:     "expanded when it reports an error",
+			lines:    []string{"::group::Compile", "go build", "::error::undefined: foo", "::endgroup::"},
+			contains: []string{"[GROUP] Compile", "  go build", "  [ERROR] undefined: foo"},
+			omits:    []string{"lines)"},
+		},
+		{
+			name:     "left open by a step that succeeds",
+			lines:    []string{"::group::Test", "ok"},
+			contains: []string{"[GROUP] Test (1 line)"},
+			omits:    []string{"ok\n"},
+		},
+		{
+			name:       "left open by a step that fails",
+			lines:      []string{"::group::Test", "FAIL"},
+			stepFailed: true,
+			contains:   []string{"[GROUP] Test", "  FAIL"},
+			omits:      []string{"line)"},
+		},
+		{
+			name:     "expanded when debugging",
+			lines:    []string{"::group::Compile", "go build", "::endgroup::"},
+			debug:    true,
+			contains: []string{"[GROUP] Compile", "  go build"},
+			omits:    []string{"line)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			saved := stdout
+			stdout = &output
+			t.Cleanup(func() { stdout = saved })
+
+			runtime := CreateTestRuntime(t, "/src/app")
+			if tt.debug {
+				runtime.Context = &Context{Secrets: map[string]string{"ACTIONS_STEP_DEBUG": "true"}}
+			}
+			processor := newCommandProcessor(runtime, &Step{ID: "build"}, NewRunRenderer())
+
+			for _, line := range tt.lines {
+				processor.processLine(line, false)
+			}
+			processor.endGroup(tt.stepFailed)
+
+			for _, s := range tt.contains {
+				assert.Contains(t, output.String(), s)
+			}
+			for _, s := range tt.omits {
+				assert.NotContains(t, output.String(), s)
+			}
+		})
+	}
+}
+
+func TestShellStepExecutor_Execute_WorkflowCommands(t *testing.T) {
+	mockDocker := NewMockDockerClient()
+	executor := CreateTestShellExecutor(mockDocker)
+
+	runtime := CreateTestRuntime(t, "/tmp/workspace")
+	runtime.JobContainer = &ContainerInfo{ID: "job-container", Status: "running"}
+
+	mockDocker.On("ExecInContainer", mock.Anything, "job-container", mock.Anything).Run(func(args mock.Arguments) {
+		config := args.Get(2).(*ExecConfig)
+		fmt.Fprintln(config.Stdout, "::warning file=main.go,line=4::shadowed variable")
+		fmt.Fprint(config.Stderr, "::set-env name=LEGACY::value")
+	}).Return(&ExecResult{}, nil)
+
+	_, err := executor.Execute(t.Context(), CreateTestStep("lint", "Lint", "make lint"), runtime)
+
+	// The last line has no newline, and is processed once the command ends.
+	assert.ErrorContains(t, err, "processing workflow commands: the set-env command is disabled")
+	require.Len(t, runtime.Annotations, 2)
+	assert.Equal(t, "/tmp/workspace/main.go:4: shadowed variable", runtime.Annotations[0].String())
+
+	mockDocker.AssertExpectations(t)
+}